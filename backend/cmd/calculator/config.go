@@ -0,0 +1,103 @@
+package main
+
+import (
+    "flag"    // Для разбора флагов командной строки
+    "fmt"     // Для форматирования ошибок
+    "os"      // Для чтения переменных окружения
+    "strconv" // Для разбора числовых переменных окружения
+    "strings" // Для нормализации портов
+
+    "calculatorapi/utility/config" // Общий секрет калькуляторов по умолчанию
+)
+
+// Параметры калькулятора. Значения по умолчанию переопределяются переменными окружения,
+// а те, в свою очередь, флагами командной строки, поэтому из одной сборки можно запустить любое число агентов.
+var (
+    httpPort         = ":8081"                  // Порт HTTP сервера, "off" отключает его (CALCULATOR_HTTP_PORT, -http-port)
+    port             = ":50051"                 // Порт gRPC сервера (CALCULATOR_GRPC_PORT, -grpc-port)
+    maxGoroutines    = 5                        // Максимальное количество горутин (CALCULATOR_MAX_GOROUTINES, -max-goroutines)
+    agentName        = ""                       // Имя калькулятора в логах оркестратора (CALCULATOR_NAME, -name)
+    orchestratorURL  = "http://localhost:8080"  // Адрес оркестратора, у которого калькулятор забирает подзадачи (ORCHESTRATOR_URL, -orchestrator)
+    orchestratorGRPC = "localhost:50050"        // Адрес gRPC сервера оркестратора, принимающего статусы (ORCHESTRATOR_GRPC_ADDR, -orchestrator-grpc)
+    advertiseHost    = "localhost"              // Хост, по которому оркестратор обращается к калькулятору (CALCULATOR_ADVERTISE_HOST, -advertise-host)
+    agentToken       = config.DefaultAgentToken // Общий секрет для /internal/task и gRPC оркестратора (AGENT_TOKEN, -agent-token)
+    devMode          = false                    // Режим разработки: разрешает секрет по умолчанию (DEV_MODE, -dev)
+)
+
+// parseConfig заполняет параметры калькулятора из переменных окружения и аргументов командной строки.
+func parseConfig(args []string) error {
+    maxFromEnv := maxGoroutines
+    if value := os.Getenv("CALCULATOR_MAX_GOROUTINES"); value != "" {
+        parsed, err := strconv.Atoi(value)
+        if err != nil {
+            return fmt.Errorf("CALCULATOR_MAX_GOROUTINES: %w", err)
+        }
+        maxFromEnv = parsed
+    }
+    devFromEnv := devMode
+    if value := os.Getenv("DEV_MODE"); value != "" {
+        parsed, err := strconv.ParseBool(value)
+        if err != nil {
+            return fmt.Errorf("DEV_MODE: %w", err)
+        }
+        devFromEnv = parsed
+    }
+
+    flags := flag.NewFlagSet("calculator", flag.ContinueOnError)
+    flags.StringVar(&httpPort, "http-port", envOrDefault("CALCULATOR_HTTP_PORT", httpPort), "HTTP listen port, e.g. :8081, or off to serve gRPC only")
+    flags.StringVar(&port, "grpc-port", envOrDefault("CALCULATOR_GRPC_PORT", port), "gRPC listen port, e.g. :50051")
+    flags.IntVar(&maxGoroutines, "max-goroutines", maxFromEnv, "maximum number of concurrent calculations")
+    flags.StringVar(&agentName, "name", envOrDefault("CALCULATOR_NAME", agentName), "agent name reported to the orchestrator (default calculator-<grpc-port>)")
+    flags.StringVar(&orchestratorURL, "orchestrator", envOrDefault("ORCHESTRATOR_URL", orchestratorURL), "orchestrator HTTP address")
+    flags.StringVar(&orchestratorGRPC, "orchestrator-grpc", envOrDefault("ORCHESTRATOR_GRPC_ADDR", orchestratorGRPC), "orchestrator gRPC address")
+    flags.StringVar(&advertiseHost, "advertise-host", envOrDefault("CALCULATOR_ADVERTISE_HOST", advertiseHost), "host the orchestrator uses to reach this agent")
+    flags.StringVar(&agentToken, "agent-token", envOrDefault("AGENT_TOKEN", agentToken), "shared secret sent to the orchestrator task endpoint and gRPC service")
+    flags.BoolVar(&devMode, "dev", devFromEnv, "allow the default agent token for local runs")
+    if err := flags.Parse(args); err != nil {
+        return err
+    }
+
+    if maxGoroutines <= 0 {
+        return fmt.Errorf("max-goroutines must be positive, got %d", maxGoroutines)
+    }
+    // Секрет по умолчанию известен всем, поэтому, как и оркестратор, калькулятор принимает его только в режиме разработки
+    if agentToken == config.DefaultAgentToken && !devMode {
+        return fmt.Errorf("refusing to use the default agent token outside dev mode; set AGENT_TOKEN or pass -dev")
+    }
+    if httpPort == "off" {
+        httpPort = "" // Калькулятор работает только через gRPC
+    } else {
+        httpPort = normalizePort(httpPort)
+    }
+    port = normalizePort(port)
+    if agentName == "" {
+        agentName = "calculator-" + port[strings.LastIndex(port, ":")+1:]
+    }
+    orchestratorURL = strings.TrimSuffix(orchestratorURL, "/")
+    return nil
+}
+
+// envOrDefault возвращает значение переменной окружения или значение по умолчанию, если она не задана.
+func envOrDefault(name, fallback string) string {
+    if value := os.Getenv(name); value != "" {
+        return value
+    }
+    return fallback
+}
+
+// advertisedAddress возвращает адрес, который калькулятор сообщает оркестратору при регистрации:
+// для порта вида ":8081" подставляется advertiseHost.
+func advertisedAddress(listen string) string {
+    if strings.HasPrefix(listen, ":") {
+        return advertiseHost + listen
+    }
+    return listen
+}
+
+// normalizePort превращает номер порта "8081" в адрес ":8081"; адреса с хостом не изменяются.
+func normalizePort(value string) string {
+    if strings.Contains(value, ":") {
+        return value
+    }
+    return ":" + value
+}
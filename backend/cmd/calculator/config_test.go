@@ -0,0 +1,70 @@
+package main
+
+import (
+    "testing"
+
+    "calculatorapi/utility/config"
+)
+
+// Проверка приоритета флагов над переменными окружения
+func TestParseConfig(t *testing.T) {
+    t.Setenv("CALCULATOR_HTTP_PORT", "9001")
+    t.Setenv("CALCULATOR_MAX_GOROUTINES", "8")
+    t.Setenv("ORCHESTRATOR_URL", "http://orchestrator:8080/")
+    t.Setenv("AGENT_TOKEN", "secret")
+
+    if err := parseConfig([]string{"-grpc-port", "50061", "-max-goroutines", "2"}); err != nil {
+        t.Fatalf("parseConfig returned error: %v", err)
+    }
+
+    if httpPort != ":9001" {
+        t.Errorf("expected http port from environment, got %q", httpPort)
+    }
+    if port != ":50061" {
+        t.Errorf("expected grpc port from flag, got %q", port)
+    }
+    if maxGoroutines != 2 {
+        t.Errorf("expected max goroutines from flag, got %d", maxGoroutines)
+    }
+    if agentName != "calculator-50061" {
+        t.Errorf("expected default agent name, got %q", agentName)
+    }
+    if orchestratorURL != "http://orchestrator:8080" {
+        t.Errorf("expected trimmed orchestrator URL, got %q", orchestratorURL)
+    }
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+    t.Setenv("CALCULATOR_MAX_GOROUTINES", "many")
+    if err := parseConfig(nil); err == nil {
+        t.Error("expected error for non-numeric CALCULATOR_MAX_GOROUTINES")
+    }
+}
+
+// Значение "off" отключает HTTP сервер
+func TestParseConfigHTTPDisabled(t *testing.T) {
+    if err := parseConfig([]string{"-http-port", "off", "-dev"}); err != nil {
+        t.Fatalf("parseConfig returned error: %v", err)
+    }
+    if httpPort != "" {
+        t.Errorf("expected disabled HTTP server, got %q", httpPort)
+    }
+}
+
+// Общий секрет по умолчанию допустим только в режиме разработки
+func TestParseConfigDefaultToken(t *testing.T) {
+    defer func(token string, dev bool) { agentToken, devMode = token, dev }(agentToken, devMode)
+    agentToken, devMode = config.DefaultAgentToken, false
+
+    if err := parseConfig(nil); err == nil {
+        t.Error("expected error for the default agent token outside dev mode")
+    }
+    t.Setenv("DEV_MODE", "true")
+    if err := parseConfig(nil); err != nil {
+        t.Errorf("expected the default agent token to be allowed in dev mode, got %v", err)
+    }
+    t.Setenv("DEV_MODE", "false")
+    if err := parseConfig([]string{"-agent-token", "secret"}); err != nil || agentToken != "secret" {
+        t.Errorf("expected the token from flag, got %q, %v", agentToken, err)
+    }
+}
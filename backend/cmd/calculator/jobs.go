@@ -0,0 +1,86 @@
+package main
+
+import (
+    "context" // Отмена выполняющихся вычислений
+    "sync"    // Синхронизация доступа к реестру
+)
+
+// jobRegistry хранит функции отмены вычислений, выполняющихся на этом калькуляторе.
+// Одно вычисление может выполняться несколькими подзадачами одновременно.
+type jobRegistry struct {
+    mu     sync.Mutex
+    nextID int
+    jobs   map[int]map[int]context.CancelFunc // Идентификатор вычисления -> задания калькулятора
+}
+
+// Задания, выполняющиеся на калькуляторе
+var runningJobs = &jobRegistry{jobs: map[int]map[int]context.CancelFunc{}}
+
+// start регистрирует задание вычисления id и возвращает его контекст и функцию, снимающую регистрацию.
+func (r *jobRegistry) start(id int) (context.Context, func()) {
+    ctx, cancel := context.WithCancel(context.Background())
+
+    r.mu.Lock()
+    r.nextID++
+    job := r.nextID
+    if r.jobs[id] == nil {
+        r.jobs[id] = map[int]context.CancelFunc{}
+    }
+    r.jobs[id][job] = cancel
+    r.mu.Unlock()
+
+    return ctx, func() {
+        r.mu.Lock()
+        delete(r.jobs[id], job)
+        if len(r.jobs[id]) == 0 {
+            delete(r.jobs, id)
+        }
+        r.mu.Unlock()
+        cancel()
+    }
+}
+
+// cancel отменяет все задания вычисления id и возвращает их количество.
+func (r *jobRegistry) cancel(id int) int {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, cancel := range r.jobs[id] {
+        cancel()
+    }
+    return len(r.jobs[id])
+}
+
+// taskKey - подзадача taskID вычисления id; для вычисления целиком taskID равен 0.
+type taskKey struct {
+    id     int
+    taskID int
+}
+
+// taskSet хранит подзадачи, принятые калькулятором и еще не завершенные. Повторная отправка той же подзадачи,
+// например повтор вызова gRPC после обрыва соединения, подтверждается без второго запуска.
+type taskSet struct {
+    mu    sync.Mutex
+    tasks map[taskKey]bool
+}
+
+// Подзадачи, принятые калькулятором через PerformCalculation и /calculate
+var acceptedTasks = &taskSet{tasks: map[taskKey]bool{}}
+
+// accept отмечает подзадачу принятой. Возвращает false, если она уже выполняется на калькуляторе.
+func (s *taskSet) accept(id, taskID int) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    key := taskKey{id: id, taskID: taskID}
+    if s.tasks[key] {
+        return false
+    }
+    s.tasks[key] = true
+    return true
+}
+
+// release снимает отметку подзадачи, после чего ее можно принять снова.
+func (s *taskSet) release(id, taskID int) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.tasks, taskKey{id: id, taskID: taskID})
+}
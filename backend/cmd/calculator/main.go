@@ -0,0 +1,541 @@
+package main
+
+import (
+    // Импортирование необходимых пакетов
+    "context"
+    "net"
+    "encoding/json"    // Для работы с JSON
+    "errors"           // Распознавание отмены вычисления
+    "fmt"              // Для форматированного ввода и вывода
+    "log"              // Для логирования
+    "net/http"         // Для работы с HTTP
+    "net/url"          // Для экранирования имени агента в запросе
+    "os"               // Для чтения аргументов командной строки
+    "os/signal"        // Для остановки по сигналу, когда HTTP сервер отключен
+    "strings"          // Для разбора ключей длительностей функций
+    "sync"             // Для синхронизации горутин
+    "syscall"          // Сигнал SIGTERM
+    "time"             // Для работы со временем
+
+    // Импортирование собственных пакетов
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/health"
+    healthpb "google.golang.org/grpc/health/grpc_health_v1"
+    "google.golang.org/grpc/keepalive"
+    "google.golang.org/grpc/status"
+    pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+    "calculatorapi/utility/calculation"  // Для выполнения вычислений
+	"calculatorapi/utility/models"       // Структуры протокола получения подзадач
+)
+
+const (
+    taskWait        = 20                        // Сколько секунд оркестратор ждет появления подзадачи
+    retryInterval   = 5 * time.Second           // Пауза перед повторным запросом, если оркестратор недоступен
+    reportTimeout   = 5 * time.Second           // Ограничение времени одной отправки статуса оркестратору
+    reportAttempts  = 3                         // Количество попыток отправки статуса
+)
+
+type server struct {
+    pb.UnimplementedCalculatorServiceServer
+}
+
+// Структура запроса на выполнение операции
+type OperationRequest struct {
+    ID        int               `json:"id"`          // Идентификатор операции
+    TaskID    int               `json:"taskId"`      // Идентификатор подзадачи, 0 если вычисляется все выражение
+    Operation string            `json:"operation"`   // Строка операции
+    Times     map[string]int    `json:"times"`       // Время выполнения каждой операции
+    Variables map[string]float64 `json:"variables"`  // Значения переменных выражения
+    Precision *calculation.Precision `json:"precision"` // Точный десятичный или рациональный режим, nil - вычисление в float64
+}
+
+var (
+    // Глобальные переменные для контроля состояния сервера и горутин
+    currentGoroutines = 0                                // Текущее количество работающих горутин
+    mu               sync.Mutex                          // Мьютекс для синхронизации доступа к currentGoroutines
+    shutdownCh       = make(chan struct{})               // Канал для сигнала остановки сервера
+    serverRunning    = true                              // Флаг состояния работы сервера
+
+    shutdownOnce     sync.Once                           // Остановка начинается один раз, по HTTP или по сигналу
+
+    // Клиент оркестратора для отправки статусов; калькулятор не обращается к базе данных напрямую
+    orchestratorClient pb.OrchestratorServiceClient
+
+    // Стандартный сервис grpc.health.v1, по которому оркестратор проверяет, что калькулятор работает
+    healthServer = health.NewServer()
+)
+
+// Преобразование времени выполнения операций из запроса в структуру для вычисления
+func ConvertOperationTimes(times map[string]int) calculation.OperationTimes {
+    operationTimes := calculation.OperationTimes{}
+    for k, v := range times {
+        switch k {
+        // Заполнение времени выполнения для каждой операции
+        case "add_duration":
+            operationTimes["+"] = time.Duration(v) * time.Second
+        case "subtract_duration":
+            operationTimes["-"] = time.Duration(v) * time.Second
+        case "multiply_duration":
+            operationTimes["*"] = time.Duration(v) * time.Second
+        case "divide_duration":
+            operationTimes["/"] = time.Duration(v) * time.Second
+        case "power_duration":
+            operationTimes["^"] = time.Duration(v) * time.Second
+        case "modulo_duration":
+            operationTimes["%"] = time.Duration(v) * time.Second
+        case "int_divide_duration":
+            operationTimes["//"] = time.Duration(v) * time.Second
+        default:
+            // Длительность встроенной функции передается по ее имени, например "sqrt_duration"
+            if name, ok := strings.CutSuffix(k, "_duration"); ok {
+                if _, ok := calculation.LookupFunction(name); ok {
+                    operationTimes[name] = time.Duration(v) * time.Second
+                }
+            }
+        }
+    }
+    return operationTimes
+}
+
+// Запуск вычисления на основе полученных данных.
+// Если taskID не равен 0, operation - одна операция графа выражения,
+// иначе вычисляется все выражение. Статусы и результат отправляются оркестратору.
+// Если задана точность precision, выражение вычисляется в ее точном режиме.
+// Подзадача должна быть принята через acceptedTasks; отметка снимается после ее завершения.
+func startCalculation(id int, taskID int, operation string, variables map[string]float64, precision *calculation.Precision, times map[string]int) {
+    convertedTimes := ConvertOperationTimes(times)
+
+    // Выполнение вычисления в отдельной горутине
+    go func() {
+        defer func() {
+            // После завершения вычисления уменьшаем количество работающих горутин
+            mu.Lock()
+            currentGoroutines--
+            mu.Unlock()
+            acceptedTasks.release(id, taskID)
+        }()
+
+        // Статус 'work' подзадачи выставляет оркестратор при отправке, о вычислении целиком сообщаем сами
+        if taskID == 0 {
+            reportStatus(&pb.StatusReport{Id: int32(id), Status: "work", Agent: agentName})
+        }
+
+        runTask(id, taskID, operation, variables, precision, convertedTimes)
+    }()
+}
+
+// runTask выполняет вычисление или подзадачу с возможностью отмены через CancelCalculation.
+// Об отмененном вычислении оркестратор уже знает, поэтому его статус не отправляется.
+func runTask(id int, taskID int, operation string, variables map[string]float64, precision *calculation.Precision, operationTimes calculation.OperationTimes) {
+    ctx, done := runningJobs.start(id)
+    defer done()
+
+    report := executeTask(ctx, id, taskID, operation, variables, precision, operationTimes)
+    if report.Status == "cancelled" {
+        return
+    }
+    reportStatus(report)
+}
+
+// pullTasks забирает подзадачи у оркестратора, пока у сервера есть свободные горутины.
+// Запрос к оркестратору ждет появления подзадачи, поэтому свободный калькулятор получает ее сразу.
+func pullTasks() {
+    client := &http.Client{Timeout: (taskWait + 10) * time.Second}
+
+    for {
+        select {
+        case <-shutdownCh:
+            return
+        default:
+        }
+
+        // Резервирование горутины до запроса, чтобы не взять подзадачу без свободной мощности
+        if !acquireGoroutine() {
+            time.Sleep(time.Second)
+            continue
+        }
+
+        task, err := fetchTask(client)
+        if err != nil {
+            releaseGoroutine()
+            log.Printf("Error fetching task from orchestrator: %v", err)
+            time.Sleep(retryInterval)
+            continue
+        }
+        if task == nil {
+            releaseGoroutine() // Готовых подзадач не появилось, запрашиваем снова
+            continue
+        }
+
+        go func() {
+            defer releaseGoroutine()
+            runTask(task.ID, task.TaskID, task.Operation, task.Variables, task.Precision, ConvertOperationTimes(task.Times))
+        }()
+    }
+}
+
+// acquireGoroutine занимает одну горутину, если сервер работает и не достиг максимальной загрузки.
+func acquireGoroutine() bool {
+    mu.Lock()
+    defer mu.Unlock()
+    if !serverRunning || currentGoroutines >= maxGoroutines {
+        return false
+    }
+    currentGoroutines++
+    return true
+}
+
+// releaseGoroutine освобождает горутину, занятую acquireGoroutine.
+func releaseGoroutine() {
+    mu.Lock()
+    currentGoroutines--
+    mu.Unlock()
+}
+
+// fetchTask запрашивает у оркестратора одну подзадачу. Возвращает nil, если подзадач нет.
+func fetchTask(client *http.Client) (*models.TaskAssignment, error) {
+    req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/internal/task?agent=%s&wait=%d", orchestratorURL, url.QueryEscape(agentName), taskWait), nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("X-Agent-Token", agentToken)
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    switch resp.StatusCode {
+    case http.StatusOK:
+        var task models.TaskAssignment
+        if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+            return nil, fmt.Errorf("decoding task: %w", err)
+        }
+        return &task, nil
+    case http.StatusNoContent:
+        return nil, nil
+    default:
+        return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+    }
+}
+
+// executeTask выполняет вычисление или подзадачу со значениями переменных variables и возвращает итоговый статус для отправки оркестратору.
+// С точностью precision результат вычисляется в ее точном режиме и передается точной записью вместе с приближенным.
+// Ошибка вычисления передается вместе со статусом 'error', а не как нулевой результат,
+// отмена контекста завершает вычисление со статусом 'cancelled'.
+func executeTask(ctx context.Context, id int, taskID int, operation string, variables map[string]float64, precision *calculation.Precision, operationTimes calculation.OperationTimes) *pb.StatusReport {
+    report := &pb.StatusReport{Id: int32(id), TaskId: int32(taskID), Agent: agentName}
+
+    // Операции выводятся и передаются наблюдателям WatchCalculation по мере выполнения
+    var (
+        result float64
+        exact  string
+        err    error
+    )
+    if precision != nil {
+        _, exact, err = calculation.EvaluateExact(ctx, operation, variables, *precision, operationTimes, publishStep(id, taskID))
+        result = calculation.ApproximateExact(exact)
+    } else {
+        _, result, err = calculation.EvaluateWithVariables(ctx, operation, variables, operationTimes, publishStep(id, taskID))
+    }
+    if errors.Is(err, context.Canceled) {
+        fmt.Printf("Calculation ID %d (task ID %d) cancelled\n", id, taskID)
+        report.Status = "cancelled"
+        return report
+    }
+    if err != nil {
+        fmt.Printf("Calculation ID %d (task ID %d) failed: %v\n", id, taskID, err)
+        report.Status = "error"
+        report.Error = err.Error()
+        return report
+    }
+    fmt.Printf("Calculation ID %d (task ID %d) completed. Result: %.6f\n", id, taskID, result)
+    report.Status = "completed"
+    report.Result = result
+    report.ExactResult = exact
+    return report
+}
+
+// reportStatus отправляет статус оркестратору, повторяя попытку при недоступности.
+// Если отправить результат так и не удалось, оркестратор вернет подзадачу в очередь по таймауту.
+func reportStatus(report *pb.StatusReport) {
+    if orchestratorClient == nil {
+        log.Printf("Orchestrator client is not connected, status %q of calculation ID %d dropped", report.Status, report.Id)
+        return
+    }
+
+    for attempt := 1; attempt <= reportAttempts; attempt++ {
+        ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+        _, err := orchestratorClient.ReportStatus(ctx, report)
+        cancel()
+        if err == nil {
+            return
+        }
+
+        switch status.Code(err) {
+        case codes.FailedPrecondition, codes.InvalidArgument, codes.PermissionDenied:
+            // Оркестратор отклонил статус, повтор не поможет
+            log.Printf("Orchestrator rejected status %q of calculation ID %d: %v", report.Status, report.Id, err)
+            return
+        }
+        log.Printf("Error reporting status %q of calculation ID %d (attempt %d of %d): %v", report.Status, report.Id, attempt, reportAttempts, err)
+        if attempt < reportAttempts {
+            time.Sleep(time.Duration(attempt) * time.Second)
+        }
+    }
+}
+
+// convertPrecision преобразует точность из запроса gRPC; nil означает вычисление в float64.
+// Масштаб передается только десятичному режиму, в рациональном он не используется.
+func convertPrecision(precision *pb.Precision) *calculation.Precision {
+	if precision == nil {
+		return nil
+	}
+	if precision.Mode == calculation.ModeRational {
+		return &calculation.Precision{Mode: precision.Mode, Rounding: precision.Rounding}
+	}
+	converted := calculation.Decimal(int(precision.Scale), precision.Rounding)
+	converted.Mode = precision.Mode
+	return &converted
+}
+
+func convertToIntMap(input map[string]int32) map[string]int {
+	output := make(map[string]int)
+	for key, value := range input {
+		output[key] = int(value)
+	}
+	return output
+}
+
+func (s *server) PerformCalculation(ctx context.Context, req *pb.CalculationRequest) (*pb.CalculationResponse, error) {
+    // Повтор вызова для подзадачи, которая уже выполняется, подтверждается без второго запуска
+    if !acceptedTasks.accept(int(req.Id), int(req.TaskId)) {
+        fmt.Printf("Calculation ID %d (task ID %d) is already running, duplicate request acknowledged\n", req.Id, req.TaskId)
+        return &pb.CalculationResponse{Id: req.Id}, nil
+    }
+
+    // Lock the mutex to ensure thread safety
+    mu.Lock()
+
+    // Check if the server is shutting down
+    if !serverRunning {
+        mu.Unlock()
+        acceptedTasks.release(int(req.Id), int(req.TaskId))
+        return nil, status.Error(codes.Unavailable, "Server is shutting down")
+    }
+
+    // Check if the server has reached its maximum capacity
+    if currentGoroutines >= maxGoroutines {
+        mu.Unlock()
+        acceptedTasks.release(int(req.Id), int(req.TaskId))
+        return nil, status.Error(codes.ResourceExhausted, "Server max capacity reached")
+    }
+
+    // Increment the number of current goroutines
+    currentGoroutines++
+
+    // Unlock the mutex before starting the calculation
+    mu.Unlock()
+
+    // Start the calculation
+    startCalculation(int(req.Id), int(req.TaskId), req.Operation, req.Variables, convertPrecision(req.Precision), convertToIntMap(req.Times))
+
+    // Return the calculation response
+    return &pb.CalculationResponse{Id: req.Id}, nil
+}
+// CancelCalculation прерывает выполняющиеся на калькуляторе задания вычисления.
+// Вычисление останавливается не позже чем через одну операцию.
+func (s *server) CancelCalculation(ctx context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error) {
+    cancelled := runningJobs.cancel(int(req.Id))
+    if cancelled > 0 {
+        fmt.Printf("Calculation ID %d cancelled, %d running tasks interrupted\n", req.Id, cancelled)
+    }
+    return &pb.CancelResponse{Id: req.Id, Cancelled: int32(cancelled)}, nil
+}
+
+// CheckStatus возвращает состояние калькулятора и его загрузку.
+func (s *server) CheckStatus(ctx context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
+    mu.Lock()
+    defer mu.Unlock()
+    return &pb.StatusResponse{
+        Running:           serverRunning,
+        MaxGoroutines:     int32(maxGoroutines),
+        CurrentGoroutines: int32(currentGoroutines),
+    }, nil
+}
+
+// beginShutdown прекращает прием новых вычислений; процесс завершается, когда закончатся текущие.
+func beginShutdown() {
+    shutdownOnce.Do(func() {
+        mu.Lock()
+        serverRunning = false
+        mu.Unlock()
+        healthServer.Shutdown() // Проверки здоровья сообщают NOT_SERVING
+        close(shutdownCh)
+    })
+}
+
+// tokenCredentials передает общий секрет agentToken в метаданных каждого вызова gRPC оркестратора.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+    return map[string]string{"x-agent-token": string(t)}, nil
+}
+
+// RequireTransportSecurity разрешает передавать секрет без TLS, как и заголовок X-Agent-Token по HTTP.
+func (tokenCredentials) RequireTransportSecurity() bool {
+    return false
+}
+
+// Основная функция сервера
+func main() {
+    // Чтение параметров калькулятора из переменных окружения и флагов
+    if err := parseConfig(os.Args[1:]); err != nil {
+        log.Fatalf("invalid configuration: %v", err)
+    }
+
+    // Подключение к оркестратору для отправки статусов вычислений
+    conn, err := grpc.Dial(orchestratorGRPC,
+        grpc.WithTransportCredentials(insecure.NewCredentials()),
+        grpc.WithPerRPCCredentials(tokenCredentials(agentToken)),
+    )
+    if err != nil {
+        log.Fatalf("failed to connect to orchestrator: %v", err)
+    }
+    defer conn.Close()
+    orchestratorClient = pb.NewOrchestratorServiceClient(conn)
+
+    // Start gRPC server
+	lis, err := net.Listen("tcp", port)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	// Оркестратор держит с калькулятором долгоживущее соединение и проверяет его ping каждые 30 секунд
+	grpcServer := grpc.NewServer(grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             10 * time.Second,
+		PermitWithoutStream: true,
+	}))
+	pb.RegisterCalculatorServiceServer(grpcServer, &server{})
+	healthServer.SetServingStatus(pb.CalculatorService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	fmt.Printf("gRPC server is starting on port %s...\n", port)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+	
+    // Регистрация в реестре оркестратора и отправка heartbeat
+    go maintainRegistration()
+
+    // Получение подзадач у оркестратора по мере освобождения горутин
+    go pullTasks()
+
+    // Обработчик запроса на выполнение вычисления
+    http.HandleFunc("/calculate", func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != "POST" {
+            http.Error(w, "Method is not supported.", http.StatusNotFound)
+            return
+        }
+
+        // Проверка на возможность обработки нового запроса
+        mu.Lock()
+        if !serverRunning {
+            http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+            mu.Unlock()
+            return
+        }
+        if currentGoroutines >= maxGoroutines {
+            http.Error(w, "Server max capacity reached", http.StatusTooManyRequests)
+            mu.Unlock()
+            return
+        }
+        currentGoroutines++
+        mu.Unlock()
+
+        // Разбор запроса
+        var request OperationRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if !acceptedTasks.accept(request.ID, request.TaskID) {
+            releaseGoroutine()
+            w.WriteHeader(http.StatusAccepted)
+            fmt.Fprintln(w, "Calculation is already running.")
+            return
+        }
+
+        // Запуск вычисления
+		startCalculation(request.ID, request.TaskID, request.Operation, request.Variables, request.Precision, request.Times)
+        w.WriteHeader(http.StatusAccepted)
+        fmt.Fprintln(w, "Calculation started successfully.")
+    })
+
+    // Обработчик запроса на получение текущего количества горутин
+    http.HandleFunc("/goroutines", func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        fmt.Fprintf(w, "Current number of goroutines: %d\n", currentGoroutines)
+        mu.Unlock()
+    })
+
+    // Обработчик запроса на остановку сервера
+    http.HandleFunc("/shutdown", func(w http.ResponseWriter, r *http.Request) {
+        beginShutdown()
+        fmt.Fprintln(w, "Server is shutting down...")
+    })
+
+    // Остановка по сигналу доступна и без HTTP сервера
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+    go func() {
+        <-signals
+        beginShutdown()
+    }()
+
+    // Обработчик запроса на проверку состояния сервера
+    http.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		response := struct {
+			Status           string `json:"status"`
+			MaxGoroutines    int    `json:"maxGoroutines"`
+			CurrentGoroutines int   `json:"currentGoroutines"`
+		}{
+			Status:           "running",
+			MaxGoroutines:    maxGoroutines,
+			CurrentGoroutines: currentGoroutines,
+		}
+	
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+
+    // Горутина для ожидания остановки сервера и завершения всех операций
+    go func() {
+        <-shutdownCh
+        fmt.Println("Server stopped accepting new requests. Waiting for ongoing operations to complete...")
+        for {
+            mu.Lock()
+            if currentGoroutines == 0 {
+                mu.Unlock()
+                break
+            }
+            mu.Unlock()
+            time.Sleep(1 * time.Second)
+        }
+        log.Fatal("Server gracefully shut down")
+    }()
+
+    // Без HTTP сервера калькулятор работает только через gRPC и завершается горутиной остановки
+    if httpPort == "" {
+        fmt.Println("HTTP server is disabled, serving gRPC only")
+        select {}
+    }
+
+    // Запуск сервера на порту
+    fmt.Printf("Calculator server is starting on port %s...\n", httpPort)
+    log.Fatal(http.ListenAndServe(httpPort, nil))
+}
\ No newline at end of file
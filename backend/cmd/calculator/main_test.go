@@ -2,6 +2,7 @@ package main
 
 import (
     "bytes"
+    "context"
     "encoding/json"
     "net/http"
     "net/http/httptest"
@@ -10,7 +11,7 @@ import (
 	"time"
 	"sync"
     
-    "github.com/DATA-DOG/go-sqlmock"
+	pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
 	"calculatorapi/utility/calculation"
 )
 
@@ -28,12 +29,21 @@ func TestConvertOperationTimes(t *testing.T) {
                 "subtract_duration": 20,
                 "multiply_duration": 30,
                 "divide_duration":   40,
+                "power_duration":    50,
+                "modulo_duration":   60,
+                "int_divide_duration": 70,
+                "sqrt_duration":     80,
+                "unknown_duration":  90,
             },
             expected: calculation.OperationTimes{
                 "+": 10 * time.Second,
                 "-": 20 * time.Second,
                 "*": 30 * time.Second,
                 "/": 40 * time.Second,
+                "^": 50 * time.Second,
+                "%": 60 * time.Second,
+                "//": 70 * time.Second,
+                "sqrt": 80 * time.Second,
             },
         },
         {
@@ -87,19 +97,7 @@ func setupTestRoutes() {
             return
         }
 
-        db, mock, err := sqlmock.New()
-        if err != nil {
-            http.Error(w, "Database error", http.StatusInternalServerError)
-            return
-        }
-        defer db.Close()
-
-        mock.ExpectBegin()
-        mock.ExpectExec("UPDATE calculations SET status = ? WHERE id = ?").WithArgs("work", request.ID).WillReturnResult(sqlmock.NewResult(1, 1))
-        mock.ExpectExec("UPDATE calculations SET result = ?, status = ? WHERE id = ?").WithArgs(7.0, "completed", request.ID).WillReturnResult(sqlmock.NewResult(1, 1))
-        mock.ExpectCommit()
-
-        startCalculation(db, request.ID, request.Operation, request.Times) // Запуск расчета
+        startCalculation(request.ID, request.TaskID, request.Operation, request.Variables, request.Precision, request.Times) // Запуск расчета
         w.WriteHeader(http.StatusAccepted)
         fmt.Fprintln(w, "Calculation started successfully.")
     })
@@ -237,4 +235,167 @@ func TestShutdownEndpoint(t *testing.T) {
     default:
         t.Error("shutdown channel was not closed")
     }
-}
\ No newline at end of file
+}
+
+// Проверка статуса, который калькулятор отправляет оркестратору после вычисления
+func TestExecuteTask(t *testing.T) {
+    report := executeTask(context.Background(), 1, 2, "2 + 3", nil, nil, calculation.OperationTimes{})
+    if report.Status != "completed" || report.Result != 5 || report.Id != 1 || report.TaskId != 2 {
+        t.Errorf("unexpected report for successful task: %+v", report)
+    }
+
+    report = executeTask(context.Background(), 1, 3, "1 / 0", nil, nil, calculation.OperationTimes{})
+    if report.Status != "error" || report.Error == "" {
+        t.Errorf("expected error report for division by zero, got %+v", report)
+    }
+
+    // В десятичном режиме результат передается точной записью вместе с приближенным
+    precision := calculation.Decimal(10, "")
+    report = executeTask(context.Background(), 1, 4, "0.1 + 0.2", nil, &precision, calculation.OperationTimes{})
+    if report.Status != "completed" || report.ExactResult != "0.3" || report.Result != 0.3 {
+        t.Errorf("unexpected report for exact task: %+v", report)
+    }
+
+    // В рациональном режиме результат передается дробью, а приближенный - ближайшим float64
+    report = executeTask(context.Background(), 1, 5, "[10/3] + 2 ^ 70", nil, &calculation.Precision{Mode: calculation.ModeRational}, calculation.OperationTimes{})
+    if report.Status != "completed" || report.ExactResult != "3541774862152233910282/3" || report.Result != 10.0/3+1180591620717411303424 {
+        t.Errorf("unexpected report for rational task: %+v", report)
+    }
+}
+
+// Отмена задания через CancelCalculation прерывает вычисление со статусом 'cancelled'
+func TestCancelCalculation(t *testing.T) {
+    ctx, done := runningJobs.start(7)
+    defer done()
+
+    reports := make(chan string, 1)
+    go func() {
+        reports <- executeTask(ctx, 7, 1, "2 + 3", nil, nil, calculation.OperationTimes{"+": time.Minute}).Status
+    }()
+
+    response, err := (&server{}).CancelCalculation(context.Background(), &pb.CancelRequest{Id: 7})
+    if err != nil || response.Cancelled != 1 {
+        t.Fatalf("expected one cancelled task, got %+v, %v", response, err)
+    }
+    select {
+    case status := <-reports:
+        if status != "cancelled" {
+            t.Errorf("expected cancelled status, got %q", status)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("calculation was not interrupted")
+    }
+
+    if response, _ := (&server{}).CancelCalculation(context.Background(), &pb.CancelRequest{Id: 8}); response.Cancelled != 0 {
+        t.Errorf("expected no tasks for unknown calculation, got %d", response.Cancelled)
+    }
+}
+
+// Повтор PerformCalculation для выполняющейся подзадачи подтверждается без второго запуска
+func TestPerformCalculationDuplicate(t *testing.T) {
+    mu.Lock()
+    currentGoroutines, maxGoroutines, serverRunning = 0, 1, true
+    mu.Unlock()
+    defer func() {
+        mu.Lock()
+        maxGoroutines = 5
+        mu.Unlock()
+    }()
+
+    req := &pb.CalculationRequest{Id: 9, TaskId: 3, Operation: "2 + 3", Times: map[string]int32{"add_duration": 60}}
+    for i := 0; i < 2; i++ {
+        if _, err := (&server{}).PerformCalculation(context.Background(), req); err != nil {
+            t.Fatalf("call %d returned error: %v", i+1, err)
+        }
+    }
+    mu.Lock()
+    running := currentGoroutines
+    mu.Unlock()
+    if running != 1 {
+        t.Errorf("expected the task to run once, got %d goroutines", running)
+    }
+
+    // Задание регистрируется в горутине, поэтому отмена повторяется до его появления
+    for deadline := time.Now().Add(time.Second); runningJobs.cancel(9) == 0; {
+        if time.Now().After(deadline) {
+            t.Fatal("task was not started")
+        }
+        time.Sleep(time.Millisecond)
+    }
+    for deadline := time.Now().Add(5 * time.Second); !acceptedTasks.accept(9, 3); {
+        if time.Now().After(deadline) {
+            t.Fatal("task was not released after cancellation")
+        }
+        time.Sleep(time.Millisecond)
+    }
+    acceptedTasks.release(9, 3)
+}
+
+// fakeWatchStream собирает шаги, отправленные WatchCalculation
+type fakeWatchStream struct {
+    pb.CalculatorService_WatchCalculationServer
+    ctx   context.Context
+    steps chan *pb.CalculationStep
+}
+
+func (s *fakeWatchStream) Context() context.Context { return s.ctx }
+
+func (s *fakeWatchStream) Send(step *pb.CalculationStep) error {
+    s.steps <- step
+    return nil
+}
+
+// WatchCalculation передает операции наблюдаемого вычисления по мере выполнения
+func TestWatchCalculation(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    stream := &fakeWatchStream{ctx: ctx, steps: make(chan *pb.CalculationStep, 10)}
+    done := make(chan error)
+    go func() { done <- (&server{}).WatchCalculation(&pb.WatchRequest{Id: 5}, stream) }()
+
+    // Наблюдатель подписывается асинхронно, поэтому операции выполняются после его появления
+    for deadline := time.Now().Add(time.Second); ; {
+        stepWatchers.mu.Lock()
+        watching := len(stepWatchers.watchers) > 0
+        stepWatchers.mu.Unlock()
+        if watching || time.Now().After(deadline) {
+            break
+        }
+        time.Sleep(time.Millisecond)
+    }
+    executeTask(context.Background(), 6, 1, "1 + 1", nil, nil, calculation.OperationTimes{})
+    precision := calculation.Decimal(2, "")
+    executeTask(context.Background(), 5, 2, "2 * 3", nil, &precision, calculation.OperationTimes{})
+
+    select {
+    case step := <-stream.steps:
+        if step.Id != 5 || step.TaskId != 2 || step.Left != 2 || step.Operator != "*" || step.Right != 3 || step.Result != 6 || step.Exact != "6" {
+            t.Errorf("unexpected step: %+v", step)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("step was not sent")
+    }
+    if len(stream.steps) != 0 {
+        t.Errorf("steps of another calculation were sent")
+    }
+
+    cancel()
+    if err := <-done; err != nil {
+        t.Errorf("WatchCalculation returned error: %v", err)
+    }
+}
+
+// CheckStatus сообщает загрузку калькулятора
+func TestCheckStatus(t *testing.T) {
+    mu.Lock()
+    currentGoroutines, maxGoroutines, serverRunning = 2, 5, true
+    mu.Unlock()
+
+    status, err := (&server{}).CheckStatus(context.Background(), &pb.StatusRequest{})
+    if err != nil || !status.Running || status.CurrentGoroutines != 2 || status.MaxGoroutines != 5 {
+        t.Errorf("unexpected status: %+v, %v", status, err)
+    }
+
+    mu.Lock()
+    currentGoroutines = 0
+    mu.Unlock()
+}
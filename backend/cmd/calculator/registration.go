@@ -0,0 +1,84 @@
+package main
+
+import (
+    "context" // Для ограничения времени запросов к оркестратору
+    "log"     // Для логирования
+    "time"    // Для работы со временем
+
+    pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+)
+
+// Интервал отправки heartbeat оркестратору; оркестратор удаляет калькулятор после нескольких пропусков
+const heartbeatInterval = 5 * time.Second
+
+// maintainRegistration регистрирует калькулятор в реестре оркестратора и затем периодически присылает heartbeat
+// с текущей загрузкой. Если оркестратор забыл калькулятор (например, после перезапуска), регистрация повторяется.
+func maintainRegistration() {
+    ticker := time.NewTicker(heartbeatInterval)
+    defer ticker.Stop()
+
+    registered := false
+    for {
+        if registered {
+            registered = sendHeartbeat()
+        } else {
+            registered = registerAgent()
+        }
+
+        select {
+        case <-ticker.C:
+        case <-shutdownCh:
+            return
+        }
+    }
+}
+
+// loadSnapshot возвращает текущее и максимальное количество горутин.
+func loadSnapshot() (int32, int32) {
+    mu.Lock()
+    defer mu.Unlock()
+    return int32(currentGoroutines), int32(maxGoroutines)
+}
+
+// registerAgent регистрирует калькулятор у оркестратора. Возвращает true при успехе.
+func registerAgent() bool {
+    current, max := loadSnapshot()
+    ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+    defer cancel()
+
+    // Без HTTP сервера калькулятор доступен оркестратору только по gRPC
+    httpAddress := ""
+    if httpPort != "" {
+        httpAddress = "http://" + advertisedAddress(httpPort)
+    }
+
+    _, err := orchestratorClient.RegisterAgent(ctx, &pb.AgentRegistration{
+        Name:              agentName,
+        HttpAddress:       httpAddress,
+        GrpcAddress:       advertisedAddress(port),
+        MaxGoroutines:     max,
+        CurrentGoroutines: current,
+    })
+    if err != nil {
+        log.Printf("Error registering agent %q with orchestrator: %v", agentName, err)
+        return false
+    }
+    log.Printf("Agent %q registered with orchestrator", agentName)
+    return true
+}
+
+// sendHeartbeat сообщает оркестратору, что калькулятор работает.
+// Возвращает false, если оркестратор не знает калькулятор и его нужно зарегистрировать снова.
+func sendHeartbeat() bool {
+    current, max := loadSnapshot()
+    ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+    defer cancel()
+
+    ack, err := orchestratorClient.Heartbeat(ctx, &pb.AgentHeartbeat{Name: agentName, MaxGoroutines: max, CurrentGoroutines: current})
+    if err != nil {
+        // Оркестратор временно недоступен: если он успеет удалить калькулятор, следующий heartbeat это покажет
+        log.Printf("Error sending heartbeat to orchestrator: %v", err)
+        return true
+    }
+    return ack.Registered
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+    "fmt"  // Для вывода выполненных операций
+    "log"  // Для логирования
+    "sync" // Синхронизация доступа к наблюдателям
+
+    pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+    "calculatorapi/utility/calculation" // Шаги вычисления
+)
+
+// Размер очереди шагов одного наблюдателя; шаги, которые наблюдатель не успевает прочитать, отбрасываются
+const watchBufferSize = 64
+
+// stepBroadcaster рассылает выполненные операции наблюдателям WatchCalculation.
+type stepBroadcaster struct {
+    mu       sync.Mutex
+    watchers map[chan *pb.CalculationStep]int // Канал наблюдателя -> идентификатор вычисления, 0 для всех
+}
+
+// Наблюдатели за операциями, выполняемыми на калькуляторе
+var stepWatchers = &stepBroadcaster{watchers: map[chan *pb.CalculationStep]int{}}
+
+// watch подписывает на операции вычисления id (0 - всех вычислений) и возвращает канал и функцию отписки.
+func (b *stepBroadcaster) watch(id int) (<-chan *pb.CalculationStep, func()) {
+    ch := make(chan *pb.CalculationStep, watchBufferSize)
+    b.mu.Lock()
+    b.watchers[ch] = id
+    b.mu.Unlock()
+
+    return ch, func() {
+        b.mu.Lock()
+        delete(b.watchers, ch)
+        b.mu.Unlock()
+    }
+}
+
+// publish передает шаг наблюдателям его вычисления, не дожидаясь их.
+func (b *stepBroadcaster) publish(step *pb.CalculationStep) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for ch, id := range b.watchers {
+        if id != 0 && id != int(step.Id) {
+            continue
+        }
+        select {
+        case ch <- step:
+        default:
+            log.Printf("Watcher of calculation ID %d is too slow, step dropped", id)
+        }
+    }
+}
+
+// publishStep возвращает функцию, которая сообщает наблюдателям об операциях подзадачи taskID вычисления id.
+func publishStep(id, taskID int) calculation.StepFunc {
+    return func(step calculation.Step) {
+        fmt.Println(step)
+        stepWatchers.publish(&pb.CalculationStep{
+            Id:        int32(id),
+            TaskId:    int32(taskID),
+            Left:      step.Left,
+            Operator:  step.Operator,
+            Right:     step.Right,
+            Args:      step.Args,
+            Result:    step.Result,
+            Exact:     step.Exact,
+            ElapsedMs: step.Elapsed.Milliseconds(),
+            Agent:     agentName,
+        })
+    }
+}
+
+// WatchCalculation передает операции вычисления по мере их выполнения на этом калькуляторе.
+// С id 0 передаются операции всех вычислений. Поток завершается, когда клиент его закрывает
+// или калькулятор останавливается.
+func (s *server) WatchCalculation(req *pb.WatchRequest, stream pb.CalculatorService_WatchCalculationServer) error {
+    steps, stop := stepWatchers.watch(int(req.Id))
+    defer stop()
+
+    for {
+        select {
+        case step := <-steps:
+            if err := stream.Send(step); err != nil {
+                return err
+            }
+        case <-stream.Context().Done():
+            return nil
+        case <-shutdownCh:
+            return nil
+        }
+    }
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql"  // Для ошибки sql.ErrNoRows
+	"encoding/json" // Для кодирования ответа
+	"errors"        // Для проверки ошибок базы данных
+	"log"           // Для логирования
+	"net/http"      // Для работы с HTTP
+	"strconv"       // Для разбора идентификатора вычисления
+
+	"calculatorapi/utility/database" // Пакет для работы с базой данных
+)
+
+// handleFailedCalculations возвращает вычисления всех пользователей, исчерпавшие попытки выполнения.
+func handleFailedCalculations(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		calculations, err := store.FetchFailedCalculations()
+		if err != nil {
+			log.Printf("Error fetching failed calculations: %v", err)
+			sendJSONError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(calculations)
+	}
+}
+
+// handleRequeueCalculation возвращает вычисление из статуса 'failed' в очередь со сброшенным счетчиком попыток.
+func handleRequeueCalculation(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			sendJSONError(w, "Invalid calculation id", http.StatusBadRequest)
+			return
+		}
+
+		err = store.RequeueCalculation(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			sendJSONError(w, "Calculation not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, database.ErrCalculationNotFailed) {
+			sendJSONError(w, "Calculation is not failed", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Error requeueing calculation %d: %v", id, err)
+			sendJSONError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Calculation ID %d requeued by %v", id, r.Context().Value(loginContextKey))
+		tasksChanged.notify()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "status": "created"})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "calculatorapi/utility/database"
+    "calculatorapi/utility/models"
+)
+
+// adminRequest вызывает эндпоинт администрирования с токеном пользователя "user".
+func adminRequest(t *testing.T, store database.Store, method, path string) *httptest.ResponseRecorder {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/v1/admin/calculations/failed", requireAdmin(handleFailedCalculations(store)))
+    mux.HandleFunc("/api/v1/admin/calculations/{id}/requeue", requireAdmin(handleRequeueCalculation(store)))
+
+    req := httptest.NewRequest(method, path, nil)
+    req.Header.Set("Authorization", "Bearer "+signTestToken(t, 1, jwtKey, time.Now().Add(time.Hour)))
+    rr := httptest.NewRecorder()
+    mux.ServeHTTP(rr, req)
+    return rr
+}
+
+func TestAdminFailedCalculations(t *testing.T) {
+    store := database.NewMemoryStore()
+    id, _ := store.InsertCalculation(1, "2*3", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    if err := planCalculation(store, id, "2*3", nil, nil); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+    task, _ := store.ClaimTask("orchestrator-a", "calculator1", time.Minute)
+    if failed, err := store.RetryTask(task.TaskID, "agent crashed", database.RetryPolicy{MaxAttempts: 1}); !failed || err != nil {
+        t.Fatalf("Expected the calculation to fail, got %v, %v", failed, err)
+    }
+
+    // Пользователь не из списка администраторов получает 403
+    adminLogins = nil
+    if rr := adminRequest(t, store, http.MethodGet, "/api/v1/admin/calculations/failed"); rr.Code != http.StatusForbidden {
+        t.Errorf("Expected status %d for a non-admin user, got %d", http.StatusForbidden, rr.Code)
+    }
+
+    adminLogins = []string{"user"}
+    defer func() { adminLogins = nil }()
+    rr := adminRequest(t, store, http.MethodGet, "/api/v1/admin/calculations/failed")
+    var failed []models.FailedCalculation
+    if err := json.NewDecoder(rr.Body).Decode(&failed); err != nil || rr.Code != http.StatusOK {
+        t.Fatalf("Expected a list of failed calculations, got %d: %v", rr.Code, err)
+    }
+    if len(failed) != 1 || failed[0].ID != id || failed[0].Attempts != 1 || failed[0].Error != "agent crashed" {
+        t.Errorf("Unexpected failed calculations: %+v", failed)
+    }
+
+    if rr := adminRequest(t, store, http.MethodPost, "/api/v1/admin/calculations/1/requeue"); rr.Code != http.StatusOK {
+        t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "created" {
+        t.Errorf("Expected requeued calculation to be created, got %+v", result)
+    }
+    if rr := adminRequest(t, store, http.MethodPost, "/api/v1/admin/calculations/1/requeue"); rr.Code != http.StatusConflict {
+        t.Errorf("Expected status %d for a calculation that is not failed, got %d", http.StatusConflict, rr.Code)
+    }
+    if rr := adminRequest(t, store, http.MethodPost, "/api/v1/admin/calculations/99/requeue"); rr.Code != http.StatusNotFound {
+        t.Errorf("Expected status %d for a missing calculation, got %d", http.StatusNotFound, rr.Code)
+    }
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context" // Для работы с контекстом запросов gRPC
+	"log"     // Для логирования
+	"sort"    // Для упорядочивания списка калькуляторов
+	"sync"    // Для синхронизации доступа к реестру
+	"time"    // Для работы со временем
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+)
+
+// Время без heartbeat, после которого калькулятор удаляется из реестра; задается конфигурацией
+var heartbeatTimeout = 15 * time.Second
+
+// Калькуляторы, удаленные из реестра за пропуск heartbeat; их подзадачи проверяет checkAndRestartFailedOperations
+var lostAgents = make(chan []string, 16)
+
+// agentInfo - запись реестра о зарегистрированном калькуляторе.
+type agentInfo struct {
+	Name              string
+	HTTPAddress       string
+	GRPCAddress       string
+	MaxGoroutines     int
+	CurrentGoroutines int
+	RegisteredAt      time.Time
+	LastSeen          time.Time
+}
+
+// agentRegistry хранит калькуляторы, которые зарегистрировались у оркестратора и присылают heartbeat.
+type agentRegistry struct {
+	mu     sync.Mutex
+	agents map[string]*agentInfo
+}
+
+// Глобальный реестр калькуляторов
+var agents = newAgentRegistry()
+
+// newAgentRegistry создает пустой реестр калькуляторов.
+func newAgentRegistry() *agentRegistry {
+	return &agentRegistry{agents: make(map[string]*agentInfo)}
+}
+
+// register добавляет калькулятор в реестр или обновляет его адреса при повторной регистрации.
+func (r *agentRegistry) register(info agentInfo, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info.RegisteredAt = now
+	info.LastSeen = now
+	r.agents[info.Name] = &info
+}
+
+// heartbeat обновляет загрузку и время последнего сигнала калькулятора.
+// Возвращает false, если калькулятор не зарегистрирован.
+func (r *agentRegistry) heartbeat(name string, current, max int, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	agent, ok := r.agents[name]
+	if !ok {
+		return false
+	}
+	agent.CurrentGoroutines = current
+	agent.MaxGoroutines = max
+	agent.LastSeen = now
+	return true
+}
+
+// get возвращает копию записи реестра о калькуляторе name.
+func (r *agentRegistry) get(name string) (agentInfo, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	agent, ok := r.agents[name]
+	if !ok {
+		return agentInfo{}, false
+	}
+	return *agent, true
+}
+
+// evict удаляет калькуляторы, не присылавшие heartbeat дольше timeout, и возвращает их имена.
+func (r *agentRegistry) evict(now time.Time, timeout time.Duration) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var evicted []string
+	for name, agent := range r.agents {
+		if now.Sub(agent.LastSeen) > timeout {
+			delete(r.agents, name)
+			evicted = append(evicted, name)
+		}
+	}
+	sort.Strings(evicted)
+	return evicted
+}
+
+// list возвращает копии записей реестра, упорядоченные по имени калькулятора.
+func (r *agentRegistry) list() []agentInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]agentInfo, 0, len(r.agents))
+	for _, agent := range r.agents {
+		list = append(list, *agent)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// evictAgents периодически удаляет из реестра калькуляторы, пропустившие heartbeat.
+func evictAgents(registry *agentRegistry, shutdownCh <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			evicted := registry.evict(now, heartbeatTimeout)
+			for _, name := range evicted {
+				log.Printf("Agent %q missed heartbeats and was removed from the registry", name)
+			}
+			if len(evicted) > 0 {
+				agentConns.prune(registry.list()) // Соединения с удаленными калькуляторами больше не нужны
+				select {
+				case lostAgents <- evicted:
+				default:
+					// Проверка не успевает за удалениями; подзадачи вернутся в очередь по истечении ожидания
+					log.Printf("Lost agents queue is full, tasks of %v will be retried on timeout", evicted)
+				}
+			}
+		case <-shutdownCh:
+			return
+		}
+	}
+}
+
+// RegisterAgent добавляет калькулятор в реестр оркестратора. Вызов без общего секрета калькуляторов
+// отклоняет agentTokenUnaryInterceptor, поэтому попасть в реестр и присылать отчеты может только калькулятор.
+func (s *orchestratorServer) RegisterAgent(ctx context.Context, req *pb.AgentRegistration) (*pb.AgentRegistrationAck, error) {
+	if req.Name == "" || req.GrpcAddress == "" {
+		return nil, status.Error(codes.InvalidArgument, "agent name and gRPC address are required")
+	}
+
+	agents.register(agentInfo{
+		Name:              req.Name,
+		HTTPAddress:       req.HttpAddress,
+		GRPCAddress:       req.GrpcAddress,
+		MaxGoroutines:     int(req.MaxGoroutines),
+		CurrentGoroutines: int(req.CurrentGoroutines),
+	}, time.Now())
+	log.Printf("Agent %q registered with gRPC address %s", req.Name, req.GrpcAddress)
+
+	// Операции, выполняемые калькулятором, передаются подписчикам потока событий
+	agentSteps.start(s.store, req.Name)
+
+	return &pb.AgentRegistrationAck{HeartbeatTimeout: int32(heartbeatTimeout / time.Second)}, nil
+}
+
+// Heartbeat подтверждает, что калькулятор работает, и обновляет его загрузку.
+func (s *orchestratorServer) Heartbeat(ctx context.Context, req *pb.AgentHeartbeat) (*pb.AgentHeartbeatAck, error) {
+	registered := agents.heartbeat(req.Name, int(req.CurrentGoroutines), int(req.MaxGoroutines), time.Now())
+	return &pb.AgentHeartbeatAck{Registered: registered}, nil
+}
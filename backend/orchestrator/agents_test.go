@@ -0,0 +1,83 @@
+package main
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "calculatorapi/utility/database"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+)
+
+func TestAgentRegistryEviction(t *testing.T) {
+    registry := newAgentRegistry()
+    start := time.Now()
+    registry.register(agentInfo{Name: "calculator1", GRPCAddress: "localhost:50051"}, start)
+    registry.register(agentInfo{Name: "calculator2", GRPCAddress: "localhost:50052"}, start)
+
+    // Только первый калькулятор продолжает присылать heartbeat
+    if !registry.heartbeat("calculator1", 1, 5, start.Add(10*time.Second)) {
+        t.Fatal("Expected heartbeat of a registered agent to be accepted")
+    }
+    if registry.heartbeat("calculator3", 0, 5, start) {
+        t.Error("Expected heartbeat of an unknown agent to be rejected")
+    }
+
+    evicted := registry.evict(start.Add(heartbeatTimeout+time.Second), heartbeatTimeout)
+    if len(evicted) != 1 || evicted[0] != "calculator2" {
+        t.Errorf("Expected calculator2 to be evicted, got %v", evicted)
+    }
+
+    list := registry.list()
+    if len(list) != 1 || list[0].Name != "calculator1" || list[0].CurrentGoroutines != 1 {
+        t.Errorf("Unexpected registry contents: %+v", list)
+    }
+}
+
+func TestRegisterAgentRPC(t *testing.T) {
+    agents = newAgentRegistry()
+    server := &orchestratorServer{}
+
+    if _, err := server.RegisterAgent(context.Background(), &pb.AgentRegistration{Name: "calculator1"}); err == nil {
+        t.Error("Expected registration without gRPC address to fail")
+    }
+
+    ack, err := server.RegisterAgent(context.Background(), &pb.AgentRegistration{Name: "calculator1", GrpcAddress: "localhost:50051", MaxGoroutines: 5})
+    if err != nil {
+        t.Fatalf("RegisterAgent returned error: %v", err)
+    }
+    if ack.HeartbeatTimeout != int32(heartbeatTimeout/time.Second) {
+        t.Errorf("Unexpected heartbeat timeout %d", ack.HeartbeatTimeout)
+    }
+
+    hb, err := server.Heartbeat(context.Background(), &pb.AgentHeartbeat{Name: "calculator1", MaxGoroutines: 5, CurrentGoroutines: 2})
+    if err != nil || !hb.Registered {
+        t.Errorf("Expected heartbeat to be accepted, got %v, %v", hb, err)
+    }
+}
+
+func TestRegisterAgentAgentToken(t *testing.T) {
+    agents = newAgentRegistry()
+    defer func() { agents = newAgentRegistry() }()
+    client := serveOrchestrator(t, database.NewMemoryStore())
+
+    // Без общего секрета нельзя ни зарегистрироваться, ни продлить регистрацию
+    registration := &pb.AgentRegistration{Name: "impostor", GrpcAddress: "localhost:50051", MaxGoroutines: 5}
+    if _, err := client.RegisterAgent(context.Background(), registration); status.Code(err) != codes.Unauthenticated {
+        t.Errorf("Expected Unauthenticated registration, got %v", err)
+    }
+    if _, err := client.Heartbeat(context.Background(), &pb.AgentHeartbeat{Name: "impostor"}); status.Code(err) != codes.Unauthenticated {
+        t.Errorf("Expected Unauthenticated heartbeat, got %v", err)
+    }
+    if list := agents.list(); len(list) != 0 {
+        t.Errorf("Expected an empty registry, got %+v", list)
+    }
+
+    ctx := metadata.AppendToOutgoingContext(context.Background(), agentTokenMetadata, string(agentToken))
+    if hb, err := client.Heartbeat(ctx, &pb.AgentHeartbeat{Name: "impostor"}); err != nil || hb.Registered {
+        t.Errorf("Expected the heartbeat of an unregistered agent to pass the token check, got %v, %v", hb, err)
+    }
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"       // Для передачи ID пользователя через контекст запроса
+	"crypto/subtle" // Для сравнения токена калькулятора за постоянное время
+	"fmt"           // Для форматирования ошибок
+	"net/http"      // Для работы с HTTP
+	"strings"       // Для разбора заголовка Authorization
+
+	"github.com/golang-jwt/jwt/v4" // Для проверки токенов
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// contextKey - тип ключей контекста запроса, чтобы не пересекаться с ключами других пакетов.
+type contextKey string
+
+// Ключи контекста, под которыми requireAuth сохраняет ID и логин пользователя из токена
+const (
+	userIDContextKey contextKey = "userID"
+	loginContextKey  contextKey = "login"
+)
+
+// Логины пользователей с доступом к /api/v1/admin; задаются конфигурацией
+var adminLogins []string
+
+// requireAuth проверяет JWT из заголовка "Authorization: Bearer <token>" и передает обработчику
+// ID пользователя через контекст запроса. Запросы без действительного токена получают 401 Unauthorized.
+func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		tokenString := strings.TrimPrefix(header, "Bearer ")
+		if header == "" || tokenString == header {
+			sendJSONError(w, "Missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := parseToken(tokenString)
+		if err != nil {
+			sendJSONError(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDContextKey, claims.UserID)
+		ctx = context.WithValue(ctx, loginContextKey, claims.Login)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// requireAdmin пропускает только пользователей, чьи логины перечислены в adminLogins.
+// Запросы без действительного токена получают 401 Unauthorized, остальных пользователей - 403 Forbidden.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		login, _ := r.Context().Value(loginContextKey).(string)
+		for _, admin := range adminLogins {
+			if login != "" && login == admin {
+				next(w, r)
+				return
+			}
+		}
+		sendJSONError(w, "Admin access required", http.StatusForbidden)
+	})
+}
+
+// agentTokenHeader - заголовок, в котором калькулятор передает общий секрет agentToken.
+const agentTokenHeader = "X-Agent-Token"
+
+// requireAgentToken пропускает только запросы калькуляторов с общим секретом agentToken в заголовке X-Agent-Token.
+// Остальные запросы получают 401 Unauthorized, поэтому пользователи API не могут брать подзадачи и присылать их результаты.
+func requireAgentToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(agentTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), agentToken) != 1 {
+			http.Error(w, "Invalid agent token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// agentTokenMetadata - ключ метаданных gRPC, в котором калькулятор передает общий секрет agentToken.
+const agentTokenMetadata = "x-agent-token"
+
+// checkAgentToken проверяет общий секрет agentToken в метаданных входящего вызова gRPC.
+func checkAgentToken(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(agentTokenMetadata)
+	if len(tokens) != 1 || subtle.ConstantTimeCompare([]byte(tokens[0]), agentToken) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid agent token")
+	}
+	return nil
+}
+
+// agentTokenUnaryInterceptor пропускает к gRPC сервису оркестратора только вызовы калькуляторов с общим секретом,
+// поэтому клиент, которому доступен порт, не может присылать результаты подзадач.
+func agentTokenUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := checkAgentToken(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// agentTokenStreamInterceptor - то же для потоковых вызовов.
+func agentTokenStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := checkAgentToken(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// parseToken проверяет подпись и срок действия токена, выданного /api/v1/login.
+func parseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// Принимаются только токены, подписанные HS256, как при выдаче
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return jwtKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.UserID == 0 {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	return claims, nil
+}
+
+// userIDFromContext возвращает ID пользователя, сохраненный requireAuth.
+func userIDFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDContextKey).(int)
+	return userID, ok
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "calculatorapi/utility/database"
+    "github.com/golang-jwt/jwt/v4"
+)
+
+// signTestToken создает токен так же, как обработчик /api/v1/login.
+func signTestToken(t *testing.T, userID int, key []byte, expiresAt time.Time) string {
+    claims := &Claims{
+        Login:  "user",
+        UserID: userID,
+        StandardClaims: jwt.StandardClaims{
+            ExpiresAt: expiresAt.Unix(),
+        },
+    }
+    token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+    if err != nil {
+        t.Fatalf("signing token: %v", err)
+    }
+    return token
+}
+
+func TestRequireAuth(t *testing.T) {
+    handler := requireAuth(func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := userIDFromContext(r.Context())
+        if !ok {
+            t.Error("Expected user ID in request context")
+        }
+        fmt.Fprint(w, userID)
+    })
+
+    testCases := []struct {
+        name       string
+        header     string
+        wantStatus int
+        wantBody   string
+    }{
+        {"Valid token", "Bearer " + signTestToken(t, 42, jwtKey, time.Now().Add(time.Hour)), http.StatusOK, "42"},
+        {"Missing header", "", http.StatusUnauthorized, ""},
+        {"Not a bearer token", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, ""},
+        {"Expired token", "Bearer " + signTestToken(t, 42, jwtKey, time.Now().Add(-time.Hour)), http.StatusUnauthorized, ""},
+        {"Foreign key", "Bearer " + signTestToken(t, 42, []byte("other_key"), time.Now().Add(time.Hour)), http.StatusUnauthorized, ""},
+    }
+
+    for _, tc := range testCases {
+        t.Run(tc.name, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodGet, "/get-calculations-by-user?userId=1", nil)
+            if tc.header != "" {
+                req.Header.Set("Authorization", tc.header)
+            }
+            rr := httptest.NewRecorder()
+            handler(rr, req)
+
+            if rr.Code != tc.wantStatus {
+                t.Errorf("Expected status %d, got %d", tc.wantStatus, rr.Code)
+            }
+            if tc.wantBody != "" && rr.Body.String() != tc.wantBody {
+                t.Errorf("Expected body %q, got %q", tc.wantBody, rr.Body.String())
+            }
+        })
+    }
+}
+
+func TestRequireAgentToken(t *testing.T) {
+    handler := requireAgentToken(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNoContent)
+    })
+
+    testCases := []struct {
+        name       string
+        token      string
+        wantStatus int
+    }{
+        {"Agent token", string(agentToken), http.StatusNoContent},
+        {"Missing token", "", http.StatusUnauthorized},
+        {"Wrong token", "guess", http.StatusUnauthorized},
+        {"User token", signTestToken(t, 42, jwtKey, time.Now().Add(time.Hour)), http.StatusUnauthorized},
+    }
+
+    for _, tc := range testCases {
+        t.Run(tc.name, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodGet, "/internal/task?agent=test&wait=0", nil)
+            if tc.token != "" {
+                req.Header.Set(agentTokenHeader, tc.token)
+            }
+            rr := httptest.NewRecorder()
+            handler(rr, req)
+
+            if rr.Code != tc.wantStatus {
+                t.Errorf("Expected status %d, got %d", tc.wantStatus, rr.Code)
+            }
+        })
+    }
+}
+
+func TestFetchOwnedCalculationOtherUser(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    // Вычисление принадлежит пользователю 7
+    mock.ExpectQuery("SELECT operation, result, status, userId, error_message, attempts, variables, exact_result, precision_scale, rounding_mode, precision_mode FROM calculations").WithArgs(3).
+        WillReturnRows(sqlmock.NewRows([]string{"operation", "result", "status", "userId", "error_message", "attempts", "variables", "exact_result", "precision_scale", "rounding_mode", "precision_mode"}).AddRow("2+2", 4.0, "completed", 7, nil, 0, nil, nil, nil, nil, nil))
+
+    req := httptest.NewRequest(http.MethodGet, "/get-calculation-result?id=3", nil)
+    req = req.WithContext(context.WithValue(req.Context(), userIDContextKey, 42))
+    rr := httptest.NewRecorder()
+
+    if _, ok := fetchOwnedCalculation(rr, req, database.NewPostgresStore(db), 3); ok {
+        t.Error("Expected calculation of another user to be rejected")
+    }
+    if rr.Code != http.StatusNotFound {
+        t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+    }
+}
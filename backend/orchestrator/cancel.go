@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"       // Ограничение времени запросов к калькуляторам
+	"encoding/json" // Для кодирования ответа
+	"errors"        // Для проверки ошибок базы данных
+	"log"           // Для логирования
+	"net/http"      // Для работы с HTTP
+	"strconv"       // Для разбора идентификатора вычисления
+
+	pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+	"calculatorapi/utility/database" // Пакет для работы с базой данных
+)
+
+// handleCancelCalculation отменяет вычисление пользователя: невыполненные подзадачи снимаются из очереди,
+// а калькуляторы прерывают уже выполняющиеся. Результаты, пришедшие после отмены, отклоняются.
+func handleCancelCalculation(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			sendJSONError(w, "Invalid calculation id", http.StatusBadRequest)
+			return
+		}
+
+		if _, ok := fetchOwnedCalculation(w, r, store, id); !ok {
+			return
+		}
+
+		err = store.CancelCalculation(id)
+		if errors.Is(err, database.ErrCalculationFinished) {
+			sendJSONError(w, "Calculation is already finished", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Error cancelling calculation %d: %v", id, err)
+			sendJSONError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		tasksChanged.notify()
+
+		go cancelOnAgents(id)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "status": "cancelled"})
+	}
+}
+
+// cancelOnAgents просит все зарегистрированные калькуляторы прервать задания вычисления id.
+func cancelOnAgents(id int) {
+	for _, agent := range agents.list() {
+		if agent.GRPCAddress == "" {
+			continue
+		}
+
+		client, err := agentConns.client(agent.GRPCAddress)
+		if err != nil {
+			log.Printf("Failed to dial agent %q at %s: %v", agent.Name, agent.GRPCAddress, err)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), agentRPCTimeout)
+		resp, err := client.CancelCalculation(ctx, &pb.CancelRequest{Id: int32(id)})
+		cancel()
+		if err != nil {
+			log.Printf("Failed to cancel calculation ID %d on agent %q: %v", id, agent.Name, err)
+			continue
+		}
+		if resp.Cancelled > 0 {
+			log.Printf("Agent %q interrupted %d tasks of calculation ID %d", agent.Name, resp.Cancelled, id)
+		}
+	}
+}
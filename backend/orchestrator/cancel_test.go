@@ -0,0 +1,49 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "calculatorapi/utility/database"
+)
+
+// cancelRequest вызывает обработчик отмены от имени пользователя userID.
+func cancelRequest(store database.Store, userID int, id string) *httptest.ResponseRecorder {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/v1/calculations/{id}/cancel", handleCancelCalculation(store))
+
+    req := httptest.NewRequest(http.MethodPost, "/api/v1/calculations/"+id+"/cancel", nil)
+    req = req.WithContext(context.WithValue(req.Context(), userIDContextKey, userID))
+    rr := httptest.NewRecorder()
+    mux.ServeHTTP(rr, req)
+    return rr
+}
+
+func TestCancelCalculation(t *testing.T) {
+    store := database.NewMemoryStore()
+    id, _ := store.InsertCalculation(1, "2+3*4", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    if err := planCalculation(store, id, "2+3*4", nil, nil); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+
+    if rr := cancelRequest(store, 2, "1"); rr.Code != http.StatusNotFound {
+        t.Errorf("Expected status %d for another user, got %d", http.StatusNotFound, rr.Code)
+    }
+    if rr := cancelRequest(store, 1, "abc"); rr.Code != http.StatusBadRequest {
+        t.Errorf("Expected status %d for invalid id, got %d", http.StatusBadRequest, rr.Code)
+    }
+
+    if rr := cancelRequest(store, 1, "1"); rr.Code != http.StatusOK {
+        t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+    }
+    result, err := store.GetCalculationResultByID(id)
+    if err != nil || result.Status != "cancelled" {
+        t.Errorf("Expected cancelled calculation, got %+v, %v", result, err)
+    }
+
+    if rr := cancelRequest(store, 1, "1"); rr.Code != http.StatusConflict {
+        t.Errorf("Expected status %d for a finished calculation, got %d", http.StatusConflict, rr.Code)
+    }
+}
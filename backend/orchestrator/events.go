@@ -0,0 +1,272 @@
+package main
+
+import (
+	"database/sql" // Для распознавания удаленных вычислений
+	"errors"       // Для сравнения ошибок хранилища
+	"log"          // Для логирования
+	"sync"         // Для синхронизации подписчиков
+	"time"         // Для работы со временем
+
+	"calculatorapi/utility/calculation" // Точный режим вычисления
+	"calculatorapi/utility/database"    // Пакет для работы с базой данных
+	"calculatorapi/utility/models"      // Пакет с моделями данных
+)
+
+// Размер очереди событий одного подписчика. Если подписчик не успевает их читать,
+// новые события для него отбрасываются, чтобы не задерживать изменения хранилища.
+const eventBufferSize = 64
+
+// calculationEvent - изменение вычисления пользователя: переход в новый статус или изменение одной из подзадач.
+type calculationEvent struct {
+	UserId      int                         // Владелец вычисления, только ему доставляется событие
+	Calculation *models.CalculationResponse // Новое состояние вычисления, если изменился его статус
+	Step        *models.Task                // Новое состояние подзадачи, если изменилась она
+	Progress    *calculationProgress        // Операция, выполненная калькулятором
+}
+
+// calculationProgress - операция вычисления, о которой калькулятор сообщил через WatchCalculation.
+type calculationProgress struct {
+	ID        int     `json:"id"`               // Идентификатор вычисления
+	TaskID    int     `json:"taskId,omitempty"` // Идентификатор подзадачи
+	Left      float64 `json:"left"`             // Левый операнд
+	Operator  string  `json:"operator"`         // Оператор
+	Right     float64 `json:"right"`            // Правый операнд
+	Args      []float64 `json:"args,omitempty"` // Аргументы, если operator - имя встроенной функции
+	Result    float64 `json:"result"`           // Промежуточный результат
+	Exact     string  `json:"exact,omitempty"`  // Точная запись промежуточного результата в точном режиме
+	ElapsedMs int64   `json:"elapsedMs"`        // Время выполнения операции в миллисекундах
+	Agent     string  `json:"agent"`            // Калькулятор, выполнивший операцию
+}
+
+// eventBus рассылает события вычислений подписчикам внутри процесса оркестратора.
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan calculationEvent]int // Канал подписчика -> идентификатор пользователя
+}
+
+// Глобальная шина событий вычислений
+var calculationEvents = newEventBus()
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[chan calculationEvent]int)}
+}
+
+// subscribe подписывает на события вычислений пользователя userId.
+// Возвращает канал событий и функцию отписки, которая закрывает канал.
+func (b *eventBus) subscribe(userId int) (<-chan calculationEvent, func()) {
+	ch := make(chan calculationEvent, eventBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = userId
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish доставляет событие подписчикам его владельца, не дожидаясь их.
+func (b *eventBus) publish(event calculationEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch, userId := range b.subscribers {
+		if userId != event.UserId {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Event queue of a user %d subscriber is full, event dropped", userId)
+		}
+	}
+}
+
+// eventStore дополняет хранилище публикацией событий: после каждого изменения вычисления или подзадачи
+// их новое состояние читается из хранилища и отправляется в шину. Статус вычисления публикуется,
+// только если он изменился, поэтому подписчики видят переходы created -> work -> completed/error/failed/cancelled.
+type eventStore struct {
+	database.Store
+	bus *eventBus
+
+	// mu защищает statuses и упорядочивает публикации: состояние читается и публикуется под блокировкой,
+	// поэтому параллельные изменения из HTTP и gRPC обработчиков не публикуют устаревший статус после нового.
+	mu       sync.Mutex
+	statuses map[int]publishedStatus // Последний опубликованный статус незавершенных вычислений
+}
+
+// publishedStatus - последний опубликованный статус вычисления и его владелец.
+type publishedStatus struct {
+	UserId int
+	Status string
+}
+
+// newEventStore создает хранилище, публикующее изменения вычислений в шину bus.
+func newEventStore(store database.Store, bus *eventBus) *eventStore {
+	return &eventStore{Store: store, bus: bus, statuses: make(map[int]publishedStatus)}
+}
+
+// publishCalculation публикует состояние вычисления id, если его статус изменился.
+func (s *eventStore) publishCalculation(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishLocked(id)
+}
+
+// publishLocked публикует состояние вычисления id и забывает его, когда вычисление завершено или удалено.
+// Вызывается под s.mu.
+func (s *eventStore) publishLocked(id int) {
+	calc, err := s.Store.GetCalculationResultByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		delete(s.statuses, id) // Вычисление удалено
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading calculation ID %d to publish: %v", id, err)
+		return
+	}
+
+	changed := s.statuses[id].Status != calc.Status
+	if finishedStatus(calc.Status) {
+		delete(s.statuses, id) // Больше переходов не будет
+	} else {
+		s.statuses[id] = publishedStatus{UserId: calc.UserId, Status: calc.Status}
+	}
+	if changed {
+		s.bus.publish(calculationEvent{UserId: calc.UserId, Calculation: calc})
+	}
+}
+
+// prune перечитывает отслеживаемые вычисления и забывает завершенные и удаленные, например
+// завершенные другим экземпляром оркестратора; изменившийся статус при этом публикуется.
+func (s *eventStore) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.statuses {
+		s.publishLocked(id)
+	}
+}
+
+// publishStep публикует состояние подзадачи taskID, а затем ее вычисления.
+func (s *eventStore) publishStep(taskID int) {
+	task, err := s.Store.GetTaskByID(taskID)
+	if err != nil {
+		log.Printf("Error reading task ID %d to publish: %v", taskID, err)
+		return
+	}
+	calc, err := s.Store.GetCalculationResultByID(task.CalculationID)
+	if err != nil {
+		log.Printf("Error reading calculation ID %d to publish: %v", task.CalculationID, err)
+		return
+	}
+
+	s.bus.publish(calculationEvent{UserId: calc.UserId, Step: task})
+	s.publishCalculation(task.CalculationID)
+}
+
+func (s *eventStore) InsertCalculation(userId int, operation string, addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration, inactiveServerTime int, functionDurations map[string]int, variables map[string]float64, precision *calculation.Precision) (int, error) {
+	id, err := s.Store.InsertCalculation(userId, operation, addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration, inactiveServerTime, functionDurations, variables, precision)
+	if err == nil {
+		s.publishCalculation(id)
+	}
+	return id, err
+}
+
+func (s *eventStore) ClearCalculationsByUser(userId int) error {
+	err := s.Store.ClearCalculationsByUser(userId)
+	if err == nil {
+		s.mu.Lock()
+		for id, published := range s.statuses {
+			if published.UserId == userId {
+				delete(s.statuses, id)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *eventStore) UpdateCalculation(id int, result float64, exact string, status string) error {
+	err := s.Store.UpdateCalculation(id, result, exact, status)
+	if err == nil {
+		s.publishCalculation(id)
+	}
+	return err
+}
+
+func (s *eventStore) UpdateCalculationError(id int, message string) error {
+	err := s.Store.UpdateCalculationError(id, message)
+	if err == nil {
+		s.publishCalculation(id)
+	}
+	return err
+}
+
+func (s *eventStore) UpdateCalculationStatusToWork(id int) error {
+	err := s.Store.UpdateCalculationStatusToWork(id)
+	if err == nil {
+		s.publishCalculation(id)
+	}
+	return err
+}
+
+func (s *eventStore) ClaimTask(owner, agent string, lease time.Duration) (*models.CalculationRequest, error) {
+	task, err := s.Store.ClaimTask(owner, agent, lease)
+	if err == nil && task != nil {
+		s.publishStep(task.TaskID)
+	}
+	return task, err
+}
+
+func (s *eventStore) UpdateTaskStatusToWork(taskID int, owner, agent string) error {
+	err := s.Store.UpdateTaskStatusToWork(taskID, owner, agent)
+	if err == nil {
+		s.publishStep(taskID)
+	}
+	return err
+}
+
+func (s *eventStore) RetryTask(taskID int, message string, policy database.RetryPolicy) (bool, error) {
+	failed, err := s.Store.RetryTask(taskID, message, policy)
+	if err == nil {
+		s.publishStep(taskID)
+	}
+	return failed, err
+}
+
+func (s *eventStore) CompleteTask(taskID int, result float64, exact string) error {
+	err := s.Store.CompleteTask(taskID, result, exact)
+	if err == nil {
+		s.publishStep(taskID)
+	}
+	return err
+}
+
+func (s *eventStore) FailTask(taskID int, message string) error {
+	err := s.Store.FailTask(taskID, message)
+	if err == nil {
+		s.publishStep(taskID)
+	}
+	return err
+}
+
+func (s *eventStore) RequeueCalculation(id int) error {
+	err := s.Store.RequeueCalculation(id)
+	if err == nil {
+		s.publishCalculation(id)
+	}
+	return err
+}
+
+func (s *eventStore) CancelCalculation(id int) error {
+	err := s.Store.CancelCalculation(id)
+	if err == nil {
+		s.publishCalculation(id)
+	}
+	return err
+}
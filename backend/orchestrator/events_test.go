@@ -0,0 +1,194 @@
+package main
+
+import (
+    "bufio"
+    "context"
+    "io"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "google.golang.org/grpc"
+    pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+    "calculatorapi/utility/database"
+)
+
+// nextEvent возвращает следующее событие шины или завершает тест, если его нет.
+func nextEvent(t *testing.T, events <-chan calculationEvent) calculationEvent {
+    t.Helper()
+    select {
+    case event := <-events:
+        return event
+    case <-time.After(time.Second):
+        t.Fatal("Expected an event")
+        return calculationEvent{}
+    }
+}
+
+func TestEventStorePublishesTransitions(t *testing.T) {
+    bus := newEventBus()
+    store := newEventStore(database.NewMemoryStore(), bus)
+    events, unsubscribe := bus.subscribe(1)
+    defer unsubscribe()
+    others, unsubscribeOthers := bus.subscribe(2)
+    defer unsubscribeOthers()
+
+    id, _ := store.InsertCalculation(1, "2+3", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    if event := nextEvent(t, events); event.Calculation == nil || event.Calculation.Status != "created" {
+        t.Fatalf("Expected created status, got %+v", event)
+    }
+    if err := planCalculation(store, id, "2+3", nil, nil); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+
+    task, err := store.ClaimTask("orchestrator-a", "calculator1", time.Minute)
+    if err != nil || task == nil {
+        t.Fatalf("Expected a ready task, got %v", err)
+    }
+    if event := nextEvent(t, events); event.Step == nil || event.Step.Status != "work" {
+        t.Errorf("Expected working step, got %+v", event)
+    }
+    if event := nextEvent(t, events); event.Calculation == nil || event.Calculation.Status != "work" {
+        t.Errorf("Expected work status, got %+v", event)
+    }
+
+    if err := store.CompleteTask(task.TaskID, 5, ""); err != nil {
+        t.Fatalf("CompleteTask returned error: %v", err)
+    }
+    if event := nextEvent(t, events); event.Step == nil || event.Step.Status != "completed" || event.Step.Result != 5 {
+        t.Errorf("Expected completed step with result 5, got %+v", event)
+    }
+    if event := nextEvent(t, events); event.Calculation == nil || event.Calculation.Status != "completed" || event.Calculation.Result != 5 {
+        t.Errorf("Expected completed status with result 5, got %+v", event)
+    }
+
+    select {
+    case event := <-events:
+        t.Errorf("Unexpected event %+v", event)
+    case event := <-others:
+        t.Errorf("Event of user 1 delivered to user 2: %+v", event)
+    default:
+    }
+}
+
+func TestEventStoreForgetsCalculations(t *testing.T) {
+    bus := newEventBus()
+    memory := database.NewMemoryStore()
+    store := newEventStore(memory, bus)
+    events, unsubscribe := bus.subscribe(3)
+    defer unsubscribe()
+    tracked := func() int {
+        store.mu.Lock()
+        defer store.mu.Unlock()
+        return len(store.statuses)
+    }
+
+    // Удаленные пользователем вычисления больше не отслеживаются
+    store.InsertCalculation(3, "2+3", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    if tracked() != 1 {
+        t.Fatalf("Expected the created calculation to be tracked, got %d", tracked())
+    }
+    if err := store.ClearCalculationsByUser(3); err != nil {
+        t.Fatalf("ClearCalculationsByUser returned error: %v", err)
+    }
+    if tracked() != 0 {
+        t.Errorf("Expected deleted calculations to be forgotten, got %d", tracked())
+    }
+
+    // Вычисление, завершенное в обход этого экземпляра, забывается при очистке с публикацией нового статуса
+    id, _ := store.InsertCalculation(3, "2+3", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    nextEvent(t, events)
+    nextEvent(t, events)
+    memory.UpdateCalculation(id, 5, "", "completed")
+    store.prune()
+    if event := nextEvent(t, events); event.Calculation == nil || event.Calculation.Status != "completed" {
+        t.Errorf("Expected completed status, got %+v", event)
+    }
+    if tracked() != 0 {
+        t.Errorf("Expected finished calculations to be forgotten, got %d", tracked())
+    }
+}
+
+func TestCalculationEventsStream(t *testing.T) {
+    bus := calculationEvents
+    store := newEventStore(database.NewMemoryStore(), bus)
+    id, _ := store.InsertCalculation(1, "2*3", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    if err := planCalculation(store, id, "2*3", nil, nil); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        handleCalculationEvents(store)(w, r.WithContext(context.WithValue(r.Context(), userIDContextKey, 1)))
+    }))
+    defer server.Close()
+
+    resp, err := http.Get(server.URL + "/api/v1/calculations/events?id=1")
+    if err != nil {
+        t.Fatalf("Error opening stream: %v", err)
+    }
+    defer resp.Body.Close()
+    if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+        t.Fatalf("Expected text/event-stream, got %q", ct)
+    }
+
+    // Текущее состояние приходит сразу, после него вычисление выполняется
+    reader := bufio.NewReader(resp.Body)
+    if line, _ := reader.ReadString('\n'); line != "event: status\n" {
+        t.Fatalf("Expected status event, got %q", line)
+    }
+    if line, _ := reader.ReadString('\n'); !strings.Contains(line, `"status":"created"`) {
+        t.Fatalf("Expected created status, got %q", line)
+    }
+    task, _ := store.ClaimTask("orchestrator-a", "calculator1", time.Minute)
+    store.CompleteTask(task.TaskID, 6, "")
+
+    // Поток закрывается после завершения вычисления
+    var rest strings.Builder
+    if _, err := reader.WriteTo(&rest); err != nil {
+        t.Fatalf("Error reading stream: %v", err)
+    }
+    body := rest.String()
+    for _, want := range []string{"event: step\n", `"status":"work"`, `"result":6`, `"status":"completed"`} {
+        if !strings.Contains(body, want) {
+            t.Errorf("Expected stream to contain %q, got %q", want, body)
+        }
+    }
+}
+
+// watchingAgent - калькулятор, который передает одну операцию в WatchCalculation и закрывает поток
+type watchingAgent struct {
+    pb.UnimplementedCalculatorServiceServer
+}
+
+func (watchingAgent) WatchCalculation(req *pb.WatchRequest, stream pb.CalculatorService_WatchCalculationServer) error {
+    return stream.Send(&pb.CalculationStep{Id: 1, TaskId: 3, Left: 2, Operator: "*", Right: 3, Result: 6, Exact: "6", ElapsedMs: 1000, Agent: "calculator1"})
+}
+
+func TestRelaySteps(t *testing.T) {
+    lis, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    grpcServer := grpc.NewServer()
+    pb.RegisterCalculatorServiceServer(grpcServer, watchingAgent{})
+    go grpcServer.Serve(lis)
+    defer grpcServer.Stop()
+
+    store := database.NewMemoryStore()
+    store.InsertCalculation(7, "2*3", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    events, unsubscribe := calculationEvents.subscribe(7)
+    defer unsubscribe()
+
+    // Поток закрывается калькулятором после отправки операции
+    err = relaySteps(store, agentInfo{Name: "calculator1", GRPCAddress: lis.Addr().String()})
+    if err != io.EOF {
+        t.Errorf("Expected the stream to end with io.EOF, got %v", err)
+    }
+    event := nextEvent(t, events)
+    if event.Progress == nil || event.Progress.ID != 1 || event.Progress.Result != 6 || event.Progress.Exact != "6" || event.Progress.ElapsedMs != 1000 || event.Progress.Agent != "calculator1" {
+        t.Errorf("Expected relayed progress of calculation 1, got %+v", event)
+    }
+}
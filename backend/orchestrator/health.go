@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context" // Для ограничения времени проверки
+	"time"    // Для работы со временем
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+)
+
+// Ограничение времени проверки одного калькулятора
+const agentCheckTimeout = 2 * time.Second
+
+// checkAgent проверяет калькулятор по gRPC: сервис grpc.health.v1 сообщает, принимает ли он вычисления,
+// а CheckStatus - его загрузку. HTTP сервер калькулятора для проверки не нужен.
+// В статус добавляется состояние соединения с калькулятором из пула после проверки.
+func checkAgent(agent agentInfo) (status ServerStatus) {
+	status = ServerStatus{
+		Name:        agent.Name,
+		URL:         agent.HTTPAddress,
+		GRPCAddress: agent.GRPCAddress,
+		LastSeen:    agent.LastSeen,
+	}
+
+	conn, err := agentConns.get(agent.GRPCAddress)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer func() { status.Connection = conn.GetState().String() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), agentCheckTimeout)
+	defer cancel()
+
+	health, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: pb.CalculatorService_ServiceDesc.ServiceName})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	if health.Status != healthpb.HealthCheckResponse_SERVING {
+		status.Error = "agent is " + health.Status.String()
+		return status
+	}
+
+	load, err := pb.NewCalculatorServiceClient(conn).CheckStatus(ctx, &pb.StatusRequest{})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Running = load.Running
+	status.MaxGoroutines = int(load.MaxGoroutines)
+	status.CurrentGoroutines = int(load.CurrentGoroutines)
+	return status
+}
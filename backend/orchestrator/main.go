@@ -3,18 +3,24 @@ package main
 // Импорт необходимых пакетов
 import (
 	"encoding/json" // Для кодирования и декодирования JSON
+	"errors"        // Для проверки типа ошибок разбора выражений
+	"flag"          // Для разбора флагов командной строки
 	"fmt"           // Для форматированного вывода и ввода
 	"time"          // Для работы со временем
 	"context"         // Для работы с байтами
 	"database/sql"  // Для работы с базами данных SQL
 	"log"           // Для логирования
+	"net"           // Для запуска gRPC сервера
 	"net/http"      // Для работы с HTTP
+	"os"            // Для чтения переменных окружения
 	"strconv"       // Для конвертации строк в числа и обратно
-	"strings"
+	"sync"          // Для параллельного опроса калькуляторов
 	"github.com/golang-jwt/jwt/v4" // Для работы с токенами
 
 	"google.golang.org/grpc"
 	pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+	"calculatorapi/utility/calculation" // Пакет для разбора выражений на подзадачи
+	"calculatorapi/utility/config"      // Пакет с настройками оркестратора
 	"calculatorapi/utility/database" // Пакет для работы с базой данных
 	"calculatorapi/utility/models"   // Пакет с моделями данных
 	"golang.org/x/crypto/bcrypt"     // Драйвер для хэширования паролей
@@ -28,7 +34,13 @@ type CalculationRequest struct {
 	SubtractDuration   int    `json:"subtract_duration"`  	// Длительность операции вычитания
 	MultiplyDuration   int    `json:"multiply_duration"`  	// Длительность операции умножения
 	DivideDuration     int    `json:"divide_duration"`    	// Длительность операции деления
+	PowerDuration      int    `json:"power_duration"`     	// Длительность операции возведения в степень
+	ModuloDuration     int    `json:"modulo_duration"`    	// Длительность операции остатка от деления
+	IntDivideDuration  int    `json:"int_divide_duration"` 	// Длительность операции целочисленного деления
 	InactiveServerTime int    `json:"inactive_server_time"` // Время ожидания неактивного сервера
+	FunctionDurations  map[string]int `json:"function_durations"` // Длительности встроенных функций по имени
+	Variables          map[string]float64 `json:"variables"`     // Значения переменных выражения по имени
+	Precision          *calculation.Precision `json:"precision"`  // Точный десятичный или рациональный режим, nil - вычисление в float64
 }
 
 // Структура для ответа на запрос калькуляции, содержащая id добавленной операции в базу данных
@@ -48,66 +60,37 @@ type Claims struct {
     jwt.StandardClaims
 }
 
-var jwtKey = []byte("secret_key") // Secret key для подписания JWT токенов
-
-// Список развернутых серверов калькуляторов
-var servers = []string{
-	"http://localhost:8081", 
-	"http://localhost:8082", 
-}
-
-// Список развернутых серверов калькуляторов
-var GRPCservers = []string{
-	"http://localhost:50051", 
-	"http://localhost:50052", 
-}
+var jwtKey = []byte(config.DefaultJWTKey) // Secret key для подписания JWT токенов, задается конфигурацией
+var agentToken = []byte(config.DefaultAgentToken) // Общий секрет калькуляторов для /internal/task, задается конфигурацией
 
 // Структура для статуса сервера калькулятора
 type ServerStatus struct {
-	URL               string `json:"url"`                		// URL сервера
-	Running           bool   `json:"running"`            		// Статус работы сервера
-	MaxGoroutines     int    `json:"maxGoroutines,omitempty"` 	// Максимальное количество горутин
-	CurrentGoroutines int    `json:"currentGoroutines"`  		// Текущее количество горутин
-	Error             string `json:"error,omitempty"`    		// Ошибка, если есть
+	Name              string    `json:"name"`                    	// Имя калькулятора в реестре
+	URL               string    `json:"url"`                     	// URL сервера
+	GRPCAddress       string    `json:"grpcAddress"`             	// Адрес gRPC сервера калькулятора
+	Running           bool      `json:"running"`                 	// Статус работы сервера
+	MaxGoroutines     int       `json:"maxGoroutines,omitempty"` 	// Максимальное количество горутин
+	CurrentGoroutines int       `json:"currentGoroutines"`       	// Текущее количество горутин
+	LastSeen          time.Time `json:"lastSeen"`                	// Время последнего heartbeat
+	Connection        string    `json:"connection,omitempty"`    	// Состояние gRPC соединения из пула, например "READY"
+	Error             string    `json:"error,omitempty"`         	// Ошибка, если есть
 }
 
-// Функция для проверки статуса всех серверов калькуляторов
+// Функция для получения статуса всех калькуляторов из реестра.
+// Каждый калькулятор опрашивается по gRPC параллельно: сервисом grpc.health.v1 и методом CheckStatus.
 func pingServers() []ServerStatus {
-	var statuses []ServerStatus // Список статусов серверов
-
-	for _, serverURL := range servers {
-		status := ServerStatus{URL: serverURL}
-		resp, err := http.Get(fmt.Sprintf("%s/ping", serverURL))
-		if err != nil {
-			// Если запрос не удался, сервер считается неактивным
-			status.Running = false
-			status.Error = err.Error()
-		} else {
-			defer resp.Body.Close()
-			// Если сервер ответил успешно, читаем ответ
-			if resp.StatusCode == http.StatusOK {
-				var serverResponse struct {
-					Status           string `json:"status"`
-					MaxGoroutines    int    `json:"maxGoroutines"`
-					CurrentGoroutines int   `json:"currentGoroutines"`
-				}
-				if err := json.NewDecoder(resp.Body).Decode(&serverResponse); err != nil {
-					// Если не удалось декодировать ответ, записываем ошибку
-					status.Error = "Failed to decode response"
-				} else {
-					// Если декодирование успешно, обновляем статус сервера
-					status.Running = serverResponse.Status == "running"
-					status.MaxGoroutines = serverResponse.MaxGoroutines
-					status.CurrentGoroutines = serverResponse.CurrentGoroutines
-				}
-			} else {
-				// Если статус ответа не OK, сервер считается неактивным
-				status.Running = false
-				status.Error = fmt.Sprintf("Unexpected status code: %d", resp.StatusCode)
-			}
-		}
-		statuses = append(statuses, status) // Добавляем статус в список
+	list := agents.list()
+	statuses := make([]ServerStatus, len(list)) // Список статусов серверов
+
+	var wg sync.WaitGroup
+	for i, agent := range list {
+		wg.Add(1)
+		go func(i int, agent agentInfo) {
+			defer wg.Done()
+			statuses[i] = checkAgent(agent)
+		}(i, agent)
 	}
+	wg.Wait()
 
 	return statuses
 }
@@ -131,63 +114,135 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// Функция для отправки калькуляций на серверы калькуляторов
-func submitCalculations(db *sql.DB) {
-    calculations, err := database.FetchCalculationsToProcess(db)
+// Максимальное количество подзадач, отправляемых за один проход
+const maxTasksPerSubmission = 10
+
+var (
+	instanceID    = "orchestrator"   // Имя экземпляра оркестратора, владельца аренды подзадач; задается конфигурацией
+	leaseDuration = 30 * time.Second // Время аренды подзадачи на период отправки; задается конфигурацией
+	// Число попыток вычисления и пауза перед повторной отправкой зависших подзадач; задается конфигурацией
+	retryPolicy = database.RetryPolicy{MaxAttempts: 3, Backoff: 5 * time.Second, MaxBackoff: 5 * time.Minute}
+	// Ожидание подзадачи сверх длительности операции по умолчанию и наибольшее; задаются конфигурацией
+	defaultTaskTimeout = 3 * time.Minute
+	maxTaskTimeout     = 30 * time.Minute
+)
+
+// Функция для отправки готовых подзадач вычислений на серверы калькуляторов.
+// Независимые подзадачи одного выражения расходятся по разным серверам и выполняются параллельно,
+// а порядок серверов для каждой подзадачи определяет стратегия agentScheduler.
+func submitCalculations(store database.Store) {
+    // Разбиение на подзадачи вычислений, которые не удалось разбить при добавлении
+    unplanned, err := store.FetchUnplannedCalculations()
+    if err != nil {
+        log.Printf("Error fetching unplanned calculations: %v", err)
+        return
+    }
+    for _, calc := range unplanned {
+        if err := planCalculation(store, calc.ID, calc.Operation, calc.Variables, calc.Precision); err != nil {
+            log.Printf("Error planning calculation ID %d: %v", calc.ID, err)
+        }
+    }
+
+    // Подзадачи арендуются атомарно, поэтому другой экземпляр оркестратора или следующий проход
+    // не отправит их повторно, пока не истечет аренда
+    tasks, err := store.ClaimTasks(instanceID, maxTasksPerSubmission, leaseDuration)
     if err != nil {
-        log.Printf("Error fetching calculations to process: %v", err)
+        log.Printf("Error claiming tasks to process: %v", err)
         return
     }
 
-    for _, calc := range calculations {
-        submitted := false
-        for _, serverURL := range servers {
-            if trySubmitCalculation(serverURL, calc) {
-                submitted = true
+    for _, task := range tasks {
+        // Стратегия учитывает ожидаемое время операции, чтобы длинные операции не скапливались на одном калькуляторе
+        cost := calculateTotalOperationTime(task.Operation, task.AddDuration, task.SubtractDuration, task.MultiplyDuration, task.DivideDuration, task.PowerDuration, task.ModuloDuration, task.IntDivideDuration, task.FunctionDurations)
+        acceptedBy := "" // Калькулятор, принявший подзадачу
+        for _, agent := range agentScheduler.candidates(agents.list(), cost, time.Now()) {
+            if trySubmitCalculation(agent, task) {
+                agentScheduler.assigned(agent.Name, cost, time.Now())
+                acceptedBy = agent.Name
                 break // Прекращаем попытки, если успешно отправлено
             }
         }
-        if !submitted {
-            log.Printf("Failed to submit calculation ID %d to any server", calc.ID)
+        if acceptedBy == "" {
+            log.Printf("Failed to submit task ID %d of calculation ID %d to any server", task.TaskID, task.ID)
+            if err := store.ReleaseTask(task.TaskID, instanceID); err != nil {
+                log.Printf("Error returning task ID %d to the queue: %v", task.TaskID, err)
+            }
+            tasksChanged.notify()
+            continue
+        }
+
+        // Калькулятор принял подзадачу. ErrTaskNotReady означает, что он уже успел сообщить результат
+        err := store.UpdateTaskStatusToWork(task.TaskID, instanceID, acceptedBy)
+        if err != nil && !errors.Is(err, database.ErrTaskNotReady) {
+            log.Printf("Error marking task ID %d as work: %v", task.TaskID, err)
+        }
+    }
+}
+
+// planCalculation разбивает выражение вычисления на граф подзадач, подставляя значения переменных, и сохраняет их в хранилище.
+// Выражение без операций завершается сразу, синтаксическая ошибка (в том числе переменная, которой нет в variables)
+// переводит вычисление в статус 'error' и возвращается как *calculation.SyntaxError.
+// Если задана точность precision, результат выражения без операций приводится к ее режиму и сохраняется точно.
+func planCalculation(store database.Store, id int, operation string, variables map[string]float64, precision *calculation.Precision) error {
+    decompose := calculation.DecomposeWithVariables
+    if precision != nil {
+        decompose = calculation.DecomposeExact // Константы pi и e не имеют точного значения
+    }
+    plan, err := decompose(operation, variables)
+    if err != nil {
+        if updateErr := store.UpdateCalculationError(id, err.Error()); updateErr != nil {
+            log.Printf("Error marking calculation ID %d as error: %v", id, updateErr)
         }
+        return err
     }
+
+    // Выражение из одного числа не требует вычислений
+    if len(plan.Tasks) == 0 {
+        if precision != nil {
+            exact, err := calculation.ExactValue(plan.Value, *precision)
+            if err != nil {
+                return err
+            }
+            return store.UpdateCalculation(id, calculation.ApproximateExact(exact), exact, "completed")
+        }
+        value, err := strconv.ParseFloat(plan.Value, 64)
+        if err != nil {
+            return err
+        }
+        return store.UpdateCalculation(id, value, "", "completed")
+    }
+
+    if err := store.CreateCalculationTasks(id, plan); err != nil {
+        return err
+    }
+
+    // Калькуляторы, ожидающие подзадачи, могут сразу забрать новые
+    tasksChanged.notify()
+    return nil
 }
 
-func trySubmitCalculation(serverURL string, calc models.CalculationRequest) bool {
+// Попытка отправить подзадачу на калькулятор из реестра
+func trySubmitCalculation(agent agentInfo, calc models.CalculationRequest) bool {
 	// Create a gRPC request from the CalculationRequest
 	req := &pb.CalculationRequest{
 		Id:        int32(calc.ID),
+		TaskId:    int32(calc.TaskID),
 		Operation: calc.Operation,
-		Times: map[string]int32{
-			"add_duration":     int32(calc.AddDuration),
-			"subtract_duration": int32(calc.SubtractDuration),
-			"multiply_duration": int32(calc.MultiplyDuration),
-			"divide_duration":   int32(calc.DivideDuration),
-		},
+		Times:     make(map[string]int32),
+		Variables: calc.Variables,
 	}
-
-	// Get the index of the serverURL in the servers list
-	index := -1
-	for i, s := range servers {
-		if s == serverURL {
-			index = i
-			break
+	if calc.Precision != nil {
+		req.Precision = &pb.Precision{Mode: calc.Precision.Mode, Rounding: calc.Precision.Rounding}
+		if calc.Precision.Scale != nil {
+			req.Precision.Scale = int32(*calc.Precision.Scale)
 		}
 	}
-
-	if index == -1 {
-		log.Printf("Server URL %s not found in the servers list", serverURL)
-		return false
+	for name, seconds := range operationTimes(calc) {
+		req.Times[name] = int32(seconds)
 	}
 
-	// Get the corresponding gRPC server URL from the GRPCservers list
-	grpcServerURL := GRPCservers[index]
-
-	// Remove the "http://" part from the grpcServerURL
-	grpcServerURL = strings.TrimPrefix(grpcServerURL, "http://")
-
 	// Call the startCalculationGRPC function to start the calculation via gRPC
-	return startCalculationGRPC(grpcServerURL, req)
+	return startCalculationGRPC(agent.GRPCAddress, req)
 }
 
 // // Попытка отправить калькуляцию на указанный сервер
@@ -233,98 +288,125 @@ func trySubmitCalculation(serverURL string, calc models.CalculationRequest) bool
 //     return false
 // }
 
-// calculateTotalOperationTime рассчитывает общее время выполнения операции.
-// Входные данные: строка операции и время выполнения для каждого типа операций.
-// Возвращает общее время выполнения операции в секундах.
-func calculateTotalOperationTime(operation string, addDuration, subtractDuration, multiplyDuration, divideDuration int) int {
-    totalDuration := 0
-
-    // Проходим по каждому символу в строке операции
-    for _, char := range operation {
-        // Определяем тип операции и добавляем соответствующее ей время к общему времени
-        switch char {
-        case '+':
-            totalDuration += addDuration
-        case '-':
-            totalDuration += subtractDuration
-        case '*':
-            totalDuration += multiplyDuration
-        case '/':
-            totalDuration += divideDuration
+// calculateTotalOperationTime рассчитывает общее время выполнения выражения подзадачи в секундах.
+// Время складывается по синтаксическому дереву так же, как калькулятор выполняет выражение: задержку дают
+// только бинарные операции и вызовы функций, а унарный минус и экспонента литерала (1e-3) времени не требуют.
+// Выражение, которое не удалось разобрать, оценивается в 0: калькулятор сразу завершит его ошибкой.
+func calculateTotalOperationTime(operation string, addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration int, functionDurations map[string]int) int {
+    tree, err := calculation.Parse(operation)
+    if err != nil {
+        return 0
+    }
+    durations := operationDurations(addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration, functionDurations)
+
+    var total func(node calculation.Node) int
+    total = func(node calculation.Node) int {
+        switch n := node.(type) {
+        case *calculation.UnaryNode:
+            return total(n.Operand)
+        case *calculation.BinaryNode:
+            return total(n.Left) + total(n.Right) + durations[n.Op]
+        case *calculation.CallNode:
+            sum := durations[n.Name]
+            for _, arg := range n.Args {
+                sum += total(arg)
+            }
+            return sum
+        default:
+            return 0
         }
     }
+    return total(tree)
+}
 
-    return totalDuration
+// operationDurations возвращает длительности операций в секундах по оператору или имени функции,
+// как их задает вычисление. Операторы, функции без длительности и OperatorNegate выполняются без задержки.
+func operationDurations(addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration int, functionDurations map[string]int) map[string]int {
+    durations := map[string]int{
+        "+":  addDuration,
+        "-":  subtractDuration,
+        "*":  multiplyDuration,
+        "/":  divideDuration,
+        "^":  powerDuration,
+        "%":  moduloDuration,
+        "//": intDivideDuration,
+    }
+    for name, seconds := range functionDurations {
+        durations[name] = seconds
+    }
+    return durations
 }
 
-// checkAndRestartFailedOperations проверяет и перезапускает операции, которые не были завершены в ожидаемое время.
-func checkAndRestartFailedOperations(db *sql.DB) {
-    log.Println("Starting checkAndRestartFailedOperations")
+// taskTimeout возвращает время ожидания подзадачи сверх длительности операции: inactive_server_time вычисления
+// в секундах или, если оно не задано, defaultTaskTimeout, но не больше maxTaskTimeout.
+func taskTimeout(inactiveServerTime int) time.Duration {
+    timeout := defaultTaskTimeout
+    if inactiveServerTime > 0 {
+        timeout = time.Duration(inactiveServerTime) * time.Second
+    }
+    if timeout > maxTaskTimeout {
+        timeout = maxTaskTimeout
+    }
+    return timeout
+}
 
-	// SQL-запрос для получения операций со статусом 'work'
-    query := `
-        SELECT id, userId, operation, start_time, add_duration, subtract_duration, multiply_duration, divide_duration
-        FROM calculations
-        WHERE status = 'work'
-    `
+// checkAndRestartFailedOperations проверяет и возвращает в очередь подзадачи, которые не были завершены в ожидаемое время
+// или выполнялись калькуляторами из lost, удаленными из реестра за пропуск heartbeat.
+func checkAndRestartFailedOperations(store database.Store, lost []string) {
+    log.Println("Starting checkAndRestartFailedOperations")
 
-    rows, err := db.Query(query)
+	// Подзадачи со статусом 'work' вместе с длительностями операций и временем ожидания их вычислений
+    tasks, err := store.FetchWorkingTasks()
     if err != nil {
-        log.Printf("Error querying 'work' status operations: %v", err)
+        log.Printf("Error querying 'work' status tasks: %v", err)
         return
     }
-    defer rows.Close()
+
+    lostAgents := make(map[string]bool, len(lost))
+    for _, name := range lost {
+        lostAgents[name] = true
+    }
 
     now := time.Now().UTC() // Текущее время в формате UTC
     log.Printf("Current time (UTC): %v", now)
 
-	// Обработка каждой строки результата запроса
-    for rows.Next() {
-        var (
-            id                 int
-			userId   		   int
-            operation          string
-            startTime          time.Time
-            addDuration        int
-            subtractDuration   int
-            multiplyDuration   int
-            divideDuration     int
-        )
-
-        if err := rows.Scan(&id, &userId, &operation, &startTime, &addDuration, &subtractDuration, &multiplyDuration, &divideDuration); err != nil {
-            log.Printf("Error scanning 'work' status operation: %v", err)
+	// Обработка каждой подзадачи
+    for _, task := range tasks {
+        operationTime := operationDurations(task.AddDuration, task.SubtractDuration, task.MultiplyDuration, task.DivideDuration, task.PowerDuration, task.ModuloDuration, task.IntDivideDuration, task.FunctionDurations)[task.Operator]
+        expectedEndTime := task.StartTime.Add(time.Duration(operationTime) * time.Second).Add(taskTimeout(task.InactiveServerTime))
+
+        log.Printf("Task ID %d, Calculation ID %d, User Id: %d Start time: %v, Operation time: %d seconds, Expected end time: %v", task.ID, task.CalculationID, task.UserId, task.StartTime, operationTime, expectedEndTime)
+
+		// Подзадача калькулятора, переставшего присылать heartbeat, не будет выполнена, поэтому ее не нужно дожидаться.
+		// Если текущее время превышает ожидаемое время завершения, подзадача также возвращается в статус 'ready'.
+		// В обоих случаях вычислению засчитывается неудачная попытка. Исчерпав попытки, вычисление переходит в статус 'failed'
+        var message string
+        switch {
+        case lostAgents[task.Agent]:
+            log.Printf("Task ID %d was running on agent %q, which missed heartbeats. Resetting status to 'ready'.", task.ID, task.Agent)
+            message = fmt.Sprintf("task %d (%s): agent %q stopped sending heartbeats", task.ID, task.Operator, task.Agent)
+        case now.After(expectedEndTime):
+            log.Printf("Task ID %d exceeded expected end time. Resetting status to 'ready'.", task.ID)
+            message = fmt.Sprintf("task %d (%s) did not complete by %s", task.ID, task.Operator, expectedEndTime.Format(time.RFC3339))
+        default:
+            log.Printf("Task ID %d is still within the expected time frame.", task.ID)
             continue
         }
 
-        operationTime := calculateTotalOperationTime(operation, addDuration, subtractDuration, multiplyDuration, divideDuration)
-        expectedEndTime := startTime.Add(time.Duration(operationTime) * time.Second).Add(3 * time.Minute)
-
-        log.Printf("Operation ID %d, User Id: %d Start time: %v, Operation time: %d seconds, Expected end time: %v", id, userId, startTime, operationTime, expectedEndTime)
-
-		// Если текущее время превышает ожидаемое время завершения операции, обновляем статус на 'created'
-        if now.After(expectedEndTime) {
-            log.Printf("Operation ID %d exceeded expected end time. Resetting status to 'created'.", id)
-
-            resetQuery := `
-                UPDATE calculations
-                SET status = 'created', start_time = NULL
-                WHERE id = $1
-            `
-
-            if _, err := db.Exec(resetQuery, id); err != nil {
-                log.Printf("Error resetting operation ID %d to 'created': %v", id, err)
-            } else {
-                log.Printf("Operation ID %d has been reset to 'created' due to timeout.", id)
-            }
-        } else {
-            log.Printf("Operation ID %d is still within the expected time frame.", id)
+        failed, err := store.RetryTask(task.ID, message, retryPolicy)
+        switch {
+        case errors.Is(err, database.ErrTaskNotActive):
+            log.Printf("Task ID %d finished before it could be retried.", task.ID)
+        case err != nil:
+            log.Printf("Error resetting task ID %d to 'ready': %v", task.ID, err)
+        case failed:
+            log.Printf("Calculation ID %d ran out of attempts and has been marked as failed.", task.CalculationID)
+        default:
+            log.Printf("Task ID %d has been reset to 'ready'.", task.ID)
+            tasksChanged.notify()
         }
     }
 
-    if err := rows.Err(); err != nil {
-        log.Printf("Error iterating over 'work' status operations: %v", err)
-    }
-
     log.Println("Completed checkAndRestartFailedOperations")
 }
 
@@ -335,20 +417,53 @@ func sendJSONError(w http.ResponseWriter, message string, statusCode int) {
 	json.NewEncoder(w).Encode(map[string]string{"error": message})
 }
 
+// handleUserCalculations возвращает все вычисления пользователя из токена.
+func handleUserCalculations(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, _ := userIDFromContext(r.Context())
+
+		calculations, err := store.FetchCalculationsByUser(userId)
+		if err != nil {
+			log.Printf("Error fetching calculations for user %d: %v", userId, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(calculations)
+	}
+}
+
+// fetchOwnedCalculation загружает вычисление и проверяет, что оно принадлежит пользователю из токена.
+// Чужие и несуществующие вычисления одинаково получают 404, чтобы не раскрывать их существование.
+func fetchOwnedCalculation(w http.ResponseWriter, r *http.Request, store database.Store, id int) (*models.CalculationResponse, bool) {
+	userId, _ := userIDFromContext(r.Context())
+
+	result, err := store.GetCalculationResultByID(id)
+	if err == sql.ErrNoRows || (err == nil && result.UserId != userId) {
+		http.Error(w, "Calculation not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error fetching calculation result: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return result, true
+}
+
 func startCalculationGRPC(serverURL string, req *pb.CalculationRequest) bool {
-	// Create a gRPC connection to the server
-	conn, err := grpc.Dial(serverURL, grpc.WithInsecure())
+	// Get a client over the pooled connection to the server
+	client, err := agentConns.client(serverURL)
 	if err != nil {
 		log.Printf("Failed to dial server %s: %v", serverURL, err)
 		return false
 	}
-	defer conn.Close()
 
-	// Create a gRPC client
-	client := pb.NewCalculatorServiceClient(conn)
-
-	// Call the PerformCalculation RPC method
-	resp, err := client.PerformCalculation(context.Background(), req)
+	// Call the PerformCalculation RPC method; the deadline keeps a hung server from blocking the submission loop
+	ctx, cancel := context.WithTimeout(context.Background(), agentRPCTimeout)
+	defer cancel()
+	resp, err := client.PerformCalculation(ctx, req)
 	if err != nil {
 		log.Printf("Failed to start calculation on server %s: %v", serverURL, err)
 		return false
@@ -366,23 +481,87 @@ func startCalculationGRPC(serverURL string, req *pb.CalculationRequest) bool {
 
 // Основная функция, запускающая сервер
 func main() {
-	// Инициализация соединения с базой данных на старте приложения
-	database.InitializeDB()
-	database.SetupDatabase()
+	// Загрузка и проверка настроек: значения по умолчанию, файл -config, переменные окружения
+	configPath := flag.String("config", os.Getenv("ORCHESTRATOR_CONFIG"), "path to a JSON config file")
+	dev := flag.Bool("dev", false, "development mode: allow default secrets")
+	flag.Parse()
+	cfg, err := config.Load(*configPath, *dev)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.Dev {
+		log.Println("Running in dev mode: default secrets are allowed")
+	}
+	jwtKey = []byte(cfg.JWTKey)
+	agentToken = []byte(cfg.AgentToken)
+	heartbeatTimeout = time.Duration(cfg.HeartbeatTimeout)
+	instanceID = cfg.InstanceID
+	leaseDuration = time.Duration(cfg.LeaseDuration)
+	agentScheduler = newScheduler(cfg.Scheduler, cfg.AgentWeights)
+	retryPolicy = database.RetryPolicy{MaxAttempts: cfg.MaxAttempts, Backoff: time.Duration(cfg.RetryBackoff), MaxBackoff: time.Duration(cfg.MaxRetryBackoff)}
+	adminLogins = cfg.AdminLogins
+	defaultTaskTimeout = time.Duration(cfg.TaskTimeout)
+	maxTaskTimeout = time.Duration(cfg.MaxTaskTimeout)
+	log.Printf("Orchestrator instance %q, %s scheduling", instanceID, cfg.Scheduler)
+
+	// Подкоманда "migrate" управляет схемой базы данных PostgreSQL и завершает программу
+	if flag.Arg(0) == "migrate" {
+		if cfg.Store != config.StorePostgres {
+			log.Fatalf("The migrate command applies to the postgres store, the %s store is migrated on startup", cfg.Store)
+		}
+		database.InitializeDB(cfg.Database)
+		if err := runMigrateCommand(database.GetDB(), flag.Args()[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Открытие хранилища; более новая схема, чем известна программе, не позволяет запуститься
+	store, err := openStore(cfg)
+	if err != nil {
+		log.Fatalf("Error opening the %s store: %v", cfg.Store, err)
+	}
+	defer store.Close()
+	// Изменения вычислений и подзадач публикуются в шину событий для потока /api/v1/calculations/events
+	events := newEventStore(store, calculationEvents)
+	store = events
+	// Соединения с калькуляторами переиспользуются всеми вызовами и закрываются при остановке
+	defer agentConns.closeAll()
 
 	// Определение канала для управления выключением
 	shutdownCh := make(chan struct{})
 
+	// Запуск gRPC сервера для приема статусов от калькуляторов
+	lis, err := net.Listen("tcp", cfg.GRPCAddr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	// Вызовы без общего секрета калькуляторов отклоняются
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(agentTokenUnaryInterceptor),
+		grpc.StreamInterceptor(agentTokenStreamInterceptor),
+	)
+	pb.RegisterOrchestratorServiceServer(grpcServer, &orchestratorServer{store: store})
+	fmt.Printf("gRPC server is starting on %s...\n", cfg.GRPCAddr)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
+
+	// Удаление из реестра калькуляторов, переставших присылать heartbeat
+	go evictAgents(agents, shutdownCh)
+
 	// Горутина периодической отправки задач на калькуляторы
 	go func() {
-		db := database.GetDB() // Получение глобального объекта базы данных
-		ticker := time.NewTicker(30 * time.Second) // Таймер для периодической проверки
+		// Подзадачи становятся готовыми волнами по мере завершения операндов, поэтому проверка частая
+		ticker := time.NewTicker(time.Duration(cfg.SubmitInterval))
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ticker.C:
-				submitCalculations(db) // Отправка вычислений на обработку
+				submitCalculations(store) // Отправка вычислений на обработку
 			case <-shutdownCh:
 				log.Println("Stopping submission of new calculations.")
 				return
@@ -392,7 +571,7 @@ func main() {
 
 	// Обработчик для эндпоинта /submit-calculation.
 	// Принимает запросы на добавление новых вычислений.
-	http.HandleFunc("/submit-calculation", enableCORS(func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/submit-calculation", enableCORS(requireAuth(func(w http.ResponseWriter, r *http.Request) {
         // Возвращаем ошибку, если метод запроса не POST
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -406,6 +585,28 @@ func main() {
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
+		// Пользователь определяется только по токену, userId из тела запроса игнорируется
+		req.UserId, _ = userIDFromContext(r.Context())
+
+		// Длительность можно задать только встроенной функции
+		for name := range req.FunctionDurations {
+			if _, ok := calculation.LookupFunction(name); !ok {
+				http.Error(w, fmt.Sprintf("Unknown function %q in function_durations", name), http.StatusBadRequest)
+				return
+			}
+		}
+		// Имена переменных не должны совпадать с функциями и константами
+		if err := calculation.ValidateVariables(req.Variables); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Масштаб и режим округления точного режима
+		if req.Precision != nil {
+			if err := req.Precision.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 
 		// fmt.Println("AddDuration:", req.AddDuration)
 		// fmt.Println("SubtractDuration:", req.SubtractDuration)
@@ -413,9 +614,8 @@ func main() {
 		// fmt.Println("DivideDuration:", req.DivideDuration)
 		// fmt.Println("InactiveServerTime:", req.InactiveServerTime)
 
-		// Вставка данных о вычислении в базу данных
-		db := database.GetDB()
-		id, err := database.InsertCalculation(db, req.UserId, req.Operation, req.AddDuration, req.SubtractDuration, req.MultiplyDuration, req.DivideDuration, req.InactiveServerTime)
+		// Вставка данных о вычислении в хранилище
+		id, err := store.InsertCalculation(req.UserId, req.Operation, req.AddDuration, req.SubtractDuration, req.MultiplyDuration, req.DivideDuration, req.PowerDuration, req.ModuloDuration, req.IntDivideDuration, req.InactiveServerTime, req.FunctionDurations, req.Variables, req.Precision)
 		// В случае ошибки при записи в базу данных возвращаем ошибку сервера
 		if err != nil {
 			log.Fatal("Error writing data to database:", err)
@@ -428,14 +628,31 @@ func main() {
 			UserId    int    `json:"userId"`
 			Status    string `json:"status"`
 			Operation string `json:"operation"`
+			Variables map[string]float64 `json:"variables,omitempty"`
+			Precision *calculation.Precision `json:"precision,omitempty"`
+			Error     string `json:"error,omitempty"`
+		}
+
+		// Разбиение выражения на подзадачи. Синтаксическая ошибка сразу возвращается пользователю,
+		// остальные ошибки не мешают принять вычисление: разбиение повторится при следующей отправке задач.
+		if err := planCalculation(store, id, req.Operation, req.Variables, req.Precision); err != nil {
+			var syntaxErr *calculation.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				resp := CalculationResponse{ID: id, UserId: req.UserId, Status: "error", Operation: req.Operation, Variables: req.Variables, Precision: req.Precision, Error: err.Error()}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(resp)
+				return
+			}
+			log.Printf("Error planning calculation ID %d, it will be retried: %v", id, err)
 		}
 		
 		// Создаем ответ сервера с ID созданного вычисления
 		status := "created"
-		resp := CalculationResponse{ID: id, UserId: req.UserId, Status: status, Operation: req.Operation}
+		resp := CalculationResponse{ID: id, UserId: req.UserId, Status: status, Operation: req.Operation, Variables: req.Variables, Precision: req.Precision}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
-	}))
+	})))
 
 	// Обработчик для проверки статуса серверов калькуляторов.
 	http.HandleFunc("/ping-servers", enableCORS(func(w http.ResponseWriter, r *http.Request) {
@@ -459,7 +676,7 @@ func main() {
 	}))
 
 	// Обработчик для получения результата вычисления по ID.
-	http.HandleFunc("/get-calculation-result", enableCORS(func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/get-calculation-result", enableCORS(requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		// Parse query parameters
 		idParam := r.URL.Query().Get("id")
 		if idParam == "" {
@@ -473,84 +690,81 @@ func main() {
 			return
 		}
 
-		db := database.GetDB()
-		if err != nil {
-			log.Printf("Error setting up the database: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		result, err := database.GetCalculationResultByID(db, id)
-		if err != nil {
-			log.Printf("Error fetching calculation result: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		result, ok := fetchOwnedCalculation(w, r, store, id)
+		if !ok {
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(result)
-	}))
+	})))
 
-	// Обработчик для получения всех вычислений из базы данных.
-	http.HandleFunc("/get-all-calculations", enableCORS(func(w http.ResponseWriter, r *http.Request) {
-		db := database.GetDB()
+	// Внутренний обработчик, через который калькуляторы сами забирают подзадачи и возвращают результаты.
+	// Доступен только калькуляторам, знающим общий секрет agentToken.
+	http.HandleFunc("/internal/task", requireAgentToken(handleInternalTask(store)))
 
-		calculations, err := database.FetchAllCalculations(db)
-		if err != nil {
-			log.Printf("Error fetching all calculations: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+	// Обработчик для получения подзадач вычисления по ID, чтобы отслеживать прогресс по каждой операции.
+	http.HandleFunc("/get-calculation-tasks", enableCORS(requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		idParam := r.URL.Query().Get("id")
+		if idParam == "" {
+			http.Error(w, "Missing id parameter", http.StatusBadRequest)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(calculations)
-	}))
-
-	// Обработчик для получения всех вычислений по userId.
-	http.HandleFunc("/get-calculations-by-user", enableCORS(func(w http.ResponseWriter, r *http.Request) {
-		db := database.GetDB()
-		userIdParam := r.URL.Query().Get("userId") // Получение userId из параметров запроса.
-
-		if userIdParam == "" {
-			http.Error(w, "User ID is required", http.StatusBadRequest)
+		id, err := strconv.Atoi(idParam)
+		if err != nil {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
 			return
 		}
 
-		userId, err := strconv.Atoi(userIdParam) // Преобразование userId в int.
-		if err != nil {
-			http.Error(w, "Invalid User ID", http.StatusBadRequest)
+		if _, ok := fetchOwnedCalculation(w, r, store, id); !ok {
 			return
 		}
 
-		calculations, err := database.FetchCalculationsByUser(db, userId)
+		tasks, err := store.FetchTasksByCalculation(id)
 		if err != nil {
-			log.Printf("Error fetching calculations for user %d: %v", userId, err)
+			log.Printf("Error fetching tasks of calculation %d: %v", id, err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(calculations)
-	}))
+		json.NewEncoder(w).Encode(tasks)
+	})))
+
+	// Обработчики для получения всех вычислений пользователя, определенного по токену.
+	// Параметр userId эндпоинта /get-calculations-by-user больше не учитывается.
+	http.HandleFunc("/get-all-calculations", enableCORS(requireAuth(handleUserCalculations(store))))
+	http.HandleFunc("/get-calculations-by-user", enableCORS(requireAuth(handleUserCalculations(store))))
 
-	// Обработчик для очистки всех вычислений из базы данных.
-	http.HandleFunc("/clear-all-calculations", enableCORS(func(w http.ResponseWriter, r *http.Request) {
+	// Поток изменений статусов и промежуточных результатов вычислений пользователя (Server-Sent Events).
+	http.HandleFunc("/api/v1/calculations/events", enableCORS(requireAuth(handleCalculationEvents(store))))
+
+	// Обработчик для отмены вычисления, которое еще ожидает в очереди или выполняется.
+	http.HandleFunc("/api/v1/calculations/{id}/cancel", enableCORS(requireAuth(handleCancelCalculation(store))))
+
+	// Администрирование: вычисления, исчерпавшие попытки, и их возврат в очередь
+	http.HandleFunc("/api/v1/admin/calculations/failed", enableCORS(requireAdmin(handleFailedCalculations(store))))
+	http.HandleFunc("/api/v1/admin/calculations/{id}/requeue", enableCORS(requireAdmin(handleRequeueCalculation(store))))
+
+	// Обработчик для очистки всех вычислений пользователя.
+	http.HandleFunc("/clear-all-calculations", enableCORS(requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 			return
 		}
 	
-		db := database.GetDB()
+		userId, _ := userIDFromContext(r.Context())
 	
-		if err := database.ClearAllCalculations(db); err != nil {
-			log.Printf("Error clearing all calculations: %v", err)
+		if err := store.ClearCalculationsByUser(userId); err != nil {
+			log.Printf("Error clearing calculations of user %d: %v", userId, err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 	
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, "All calculations have been cleared successfully.")
-	}))
+	})))
 
 	// Обработчик для регистрации нового пользователя по логину и паролю.
 	http.HandleFunc("/api/v1/register", enableCORS(func(w http.ResponseWriter, r *http.Request) {
@@ -567,7 +781,7 @@ func main() {
 		}
 	
 		// Call the database function to insert the new user
-		err = database.RegisterUser(database.GetDB(), newUser.Login, newUser.Password)
+		err = store.RegisterUser(newUser.Login, newUser.Password)
 		if err != nil {
 			log.Printf("Error registering user: %v", err)
 			sendJSONError(w, "Internal server error", http.StatusInternalServerError)
@@ -602,10 +816,9 @@ func main() {
 			return
 		}
 
-		db := database.GetDB()
-		user, err := database.GetUserByLogin(db, requestData.Login)
+		user, err := store.GetUserByLogin(requestData.Login)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, database.ErrUserNotFound) {
 				http.Error(w, "User not found", http.StatusNotFound)
 				return
 			}
@@ -627,9 +840,8 @@ func main() {
 			return
 		}
 
-		// Получение пользователя из базы данных
-		db := database.GetDB()
-		user, err := database.GetUserByLogin(db, creds.Login)
+		// Получение пользователя из хранилища
+		user, err := store.GetUserByLogin(creds.Login)
 		if err != nil {
 			sendJSONError(w, "Login failed", http.StatusUnauthorized)
 			return
@@ -669,14 +881,17 @@ func main() {
 
 	// Горутина для периодической проверки и перезапуска неудачных операций.
 	go func() {
-		ticker := time.NewTicker(1 * time.Minute)
+		ticker := time.NewTicker(time.Duration(cfg.RestartInterval))
 		defer ticker.Stop()
 	
 		for {
 			select {
 			case <-ticker.C:
-				db := database.GetDB()
-				checkAndRestartFailedOperations(db)	
+				checkAndRestartFailedOperations(store, nil)
+				events.prune() // Вычисления, завершенные другими экземплярами, больше не отслеживаются
+			case lost := <-lostAgents:
+				// Подзадачи калькуляторов, пропустивших heartbeat, возвращаются в очередь сразу
+				checkAndRestartFailedOperations(store, lost)
 			case <-shutdownCh:
 				log.Println("Shutting down check and restart operations.")
 				return
@@ -684,9 +899,9 @@ func main() {
 		}
 	}()
 
-	// Запуск HTTP-сервера на порту 8080.
-	fmt.Println("Server is running on port 8080...")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	// Запуск HTTP-сервера.
+	fmt.Printf("Server is running on %s...\n", cfg.HTTPAddr)
+	if err := http.ListenAndServe(cfg.HTTPAddr, nil); err != nil {
 		log.Fatal("Error starting server:", err)
 		// Закрытие канала при остановке сервера
 		close(shutdownCh)
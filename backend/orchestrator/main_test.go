@@ -1,37 +1,74 @@
 package main
 
 import (
+    "bytes"
+    "context"
+    "net"
     "net/http"
     "net/http/httptest"
     "testing"
     "github.com/DATA-DOG/go-sqlmock"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/health"
+    healthpb "google.golang.org/grpc/health/grpc_health_v1"
+    pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+    "calculatorapi/utility/database"
+    "calculatorapi/utility/calculation"
     "calculatorapi/utility/models"
     "encoding/json"
+    "errors"
+    "strings"
+    "time"
 )
 
+// statusAgent - калькулятор, отвечающий на CheckStatus
+type statusAgent struct {
+    pb.UnimplementedCalculatorServiceServer
+}
+
+func (statusAgent) CheckStatus(ctx context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
+    return &pb.StatusResponse{Running: true, MaxGoroutines: 10, CurrentGoroutines: 5}, nil
+}
+
 func TestPingServers(t *testing.T) {
-    // Создание мок-сервера, который отвечает, как если бы он был настоящим сервером расчетов
-    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        if r.URL.Path == "/ping" {
-            json.NewEncoder(w).Encode(map[string]interface{}{
-                "status": "running",
-                "maxGoroutines": 10,
-                "currentGoroutines": 5,
-            })
-        }
-    }))
-    defer server.Close()
+    lis, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    grpcServer := grpc.NewServer()
+    pb.RegisterCalculatorServiceServer(grpcServer, statusAgent{})
+    healthServer := health.NewServer()
+    healthServer.SetServingStatus(pb.CalculatorService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+    healthpb.RegisterHealthServer(grpcServer, healthServer)
+    go grpcServer.Serve(lis)
+    defer grpcServer.Stop()
 
-    // Замена среза серверов на URL мок-сервера
-    servers = []string{server.URL}
+    // Реестр с работающим калькулятором без HTTP сервера и калькулятором, который не отвечает
+    agents = newAgentRegistry()
+    agents.register(agentInfo{Name: "calculator1", GRPCAddress: lis.Addr().String()}, time.Now())
+    agents.register(agentInfo{Name: "calculator2", GRPCAddress: "127.0.0.1:1"}, time.Now())
 
     // Вызов функции, подлежащей тестированию
     statuses := pingServers()
 
     // Проверка, правильно ли отрапортированы статусы
-    if len(statuses) != 1 || !statuses[0].Running {
-        t.Errorf("Expected the server to be running but got %v", statuses[0])
+    if len(statuses) != 2 || !statuses[0].Running || statuses[0].CurrentGoroutines != 5 || statuses[0].MaxGoroutines != 10 || statuses[0].LastSeen.IsZero() {
+        t.Errorf("Expected the first server to be running but got %v", statuses)
+    }
+    if len(statuses) == 2 && statuses[0].Connection != "READY" {
+        t.Errorf("Expected a ready pooled connection, got %q", statuses[0].Connection)
+    }
+    if len(statuses) == 2 && (statuses[1].Running || statuses[1].Error == "") {
+        t.Errorf("Expected the second server to be unreachable but got %+v", statuses[1])
+    }
+
+    // Калькулятор, который останавливается, перестает считаться работающим
+    healthServer.Shutdown()
+    if statuses := pingServers(); statuses[0].Running || statuses[0].Error == "" {
+        t.Errorf("Expected the stopping server to be reported as not running, got %+v", statuses[0])
     }
+    agents = newAgentRegistry()
+    agentConns.prune(nil)
 }
 
 func TestSubmitCalculations(t *testing.T) {
@@ -41,31 +78,366 @@ func TestSubmitCalculations(t *testing.T) {
     }
     defer db.Close()
 
-    rows := sqlmock.NewRows([]string{"id", "userId", "operation", "add_duration", "subtract_duration", "multiply_duration", "divide_duration"}).
-        AddRow(1, 1, "2+2", 10, 10, 10, 10)
-    mock.ExpectQuery("^SELECT (.+) FROM calculations").WillReturnRows(rows)
+    // Все вычисления уже разбиты на подзадачи
+    mock.ExpectQuery("^SELECT (.+) FROM calculations c WHERE c.status = 'created'").
+        WillReturnRows(sqlmock.NewRows([]string{"id", "userId", "operation"}))
+
+    // Одна готовая подзадача: умножение из выражения "2*3 + 4*5" - арендуется до отправки
+    instanceID = "orchestrator-test"
+    rows := sqlmock.NewRows([]string{"id", "calculation_id", "userId", "operator", "operands", "add_duration", "subtract_duration", "multiply_duration", "divide_duration", "power_duration", "modulo_duration", "int_divide_duration", "function_durations", "variables", "precision_scale", "rounding_mode", "precision_mode"}).
+        AddRow(11, 1, 1, "*", `[{"value":"2"},{"value":"3"}]`, 10, 10, 10, 10, 10, 10, 10, nil, nil, nil, nil, nil)
+    mock.ExpectBegin()
+    mock.ExpectQuery("^SELECT (.+) FROM tasks t JOIN calculations c (.+) FOR UPDATE OF t SKIP LOCKED").
+        WithArgs(sqlmock.AnyArg(), maxTasksPerSubmission).WillReturnRows(rows)
+    mock.ExpectExec("UPDATE tasks SET status = 'dispatched', lease_owner = \\$1").
+        WithArgs(instanceID, sqlmock.AnyArg(), 11).WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
 
-    // Настройка HTTP-сервера для обработки запросов
+    // Ни один сервер не принял подзадачу, поэтому аренда снимается и она возвращается в очередь
+    mock.ExpectExec("UPDATE tasks SET status = 'ready'").WithArgs(11, instanceID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+    // Сервер, который не отвечает по gRPC
     server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        if r.URL.Path == "/calculate" {
-            var req models.CalculationRequest
-            json.NewDecoder(r.Body).Decode(&req)
-            if req.Operation != "2+2" {
-                t.Errorf("Expected operation '2+2', got '%s'", req.Operation)
-            }
-            w.WriteHeader(http.StatusOK)
-        }
+        w.WriteHeader(http.StatusNotFound)
     }))
     defer server.Close()
 
-    // Замена среза серверов на URL мок-сервера
-    servers = []string{server.URL}
+    // Реестр из одного калькулятора с адресом мок-сервера
+    agents = newAgentRegistry()
+    agents.register(agentInfo{Name: "calculator1", HTTPAddress: server.URL, GRPCAddress: strings.TrimPrefix(server.URL, "http://")}, time.Now())
 
     // Вызов функции, подлежащей тестированию
-    submitCalculations(db)
+    submitCalculations(database.NewPostgresStore(db))
 
     // Убедиться, что все ожидания были выполнены
     if err := mock.ExpectationsWereMet(); err != nil {
         t.Errorf("There were unfulfilled expectations: %s", err)
     }
-}
\ No newline at end of file
+}
+
+func TestPlanCalculationSyntaxError(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    mock.ExpectExec("UPDATE calculations SET result = NULL, status = 'error'").
+        WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+
+    err = planCalculation(database.NewPostgresStore(db), 5, "(2+3", nil, nil)
+    var syntaxErr *calculation.SyntaxError
+    if !errors.As(err, &syntaxErr) {
+        t.Errorf("Expected a syntax error, got %v", err)
+    }
+
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("There were unfulfilled expectations: %s", err)
+    }
+}
+
+func TestInternalTaskNoReadyTasks(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    // Готовых подзадач нет, поэтому запрос без ожидания завершается ответом 204
+    mock.ExpectBegin()
+    mock.ExpectQuery("^SELECT (.+) FROM tasks t JOIN calculations c").
+        WillReturnRows(sqlmock.NewRows([]string{"id", "calculation_id", "userId", "operator", "operands", "add_duration", "subtract_duration", "multiply_duration", "divide_duration", "power_duration", "modulo_duration", "int_divide_duration", "function_durations", "variables", "precision_scale", "rounding_mode", "precision_mode"}))
+    mock.ExpectCommit()
+
+    req := httptest.NewRequest(http.MethodGet, "/internal/task?agent=test&wait=0", nil)
+    rr := httptest.NewRecorder()
+    handleInternalTask(database.NewPostgresStore(db)).ServeHTTP(rr, req)
+
+    if rr.Code != http.StatusNoContent {
+        t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+    }
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("There were unfulfilled expectations: %s", err)
+    }
+}
+
+func TestInternalTaskResult(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    // Корневая подзадача завершает все вычисление
+    mock.ExpectBegin()
+    mock.ExpectQuery("UPDATE tasks SET status = 'completed'").
+        WithArgs(26.0, sqlmock.AnyArg(), 3).
+        WillReturnRows(sqlmock.NewRows([]string{"calculation_id", "parent_id", "parent_position"}).AddRow(1, nil, nil))
+    mock.ExpectExec("UPDATE calculations SET result = (.+), status = 'completed'").
+        WithArgs(26.0, nil, sqlmock.AnyArg(), 1).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    body, _ := json.Marshal(models.TaskResult{TaskID: 3, Result: 26})
+    req := httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body))
+    rr := httptest.NewRecorder()
+    handleInternalTask(database.NewPostgresStore(db)).ServeHTTP(rr, req)
+
+    if rr.Code != http.StatusNoContent {
+        t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+    }
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("There were unfulfilled expectations: %s", err)
+    }
+}
+
+func TestInternalTaskMemoryStore(t *testing.T) {
+    // Хранилище в памяти позволяет пройти весь путь подзадачи без базы данных
+    store := database.NewMemoryStore()
+    id, _ := store.InsertCalculation(1, "2+3", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    if err := planCalculation(store, id, "2+3", nil, nil); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+
+    req := httptest.NewRequest(http.MethodGet, "/internal/task?agent=test&wait=0", nil)
+    rr := httptest.NewRecorder()
+    handleInternalTask(store).ServeHTTP(rr, req)
+
+    var assignment models.TaskAssignment
+    if err := json.NewDecoder(rr.Body).Decode(&assignment); err != nil || assignment.ID != id {
+        t.Fatalf("Expected an assignment of calculation %d, got %+v, %v", id, assignment, err)
+    }
+
+    body, _ := json.Marshal(models.TaskResult{TaskID: assignment.TaskID, Result: 5})
+    req = httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body))
+    rr = httptest.NewRecorder()
+    handleInternalTask(store).ServeHTTP(rr, req)
+    if rr.Code != http.StatusNoContent {
+        t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+    }
+
+    result, err := store.GetCalculationResultByID(id)
+    if err != nil || result.Status != "completed" || result.Result != 5 {
+        t.Errorf("Expected completed calculation with result 5, got %+v, %v", result, err)
+    }
+}
+
+func TestInternalTaskFunctionCall(t *testing.T) {
+    // Вызов функции выдается калькулятору отдельной подзадачей вместе с длительностью функции
+    store := database.NewMemoryStore()
+    id, _ := store.InsertCalculation(1, "sqrt(2.25) * pi", 1, 1, 1, 1, 1, 1, 1, 0, map[string]int{"sqrt": 4}, nil, nil)
+    if err := planCalculation(store, id, "sqrt(2.25) * pi", nil, nil); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+
+    req := httptest.NewRequest(http.MethodGet, "/internal/task?agent=test&wait=0", nil)
+    rr := httptest.NewRecorder()
+    handleInternalTask(store).ServeHTTP(rr, req)
+
+    var assignment models.TaskAssignment
+    if err := json.NewDecoder(rr.Body).Decode(&assignment); err != nil {
+        t.Fatalf("Expected an assignment, got %v", err)
+    }
+    if assignment.Operation != "sqrt(2.25)" || assignment.Times["sqrt_duration"] != 4 {
+        t.Errorf("Expected the sqrt call with its duration, got %+v", assignment)
+    }
+}
+
+func TestInternalTaskVariables(t *testing.T) {
+    // Значения переменных подставляются при разбиении, поэтому калькулятор получает подзадачу из чисел
+    store := database.NewMemoryStore()
+    variables := map[string]float64{"rate": 0.5, "n": 12}
+    id, _ := store.InsertCalculation(1, "rate * n", 1, 1, 1, 1, 1, 1, 1, 0, nil, variables, nil)
+    if err := planCalculation(store, id, "rate * n", variables, nil); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+
+    req := httptest.NewRequest(http.MethodGet, "/internal/task?agent=test&wait=0", nil)
+    rr := httptest.NewRecorder()
+    handleInternalTask(store).ServeHTTP(rr, req)
+
+    var assignment models.TaskAssignment
+    if err := json.NewDecoder(rr.Body).Decode(&assignment); err != nil {
+        t.Fatalf("Expected an assignment, got %v", err)
+    }
+    if assignment.Operation != "0.5 * 12" || assignment.Variables["n"] != 12 {
+        t.Errorf("Expected the multiplication with substituted values, got %+v", assignment)
+    }
+
+    // Выражение с неизвестной переменной завершается ошибкой разбора
+    id, _ = store.InsertCalculation(1, "rate * m", 1, 1, 1, 1, 1, 1, 1, 0, nil, variables, nil)
+    var syntaxErr *calculation.SyntaxError
+    if err := planCalculation(store, id, "rate * m", variables, nil); !errors.As(err, &syntaxErr) || !errors.Is(err, calculation.ErrUnknownName) {
+        t.Errorf("Expected an unknown name error, got %v", err)
+    }
+}
+
+func TestInternalTaskExactResult(t *testing.T) {
+    // Точный результат подзадачи сохраняется как результат вычисления без округления до float64
+    store := database.NewMemoryStore()
+    precision := calculation.Decimal(30, "")
+    id, _ := store.InsertCalculation(1, "0.1 + 0.2", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, &precision)
+    if err := planCalculation(store, id, "0.1 + 0.2", nil, &precision); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+
+    req := httptest.NewRequest(http.MethodGet, "/internal/task?agent=test&wait=0", nil)
+    rr := httptest.NewRecorder()
+    handleInternalTask(store).ServeHTTP(rr, req)
+
+    var assignment models.TaskAssignment
+    if err := json.NewDecoder(rr.Body).Decode(&assignment); err != nil || assignment.Precision == nil || assignment.Precision.Scale == nil || *assignment.Precision.Scale != 30 {
+        t.Fatalf("Expected an assignment with precision, got %+v, %v", assignment, err)
+    }
+
+    body, _ := json.Marshal(models.TaskResult{TaskID: assignment.TaskID, Result: 0.3, ExactResult: "0.3"})
+    req = httptest.NewRequest(http.MethodPost, "/internal/task", bytes.NewReader(body))
+    rr = httptest.NewRecorder()
+    handleInternalTask(store).ServeHTTP(rr, req)
+    if rr.Code != http.StatusNoContent {
+        t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+    }
+    if result, err := store.GetCalculationResultByID(id); err != nil || result.ExactResult != "0.3" {
+        t.Errorf("Expected exact result 0.3, got %+v, %v", result, err)
+    }
+
+    // Выражение без операций округляется по точности сразу при разбиении
+    precision = calculation.Decimal(2, "")
+    id, _ = store.InsertCalculation(1, "2.345", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, &precision)
+    if err := planCalculation(store, id, "2.345", nil, &precision); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "completed" || result.ExactResult != "2.34" || result.Result != 2.34 {
+        t.Errorf("Expected completed calculation with exact result 2.34, got %+v", result)
+    }
+
+    // В рациональном режиме литерал сохраняется несократимой дробью
+    rational := &calculation.Precision{Mode: calculation.ModeRational}
+    id, _ = store.InsertCalculation(1, "0.25", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, rational)
+    if err := planCalculation(store, id, "0.25", nil, rational); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "completed" || result.ExactResult != "1/4" || result.Result != 0.25 {
+        t.Errorf("Expected completed calculation with exact result 1/4, got %+v", result)
+    }
+
+    // Иррациональная константа не имеет точного значения, и вычисление завершается ошибкой при разбиении
+    id, _ = store.InsertCalculation(1, "pi * 2", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, rational)
+    if err := planCalculation(store, id, "pi * 2", nil, rational); !errors.Is(err, calculation.ErrInexact) {
+        t.Errorf("Expected ErrInexact for pi in rational mode, got %v", err)
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "error" {
+        t.Errorf("Expected the calculation with pi to fail, got %+v", result)
+    }
+}
+
+func TestCalculateTotalOperationTime(t *testing.T) {
+    tests := []struct {
+        operation string
+        want      int
+    }{
+        {operation: "2 + 3", want: 1},
+        {operation: "7 / 2", want: 4},
+        {operation: "7 // 2", want: 7},
+        {operation: "2 ^ 10", want: 5},
+        {operation: "7 % 3", want: 6},
+        {operation: "2 * 3 - 1", want: 5},
+        {operation: "sqrt(2) + max(1, 2)", want: 9},
+        {operation: "exp(1e5)", want: 0},
+        {operation: "1e-3 + 2", want: 1},
+        {operation: "-5 * 2", want: 3},
+        {operation: "2 * -3 - -(1 + 1)", want: 6},
+        {operation: "sqrt(-4)", want: 8},
+        {operation: "2 +", want: 0},
+        {operation: "[10/3] ^ 2", want: 5},
+    }
+
+    for _, tt := range tests {
+        if got := calculateTotalOperationTime(tt.operation, 1, 2, 3, 4, 5, 6, 7, map[string]int{"sqrt": 8}); got != tt.want {
+            t.Errorf("calculateTotalOperationTime(%q) = %d, want %d", tt.operation, got, tt.want)
+        }
+    }
+}
+
+func TestTaskTimeout(t *testing.T) {
+    if got := taskTimeout(0); got != defaultTaskTimeout {
+        t.Errorf("Expected the default timeout %v, got %v", defaultTaskTimeout, got)
+    }
+    if got := taskTimeout(45); got != 45*time.Second {
+        t.Errorf("Expected inactive_server_time to be used, got %v", got)
+    }
+    if got := taskTimeout(24 * 60 * 60); got != maxTaskTimeout {
+        t.Errorf("Expected the timeout to be capped at %v, got %v", maxTaskTimeout, got)
+    }
+}
+
+func TestCheckAndRestartFailedOperations(t *testing.T) {
+    store := database.NewMemoryStore()
+    policy := retryPolicy
+    retryPolicy = database.RetryPolicy{MaxAttempts: 3}
+    defer func() { retryPolicy = policy }()
+
+    // Две подзадачи выполняются калькуляторами, время ожидания еще не истекло
+    id, _ := store.InsertCalculation(1, "2*3 + 4*5", 0, 0, 0, 0, 0, 0, 0, 60, nil, nil, nil)
+    if err := planCalculation(store, id, "2*3 + 4*5", nil, nil); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+    claimed, _ := store.ClaimTasks(instanceID, 2, time.Minute)
+    store.UpdateTaskStatusToWork(claimed[0].TaskID, instanceID, "calculator1")
+    store.UpdateTaskStatusToWork(claimed[1].TaskID, instanceID, "calculator2")
+
+    checkAndRestartFailedOperations(store, nil)
+    if working, _ := store.FetchWorkingTasks(); len(working) != 2 {
+        t.Fatalf("Expected tasks within inactive_server_time to keep running, got %+v", working)
+    }
+
+    // Калькулятор, пропустивший heartbeat, не дождется ожидания: его подзадача сразу возвращается в очередь
+    checkAndRestartFailedOperations(store, []string{"calculator1"})
+    working, _ := store.FetchWorkingTasks()
+    if len(working) != 1 || working[0].Agent != "calculator2" {
+        t.Fatalf("Expected only the task of calculator2 to keep running, got %+v", working)
+    }
+    task, _ := store.GetTaskByID(claimed[0].TaskID)
+    result, _ := store.GetCalculationResultByID(id)
+    if task.Status != "ready" || result.Attempts != 1 || !strings.Contains(result.Error, "calculator1") {
+        t.Errorf("Expected a retried task and a counted attempt, got task %+v, calculation %+v", task, result)
+    }
+}
+
+func TestCheckAndRestartPulledTask(t *testing.T) {
+    store := database.NewMemoryStore()
+    policy := retryPolicy
+    retryPolicy = database.RetryPolicy{MaxAttempts: 3}
+    defer func() { retryPolicy = policy }()
+    agents = newAgentRegistry()
+    defer func() { agents = newAgentRegistry() }()
+
+    id, _ := store.InsertCalculation(1, "2+3", 0, 0, 0, 0, 0, 0, 0, 60, nil, nil, nil)
+    if err := planCalculation(store, id, "2+3", nil, nil); err != nil {
+        t.Fatalf("planCalculation returned error: %v", err)
+    }
+
+    // Зарегистрированный калькулятор забирает подзадачу по HTTP, и она запоминается за ним
+    now := time.Now()
+    agents.register(agentInfo{Name: "puller", GRPCAddress: "localhost:0"}, now)
+    req := httptest.NewRequest(http.MethodGet, "/internal/task?agent=puller&wait=0", nil)
+    rr := httptest.NewRecorder()
+    handleInternalTask(store).ServeHTTP(rr, req)
+    var assignment models.TaskAssignment
+    if err := json.NewDecoder(rr.Body).Decode(&assignment); err != nil || assignment.ID != id {
+        t.Fatalf("Expected an assignment of calculation %d, got %+v, %v", id, assignment, err)
+    }
+
+    // Пропустив heartbeat, калькулятор удаляется из реестра, и его подзадача сразу возвращается в очередь
+    lost := agents.evict(now.Add(2*heartbeatTimeout), heartbeatTimeout)
+    if len(lost) != 1 || lost[0] != "puller" {
+        t.Fatalf("Expected puller to be evicted, got %v", lost)
+    }
+    checkAndRestartFailedOperations(store, lost)
+    task, _ := store.GetTaskByID(assignment.TaskID)
+    result, _ := store.GetCalculationResultByID(id)
+    if task.Status != "ready" || result.Attempts != 1 || !strings.Contains(result.Error, "puller") {
+        t.Errorf("Expected the pulled task to be retried, got task %+v, calculation %+v", task, result)
+    }
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"database/sql" // Для работы с базами данных SQL
+	"fmt"          // Для форматированного вывода
+	"strconv"      // Для разбора количества шагов
+
+	"calculatorapi/utility/database" // Пакет для работы с базой данных
+)
+
+// runMigrateCommand выполняет подкоманду "migrate":
+//
+//	migrate up          применить все новые миграции
+//	migrate down [N]    отменить N последних миграций (по умолчанию одну)
+//	migrate version     показать версию схемы базы данных и программы
+func runMigrateCommand(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [N] | version")
+	}
+
+	switch args[0] {
+	case "up":
+		return database.MigrateUp(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = parsed
+		}
+		return database.MigrateDown(db, steps)
+	case "version":
+		current, latest, err := database.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Database schema version: %d, binary schema version: %d\n", current, latest)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
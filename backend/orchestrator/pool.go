@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log"  // Для логирования
+	"sync" // Для синхронизации доступа к пулу
+	"time" // Для работы со временем
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+)
+
+const (
+	agentKeepaliveTime = 30 * time.Second // Интервал ping простаивающего соединения с калькулятором
+	agentKeepaliveWait = 10 * time.Second // Время ожидания ответа на ping, после которого соединение разрывается
+)
+
+// Ограничение времени одного вызова калькулятора
+var agentRPCTimeout = 5 * time.Second
+
+// Политика повторов вызовов калькулятора: повторяются вызовы, завершившиеся UNAVAILABLE. Соединение может
+// оборваться и после того, как калькулятор принял подзадачу, но калькулятор не запускает подзадачу, которая
+// у него уже выполняется, поэтому повтор лишь подтверждает ее. ResourceExhausted не повторяется -
+// подзадача отправляется другому калькулятору.
+var agentServiceConfig = `{
+	"methodConfig": [{
+		"name": [{"service": "` + pb.CalculatorService_ServiceDesc.ServiceName + `"}],
+		"retryPolicy": {
+			"maxAttempts": 3,
+			"initialBackoff": "0.2s",
+			"maxBackoff": "2s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
+// connPool хранит долгоживущие gRPC соединения с калькуляторами по их адресам.
+// Соединение переподключается само, поэтому создается один раз и закрывается,
+// когда калькулятор удаляется из реестра.
+type connPool struct {
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// Глобальный пул соединений с калькуляторами
+var agentConns = newConnPool()
+
+func newConnPool() *connPool {
+	return &connPool{conns: make(map[string]*grpc.ClientConn)}
+}
+
+// get возвращает соединение с калькулятором по адресу address, создавая его при первом обращении.
+// Подключение устанавливается в фоне, ошибки подключения проявляются при вызовах.
+func (p *connPool) get(address string) (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conn, ok := p.conns[address]; ok {
+		return conn, nil
+	}
+
+	conn, err := grpc.Dial(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                agentKeepaliveTime,
+			Timeout:             agentKeepaliveWait,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithDefaultServiceConfig(agentServiceConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[address] = conn
+	return conn, nil
+}
+
+// client возвращает клиента CalculatorService для калькулятора по адресу address.
+func (p *connPool) client(address string) (pb.CalculatorServiceClient, error) {
+	conn, err := p.get(address)
+	if err != nil {
+		return nil, err
+	}
+	return pb.NewCalculatorServiceClient(conn), nil
+}
+
+// prune закрывает соединения с адресами, которых больше нет среди калькуляторов реестра.
+func (p *connPool) prune(registered []agentInfo) {
+	keep := make(map[string]bool, len(registered))
+	for _, agent := range registered {
+		keep[agent.GRPCAddress] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for address, conn := range p.conns {
+		if keep[address] {
+			continue
+		}
+		if err := conn.Close(); err != nil {
+			log.Printf("Error closing connection to %s: %v", address, err)
+		}
+		delete(p.conns, address)
+	}
+}
+
+// closeAll закрывает все соединения пула.
+func (p *connPool) closeAll() {
+	p.prune(nil)
+}
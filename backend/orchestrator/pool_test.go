@@ -0,0 +1,95 @@
+package main
+
+import (
+    "context"
+    "net"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/status"
+    pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+)
+
+// flakyAgent отвечает UNAVAILABLE на первый вызов PerformCalculation, а с hang зависает до отмены вызова
+type flakyAgent struct {
+    pb.UnimplementedCalculatorServiceServer
+    calls atomic.Int32
+    hang  bool
+}
+
+func (a *flakyAgent) PerformCalculation(ctx context.Context, req *pb.CalculationRequest) (*pb.CalculationResponse, error) {
+    if a.hang {
+        <-ctx.Done()
+        return nil, ctx.Err()
+    }
+    if a.calls.Add(1) == 1 {
+        return nil, status.Error(codes.Unavailable, "temporarily unavailable")
+    }
+    return &pb.CalculationResponse{Id: req.Id}, nil
+}
+
+// serveAgent запускает gRPC сервер калькулятора на свободном порту и возвращает его адрес.
+func serveAgent(t *testing.T, agent pb.CalculatorServiceServer) string {
+    lis, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    grpcServer := grpc.NewServer()
+    pb.RegisterCalculatorServiceServer(grpcServer, agent)
+    go grpcServer.Serve(lis)
+    t.Cleanup(grpcServer.Stop)
+    return lis.Addr().String()
+}
+
+func TestConnPoolReusesConnections(t *testing.T) {
+    pool := newConnPool()
+    defer pool.closeAll()
+
+    first, err := pool.get("127.0.0.1:50051")
+    if err != nil {
+        t.Fatalf("get returned error: %v", err)
+    }
+    if second, _ := pool.get("127.0.0.1:50051"); second != first {
+        t.Error("Expected the connection to be reused")
+    }
+
+    // Соединения калькуляторов, которых нет в реестре, закрываются
+    pool.get("127.0.0.1:50052")
+    pool.prune([]agentInfo{{Name: "calculator2", GRPCAddress: "127.0.0.1:50052"}})
+    if _, ok := pool.conns["127.0.0.1:50051"]; ok || pool.conns["127.0.0.1:50052"] == nil {
+        t.Error("Expected only the connection of the registered agent to be kept")
+    }
+}
+
+func TestStartCalculationGRPCRetriesUnavailable(t *testing.T) {
+    agent := &flakyAgent{}
+    address := serveAgent(t, agent)
+    defer agentConns.prune(nil)
+
+    if !startCalculationGRPC(address, &pb.CalculationRequest{Id: 1, TaskId: 2, Operation: "1 + 1"}) {
+        t.Fatal("Expected the calculation to be started after a retry")
+    }
+    if calls := agent.calls.Load(); calls != 2 {
+        t.Errorf("Expected two calls, got %d", calls)
+    }
+}
+
+func TestStartCalculationGRPCDeadline(t *testing.T) {
+    address := serveAgent(t, &flakyAgent{hang: true})
+    defer agentConns.prune(nil)
+
+    timeout := agentRPCTimeout
+    agentRPCTimeout = 100 * time.Millisecond
+    defer func() { agentRPCTimeout = timeout }()
+
+    start := time.Now()
+    if startCalculationGRPC(address, &pb.CalculationRequest{Id: 1, TaskId: 2, Operation: "1 + 1"}) {
+        t.Error("Expected a hung agent to fail the submission")
+    }
+    if elapsed := time.Since(start); elapsed > 2*time.Second {
+        t.Errorf("Expected the call to give up after the deadline, took %v", elapsed)
+    }
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"      // Для работы с контекстом запросов gRPC
+	"errors"       // Для проверки ошибок базы данных
+	"log"          // Для логирования
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+	"calculatorapi/utility/database" // Пакет для работы с базой данных
+	"calculatorapi/utility/models"   // Пакет с моделями данных
+)
+
+// orchestratorServer реализует сервис OrchestratorService, через который калькуляторы
+// сообщают о ходе вычислений. Оркестратор - единственный компонент, изменяющий таблицу calculations.
+type orchestratorServer struct {
+	pb.UnimplementedOrchestratorServiceServer
+	store database.Store
+}
+
+// ReportStatus принимает переход вычисления или подзадачи в статус "work", "completed" или "error".
+// Отчеты принимаются только от калькуляторов из реестра, а завершенные вычисления хранилище не изменяет.
+func (s *orchestratorServer) ReportStatus(ctx context.Context, report *pb.StatusReport) (*pb.StatusReportAck, error) {
+	id, taskID := int(report.Id), int(report.TaskId)
+	if _, ok := agents.get(report.Agent); !ok {
+		log.Printf("Rejected status report for calculation ID %d from unregistered agent %q", id, report.Agent)
+		return nil, status.Errorf(codes.PermissionDenied, "agent %q is not registered", report.Agent)
+	}
+	log.Printf("Agent %q reported status %q for calculation ID %d, task ID %d", report.Agent, report.Status, id, taskID)
+
+	var err error
+	switch {
+	case taskID != 0 && report.Status == "work":
+		// Подзадача переводится в статус 'work' оркестратором при выдаче, подтверждение не меняет состояние
+	case taskID != 0 && (report.Status == "completed" || report.Status == "error"):
+		err = saveTaskResult(s.store, models.TaskResult{TaskID: taskID, Result: report.Result, ExactResult: report.ExactResult, Error: report.Error})
+	case report.Status == "work":
+		err = s.store.UpdateCalculationStatusToWork(id)
+	case report.Status == "completed":
+		err = s.store.UpdateCalculation(id, report.Result, report.ExactResult, "completed")
+	case report.Status == "error":
+		err = s.store.UpdateCalculationError(id, report.Error)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown status %q", report.Status)
+	}
+
+	if errors.Is(err, database.ErrTaskNotActive) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		log.Printf("Error saving status reported by agent %q: %v", report.Agent, err)
+		return nil, status.Error(codes.Internal, "failed to save status")
+	}
+	return &pb.StatusReportAck{}, nil
+}
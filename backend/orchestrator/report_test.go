@@ -0,0 +1,135 @@
+package main
+
+import (
+    "context"
+    "net"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "calculatorapi/utility/database"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+    pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+)
+
+func TestReportStatusCalculationError(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    mock.ExpectExec("UPDATE calculations SET result = NULL, status = 'error'").
+        WithArgs("division by zero", sqlmock.AnyArg(), 7).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+
+    agents = newAgentRegistry()
+    agents.register(agentInfo{Name: "calculator1"}, time.Now())
+
+    server := &orchestratorServer{store: database.NewPostgresStore(db)}
+    report := &pb.StatusReport{Id: 7, Status: "error", Error: "division by zero", Agent: "calculator1"}
+    if _, err := server.ReportStatus(context.Background(), report); err != nil {
+        t.Fatalf("ReportStatus returned error: %v", err)
+    }
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("There were unfulfilled expectations: %s", err)
+    }
+}
+
+func TestReportStatusInactiveTask(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    // Подзадача уже выполнена другим калькулятором
+    mock.ExpectBegin()
+    mock.ExpectQuery("UPDATE tasks SET status = 'completed'").
+        WithArgs(5.0, sqlmock.AnyArg(), 3).
+        WillReturnRows(sqlmock.NewRows([]string{"calculation_id", "parent_id", "parent_position"}))
+    mock.ExpectRollback()
+
+    agents = newAgentRegistry()
+    agents.register(agentInfo{Name: "calculator2"}, time.Now())
+
+    server := &orchestratorServer{store: database.NewPostgresStore(db)}
+    report := &pb.StatusReport{Id: 1, TaskId: 3, Status: "completed", Result: 5, Agent: "calculator2"}
+    _, err = server.ReportStatus(context.Background(), report)
+    if status.Code(err) != codes.FailedPrecondition {
+        t.Errorf("Expected FailedPrecondition, got %v", err)
+    }
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("There were unfulfilled expectations: %s", err)
+    }
+}
+
+func TestReportStatusUnregisteredAgent(t *testing.T) {
+    // Отчет без регистрации не доходит до хранилища
+    agents = newAgentRegistry()
+    store := database.NewMemoryStore()
+    id, _ := store.InsertCalculation(1, "2+2", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+
+    server := &orchestratorServer{store: store}
+    report := &pb.StatusReport{Id: int32(id), Status: "completed", Result: 5, Agent: "impostor"}
+    if _, err := server.ReportStatus(context.Background(), report); status.Code(err) != codes.PermissionDenied {
+        t.Errorf("Expected PermissionDenied, got %v", err)
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "created" {
+        t.Errorf("Expected the calculation to stay created, got %+v", result)
+    }
+}
+
+// serveOrchestrator запускает gRPC сервис оркестратора с проверкой общего секрета калькуляторов
+// и возвращает подключенного к нему клиента.
+func serveOrchestrator(t *testing.T, store database.Store) pb.OrchestratorServiceClient {
+    lis, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    grpcServer := grpc.NewServer(grpc.UnaryInterceptor(agentTokenUnaryInterceptor), grpc.StreamInterceptor(agentTokenStreamInterceptor))
+    pb.RegisterOrchestratorServiceServer(grpcServer, &orchestratorServer{store: store})
+    go grpcServer.Serve(lis)
+    t.Cleanup(grpcServer.Stop)
+
+    conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { conn.Close() })
+    return pb.NewOrchestratorServiceClient(conn)
+}
+
+func TestReportStatusAgentToken(t *testing.T) {
+    agents = newAgentRegistry()
+    agents.register(agentInfo{Name: "calculator1"}, time.Now())
+    defer func() { agents = newAgentRegistry() }()
+    store := database.NewMemoryStore()
+    id, _ := store.InsertCalculation(1, "2+2", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    client := serveOrchestrator(t, store)
+
+    // Без общего секрета имя зарегистрированного калькулятора не дает права прислать результат
+    report := &pb.StatusReport{Id: int32(id), Status: "completed", Result: 5, Agent: "calculator1"}
+    ctx := metadata.AppendToOutgoingContext(context.Background(), agentTokenMetadata, "guess")
+    for _, ctx := range []context.Context{context.Background(), ctx} {
+        if _, err := client.ReportStatus(ctx, report); status.Code(err) != codes.Unauthenticated {
+            t.Errorf("Expected Unauthenticated, got %v", err)
+        }
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "created" {
+        t.Errorf("Expected the calculation to stay created, got %+v", result)
+    }
+
+    ctx = metadata.AppendToOutgoingContext(context.Background(), agentTokenMetadata, string(agentToken))
+    report.Result = 4
+    if _, err := client.ReportStatus(ctx, report); err != nil {
+        t.Fatalf("ReportStatus returned error: %v", err)
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "completed" || result.Result != 4 {
+        t.Errorf("Expected completed calculation with result 4, got %+v", result)
+    }
+}
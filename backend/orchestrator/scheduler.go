@@ -0,0 +1,246 @@
+package main
+
+import (
+	"sort" // Для упорядочивания калькуляторов
+	"sync" // Для синхронизации состояния стратегий
+	"time" // Для работы со временем
+
+	"calculatorapi/utility/config" // Пакет с настройками оркестратора
+)
+
+// scheduler выбирает калькуляторы для подзадачи. Подзадача отправляется первому кандидату,
+// а при отказе - следующим по порядку.
+type scheduler interface {
+	// candidates возвращает калькуляторы, способные принять подзадачу с ожидаемым временем cost секунд,
+	// в порядке предпочтения
+	candidates(list []agentInfo, cost int, now time.Time) []agentInfo
+	// assigned учитывает подзадачу с ожидаемым временем cost секунд, принятую калькулятором name
+	assigned(name string, cost int, now time.Time)
+}
+
+// Глобальная стратегия выбора калькуляторов; задается конфигурацией
+var agentScheduler scheduler = newScheduler(config.SchedulerLeastLoaded, nil)
+
+// newScheduler создает стратегию по ее имени из конфигурации. weights - веса калькуляторов для weighted.
+func newScheduler(name string, weights map[string]int) scheduler {
+	switch name {
+	case config.SchedulerRoundRobin:
+		return &roundRobinScheduler{assignments: newAssignments()}
+	case config.SchedulerWeighted:
+		return &weightedScheduler{assignments: newAssignments(), weights: weights, current: make(map[string]int)}
+	default:
+		return &leastLoadedScheduler{assignments: newAssignments()}
+	}
+}
+
+// assignment - подзадача, отправленная калькулятору, и время, к которому она должна завершиться.
+type assignment struct {
+	at    time.Time
+	until time.Time
+}
+
+// assignments запоминает подзадачи, отправленные калькуляторам. Heartbeat сообщает загрузку с опозданием,
+// поэтому подзадачи, отправленные после него, добавляются к отчету калькулятора. Запись забывается,
+// когда истекает ожидаемое время операции.
+type assignments struct {
+	mu     sync.Mutex
+	agents map[string][]assignment
+}
+
+func newAssignments() *assignments {
+	return &assignments{agents: make(map[string][]assignment)}
+}
+
+// assigned запоминает подзадачу калькулятора name.
+func (a *assignments) assigned(name string, cost int, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.agents[name] = append(a.agents[name], assignment{at: now, until: now.Add(time.Duration(cost) * time.Second)})
+}
+
+// pending возвращает число подзадач, отправленных калькулятору после его последнего heartbeat,
+// и оставшееся ожидаемое время всех его подзадач в секундах.
+func (a *assignments) pending(agent agentInfo, now time.Time) (int, float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	count, seconds := 0, 0.0
+	active := a.agents[agent.Name][:0]
+	for _, task := range a.agents[agent.Name] {
+		if !task.until.After(now) {
+			continue
+		}
+		active = append(active, task)
+		seconds += task.until.Sub(now).Seconds()
+		if task.at.After(agent.LastSeen) {
+			count++
+		}
+	}
+	if len(active) == 0 {
+		delete(a.agents, agent.Name)
+	} else {
+		a.agents[agent.Name] = active
+	}
+	return count, seconds
+}
+
+// available возвращает калькуляторы, у которых с учетом отправленных после heartbeat подзадач остались свободные горутины.
+func (a *assignments) available(list []agentInfo, now time.Time) []agentInfo {
+	result := make([]agentInfo, 0, len(list))
+	for _, agent := range list {
+		count, _ := a.pending(agent, now)
+		if agent.MaxGoroutines > 0 && agent.CurrentGoroutines+count >= agent.MaxGoroutines {
+			continue
+		}
+		result = append(result, agent)
+	}
+	return result
+}
+
+// capacity возвращает число горутин калькулятора; калькулятор без отчета считается однопоточным.
+func capacity(agent agentInfo) int {
+	if agent.MaxGoroutines > 0 {
+		return agent.MaxGoroutines
+	}
+	return 1
+}
+
+// leastLoadedScheduler предпочитает калькулятор, который раньше других освободится для подзадачи:
+// с наименьшим ожидаемым временем уже отправленных операций вместе с новой на одну горутину,
+// а при равенстве - с наименьшей долей занятых горутин.
+type leastLoadedScheduler struct {
+	*assignments
+}
+
+func (s *leastLoadedScheduler) candidates(list []agentInfo, cost int, now time.Time) []agentInfo {
+	type scored struct {
+		agent agentInfo
+		wait  float64 // Ожидаемое время работы на одну горутину, секунд
+		busy  float64 // Доля занятых горутин
+	}
+
+	available := s.available(list, now)
+	scores := make([]scored, len(available))
+	for i, agent := range available {
+		count, seconds := s.pending(agent, now)
+		slots := float64(capacity(agent))
+		scores[i] = scored{
+			agent: agent,
+			wait:  (seconds + float64(cost)) / slots,
+			busy:  float64(agent.CurrentGoroutines+count) / slots,
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].wait != scores[j].wait {
+			return scores[i].wait < scores[j].wait
+		}
+		return scores[i].busy < scores[j].busy
+	})
+
+	result := make([]agentInfo, len(scores))
+	for i, score := range scores {
+		result[i] = score.agent
+	}
+	return result
+}
+
+// roundRobinScheduler отправляет подзадачи калькуляторам по очереди, пропуская полностью загруженные.
+type roundRobinScheduler struct {
+	*assignments
+	mu   sync.Mutex
+	next int
+}
+
+func (s *roundRobinScheduler) candidates(list []agentInfo, cost int, now time.Time) []agentInfo {
+	available := s.available(list, now)
+	if len(available) == 0 {
+		return available
+	}
+
+	s.mu.Lock()
+	start := s.next % len(available)
+	s.next++
+	s.mu.Unlock()
+
+	return append(available[start:], available[:start]...)
+}
+
+// weightedScheduler распределяет подзадачи пропорционально весам калькуляторов (плавный взвешенный
+// round-robin): при каждом выборе очередь каждого доступного калькулятора растет на его вес, а у выбранного
+// уменьшается на сумму весов, поэтому сумма очередей не меняется и выбор не уходит от заданных весов.
+// Изменения умножаются на ожидаемое время подзадачи, поэтому калькуляторам достается доля времени операций,
+// а не числа подзадач, равная их весу.
+type weightedScheduler struct {
+	*assignments
+	mu      sync.Mutex
+	weights map[string]int // Веса из конфигурации по имени калькулятора
+	current map[string]int // Текущая очередь калькуляторов
+	picked  string         // Калькулятор, выбранный последним вызовом candidates
+	charge  int            // Списанная с его очереди сумма весов, умноженная на время подзадачи
+}
+
+// weight возвращает вес калькулятора из конфигурации или, если он не задан, его число горутин.
+func (s *weightedScheduler) weight(agent agentInfo) int {
+	if weight, ok := s.weights[agent.Name]; ok {
+		return weight
+	}
+	return capacity(agent)
+}
+
+func (s *weightedScheduler) candidates(list []agentInfo, cost int, now time.Time) []agentInfo {
+	available := s.available(list, now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Калькуляторы, удаленные из реестра, не сохраняют очередь
+	registered := make(map[string]bool, len(list))
+	for _, agent := range list {
+		registered[agent.Name] = true
+	}
+	for name := range s.current {
+		if !registered[name] {
+			delete(s.current, name)
+		}
+	}
+
+	s.picked = ""
+	if len(available) == 0 {
+		return available
+	}
+
+	total := 0
+	for _, agent := range available {
+		s.current[agent.Name] += s.weight(agent) * operationCost(cost)
+		total += s.weight(agent)
+	}
+	sort.SliceStable(available, func(i, j int) bool {
+		return s.current[available[i].Name] > s.current[available[j].Name]
+	})
+
+	// Очередь первого кандидата уменьшается сразу, даже если подзадачу не примет ни один калькулятор
+	s.picked, s.charge = available[0].Name, total*operationCost(cost)
+	s.current[s.picked] -= s.charge
+	return available
+}
+
+func (s *weightedScheduler) assigned(name string, cost int, now time.Time) {
+	s.assignments.assigned(name, cost, now)
+
+	// Если первый кандидат отказал, списание переносится на калькулятор, принявший подзадачу
+	s.mu.Lock()
+	if s.picked != "" && name != s.picked {
+		s.current[s.picked] += s.charge
+		s.current[name] -= s.charge
+	}
+	s.picked = ""
+	s.mu.Unlock()
+}
+
+// operationCost возвращает вес подзадачи в очереди: операция без длительности считается секундной.
+func operationCost(cost int) int {
+	if cost < 1 {
+		return 1
+	}
+	return cost
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+    "testing"
+    "time"
+
+    "calculatorapi/utility/config"
+)
+
+// names возвращает имена калькуляторов в порядке списка.
+func names(list []agentInfo) []string {
+    result := make([]string, len(list))
+    for i, agent := range list {
+        result[i] = agent.Name
+    }
+    return result
+}
+
+func TestLeastLoadedScheduler(t *testing.T) {
+    now := time.Now()
+    list := []agentInfo{
+        {Name: "calculator1", MaxGoroutines: 2, CurrentGoroutines: 1, LastSeen: now},
+        {Name: "calculator2", MaxGoroutines: 4, CurrentGoroutines: 0, LastSeen: now},
+        {Name: "calculator3", MaxGoroutines: 1, CurrentGoroutines: 1, LastSeen: now},
+    }
+    s := newScheduler(config.SchedulerLeastLoaded, nil)
+
+    // Полностью загруженный калькулятор пропускается, свободный предпочитается занятому
+    if got := names(s.candidates(list, 10, now)); len(got) != 2 || got[0] != "calculator2" || got[1] != "calculator1" {
+        t.Fatalf("Expected calculator2, calculator1, got %v", got)
+    }
+
+    // Длинные операции, отправленные после heartbeat, делают калькулятор менее предпочтительным
+    s.assigned("calculator2", 60, now.Add(time.Second))
+    s.assigned("calculator2", 60, now.Add(time.Second))
+    if got := names(s.candidates(list, 10, now.Add(2*time.Second))); got[0] != "calculator1" {
+        t.Errorf("Expected calculator1 after long operations on calculator2, got %v", got)
+    }
+
+    // Отправленные подзадачи учитываются в загрузке, пока калькулятор не пришлет новый отчет
+    s.assigned("calculator1", 1, now.Add(time.Second))
+    if got := names(s.candidates(list, 10, now.Add(1500*time.Millisecond))); len(got) != 1 || got[0] != "calculator2" {
+        t.Errorf("Expected calculator1 to be full until its next heartbeat, got %v", got)
+    }
+
+    // После ожидаемого времени операции запись забывается
+    if got := names(s.candidates(list, 10, now.Add(2*time.Minute))); len(got) != 2 || got[0] != "calculator2" {
+        t.Errorf("Expected expired assignments to be forgotten, got %v", got)
+    }
+}
+
+func TestRoundRobinScheduler(t *testing.T) {
+    now := time.Now()
+    list := []agentInfo{{Name: "calculator1"}, {Name: "calculator2"}, {Name: "calculator3", MaxGoroutines: 1, CurrentGoroutines: 1}}
+    s := newScheduler(config.SchedulerRoundRobin, nil)
+
+    var first []string
+    for i := 0; i < 4; i++ {
+        first = append(first, s.candidates(list, 1, now)[0].Name)
+    }
+    want := []string{"calculator1", "calculator2", "calculator1", "calculator2"}
+    for i := range want {
+        if first[i] != want[i] {
+            t.Fatalf("Expected %v, got %v", want, first)
+        }
+    }
+}
+
+func TestWeightedScheduler(t *testing.T) {
+    now := time.Now()
+    list := []agentInfo{{Name: "calculator1", MaxGoroutines: 100}, {Name: "calculator2", MaxGoroutines: 100}}
+    s := newScheduler(config.SchedulerWeighted, map[string]int{"calculator1": 3, "calculator2": 1})
+
+    // Подзадачи одинаковой длительности делятся в пропорции весов
+    counts := map[string]int{}
+    for i := 0; i < 8; i++ {
+        name := s.candidates(list, 1, now)[0].Name
+        s.assigned(name, 1, now)
+        counts[name]++
+    }
+    if counts["calculator1"] != 6 || counts["calculator2"] != 2 {
+        t.Errorf("Expected a 3:1 split, got %v", counts)
+    }
+
+    // Отказ первого кандидата переносит списание на принявший подзадачу калькулятор
+    s = newScheduler(config.SchedulerWeighted, map[string]int{"calculator1": 1, "calculator2": 1})
+    if got := s.candidates(list, 1, now); got[0].Name != "calculator1" {
+        t.Fatalf("Expected calculator1 first, got %v", names(got))
+    }
+    s.assigned("calculator2", 1, now)
+    if got := s.candidates(list, 1, now); got[0].Name != "calculator1" {
+        t.Errorf("Expected calculator1 after calculator2 took its task, got %v", names(got))
+    }
+
+    // Длинная подзадача отодвигает калькулятор на соответствующее число коротких
+    s = newScheduler(config.SchedulerWeighted, map[string]int{"calculator1": 1, "calculator2": 1})
+    name := s.candidates(list, 3, now)[0].Name
+    s.assigned(name, 3, now)
+    for i := 0; i < 3; i++ {
+        next := s.candidates(list, 1, now)[0].Name
+        if next == name {
+            t.Fatalf("Expected %s to wait after a long operation, chosen again at step %d", name, i)
+        }
+        s.assigned(next, 1, now)
+    }
+}
+
+func TestWeightedSchedulerDistribution(t *testing.T) {
+    now := time.Now()
+    list := []agentInfo{{Name: "a", MaxGoroutines: 1000}, {Name: "b", MaxGoroutines: 1000}, {Name: "c", MaxGoroutines: 1000}}
+    weights := map[string]int{"a": 5, "b": 1, "c": 1}
+    s := newScheduler(config.SchedulerWeighted, weights).(*weightedScheduler)
+
+    // Плавный выбор не отдает тяжелому калькулятору все подзадачи подряд
+    var sequence []string
+    counts := map[string]int{}
+    const picks = 700
+    for i := 0; i < picks; i++ {
+        name := s.candidates(list, 1, now)[0].Name
+        s.assigned(name, 1, now)
+        counts[name]++
+        if i < 7 {
+            sequence = append(sequence, name)
+        }
+
+        // Очереди не растут: их сумма остается нулевой, а каждая ограничена суммой весов
+        sum := 0
+        for _, current := range s.current {
+            sum += current
+            if current > 7 || current < -7 {
+                t.Fatalf("Queue drifted to %v after %d picks", s.current, i+1)
+            }
+        }
+        if sum != 0 {
+            t.Fatalf("Expected queues to sum to zero, got %v after %d picks", s.current, i+1)
+        }
+    }
+
+    want := []string{"a", "a", "b", "a", "c", "a", "a"}
+    for i := range want {
+        if sequence[i] != want[i] {
+            t.Fatalf("Expected smooth sequence %v, got %v", want, sequence)
+        }
+    }
+    for name, weight := range weights {
+        if counts[name] != picks*weight/7 {
+            t.Errorf("Expected %d picks of %s, got %d", picks*weight/7, name, counts[name])
+        }
+    }
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"calculatorapi/utility/config"   // Пакет с настройками оркестратора
+	"calculatorapi/utility/database" // Пакет для работы с хранилищем
+)
+
+// openStore открывает хранилище, выбранное в настройках. Для PostgreSQL база данных создается
+// при отсутствии и мигрируется; SQLite мигрируется при открытии; хранилище в памяти не требует подготовки.
+func openStore(cfg *config.Config) (database.Store, error) {
+	switch cfg.Store {
+	case config.StoreMemory:
+		return database.NewMemoryStore(), nil
+	case config.StoreSQLite:
+		return database.OpenSQLiteStore(cfg.SQLitePath)
+	default:
+		database.InitializeDB(cfg.Database)
+		if _, err := database.SetupDatabase(); err != nil {
+			return nil, err
+		}
+		return database.NewPostgresStore(database.GetDB()), nil
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json" // Для кодирования событий
+	"fmt"           // Для форматированного вывода
+	"log"           // Для логирования
+	"net/http"      // Для работы с HTTP
+	"strconv"       // Для разбора идентификатора вычисления
+	"time"          // Для работы со временем
+
+	"calculatorapi/utility/database" // Пакет для работы с базой данных
+)
+
+// Интервал комментариев, которые не дают прокси закрыть простаивающий поток событий
+const streamKeepAlive = 15 * time.Second
+
+// handleCalculationEvents передает изменения вычислений пользователя как Server-Sent Events:
+// событие "status" с вычислением при каждом переходе его статуса и событие "step" с подзадачей
+// при каждом изменении подзадачи, в том числе с промежуточным результатом, и событие "progress"
+// с операцией, которую калькулятор выполнил и передал через WatchCalculation.
+// С параметром id передаются только события этого вычисления: сначала его текущее состояние,
+// а после перехода в завершенный статус поток закрывается.
+func handleCalculationEvents(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		userId, _ := userIDFromContext(r.Context())
+
+		id := 0
+		if idParam := r.URL.Query().Get("id"); idParam != "" {
+			var err error
+			if id, err = strconv.Atoi(idParam); err != nil {
+				http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Подписка оформляется до чтения текущего состояния, чтобы не пропустить переход между ними
+		events, unsubscribe := calculationEvents.subscribe(userId)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		if id != 0 {
+			calc, ok := fetchOwnedCalculation(w, r, store, id)
+			if !ok {
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			writeEvent(w, "status", calc)
+			if finishedStatus(calc.Status) {
+				flusher.Flush()
+				return
+			}
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case event := <-events:
+				switch {
+				case event.Calculation != nil && (id == 0 || event.Calculation.ID == id):
+					writeEvent(w, "status", event.Calculation)
+					flusher.Flush()
+					if id != 0 && finishedStatus(event.Calculation.Status) {
+						return
+					}
+				case event.Step != nil && (id == 0 || event.Step.CalculationID == id):
+					writeEvent(w, "step", event.Step)
+					flusher.Flush()
+				case event.Progress != nil && (id == 0 || event.Progress.ID == id):
+					writeEvent(w, "progress", event.Progress)
+					flusher.Flush()
+				}
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// writeEvent записывает одно событие в формате Server-Sent Events.
+func writeEvent(w http.ResponseWriter, name string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", name, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+}
+
+// finishedStatus сообщает, что вычисление больше не изменит статус сам; вычисление в статусе 'failed'
+// может только вернуть в очередь администратор.
+func finishedStatus(status string) bool {
+	return status == "completed" || status == "error" || status == "failed" || status == "cancelled"
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json" // Для кодирования и декодирования JSON
+	"errors"        // Для проверки ошибок базы данных
+	"log"           // Для логирования
+	"net/http"      // Для работы с HTTP
+	"strconv"       // Для разбора параметра ожидания
+	"sync"          // Для синхронизации ожидающих запросов
+	"time"          // Для работы со временем
+
+	"calculatorapi/utility/database" // Пакет для работы с базой данных
+	"calculatorapi/utility/models"   // Пакет с моделями данных
+)
+
+// Максимальное время, в течение которого GET /internal/task ждет появления готовой подзадачи
+const maxTaskWait = 30 * time.Second
+
+// taskNotifier будит запросы, ожидающие подзадачи, когда в очереди могли появиться новые готовые подзадачи.
+type taskNotifier struct {
+	mu      sync.Mutex
+	changed chan struct{} // Закрывается при каждом изменении очереди и заменяется новым
+}
+
+// Глобальный уведомитель об изменениях очереди подзадач
+var tasksChanged = &taskNotifier{changed: make(chan struct{})}
+
+// wait возвращает канал, который будет закрыт при следующем изменении очереди.
+func (n *taskNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.changed
+}
+
+// notify сообщает всем ожидающим, что очередь подзадач изменилась.
+func (n *taskNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+// operationTimes возвращает длительности операций вычисления в формате, который ожидают калькуляторы.
+func operationTimes(calc models.CalculationRequest) map[string]int {
+	times := map[string]int{
+		"add_duration":      calc.AddDuration,
+		"subtract_duration": calc.SubtractDuration,
+		"multiply_duration": calc.MultiplyDuration,
+		"divide_duration":   calc.DivideDuration,
+		"power_duration":    calc.PowerDuration,
+		"modulo_duration":   calc.ModuloDuration,
+		"int_divide_duration": calc.IntDivideDuration,
+	}
+	// Длительности функций передаются по имени функции с суффиксом "_duration", например "sqrt_duration"
+	for name, seconds := range calc.FunctionDurations {
+		times[name+"_duration"] = seconds
+	}
+	return times
+}
+
+// handleInternalTask обслуживает протокол получения подзадач калькуляторами.
+// GET выдает одну подзадачу, переводя ее в статус 'work'; если готовых подзадач нет, запрос ждет
+// до wait секунд (по умолчанию и не более maxTaskWait) и завершается ответом 204 No Content.
+// POST принимает результат подзадачи в формате models.TaskResult.
+func handleInternalTask(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			acquireTask(store, w, r)
+		case http.MethodPost:
+			acceptTaskResult(store, w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// acquireTask выдает калькулятору одну готовую подзадачу, дожидаясь ее появления при необходимости.
+func acquireTask(store database.Store, w http.ResponseWriter, r *http.Request) {
+	wait := maxTaskWait
+	if waitParam := r.URL.Query().Get("wait"); waitParam != "" {
+		seconds, err := strconv.Atoi(waitParam)
+		if err != nil || seconds < 0 {
+			http.Error(w, "Invalid wait parameter", http.StatusBadRequest)
+			return
+		}
+		if requested := time.Duration(seconds) * time.Second; requested < wait {
+			wait = requested
+		}
+	}
+	agent := r.URL.Query().Get("agent")
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	for {
+		// Канал берется до попытки, чтобы не пропустить изменение между попыткой и ожиданием
+		changed := tasksChanged.wait()
+
+		task, err := store.ClaimTask(instanceID, agent, leaseDuration)
+		if err != nil {
+			log.Printf("Error claiming task for agent %q: %v", agent, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if task != nil {
+			log.Printf("Task ID %d of calculation ID %d handed out to agent %q", task.TaskID, task.ID, agent)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.TaskAssignment{
+				ID:        task.ID,
+				TaskID:    task.TaskID,
+				Operation: task.Operation,
+				Times:     operationTimes(*task),
+				Variables: task.Variables,
+				Precision: task.Precision,
+			})
+			return
+		}
+
+		select {
+		case <-changed:
+			// Очередь изменилась, пробуем забрать подзадачу снова
+		case <-deadline.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// acceptTaskResult сохраняет результат или ошибку подзадачи, полученные от калькулятора.
+func acceptTaskResult(store database.Store, w http.ResponseWriter, r *http.Request) {
+	var result models.TaskResult
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil || result.TaskID == 0 {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	err := saveTaskResult(store, result)
+	if errors.Is(err, database.ErrTaskNotActive) {
+		// Например, подзадача была возвращена в очередь по таймауту и уже выполнена другим калькулятором
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving result of task ID %d: %v", result.TaskID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// saveTaskResult сохраняет результат или ошибку подзадачи и будит калькуляторы,
+// ожидающие подзадачи: результат мог сделать готовой родительскую подзадачу.
+func saveTaskResult(store database.Store, result models.TaskResult) error {
+	var err error
+	if result.Error != "" {
+		err = store.FailTask(result.TaskID, result.Error)
+	} else {
+		err = store.CompleteTask(result.TaskID, result.Result, result.ExactResult)
+	}
+	if err != nil {
+		return err
+	}
+
+	tasksChanged.notify()
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context" // Для отмены потока операций
+	"log"     // Для логирования
+	"sync"    // Для синхронизации списка наблюдаемых калькуляторов
+	"time"    // Для работы со временем
+
+	pb "calculatorapi/proto/calculator/calculatorapi/proto/calculator"
+	"calculatorapi/utility/database" // Пакет для работы с базой данных
+)
+
+// Пауза перед повторным подключением к потоку операций калькулятора
+const watchRetryInterval = 5 * time.Second
+
+// stepRelay подписывается на операции зарегистрированных калькуляторов через WatchCalculation
+// и передает их в шину событий вычислений. На каждый калькулятор открывается один поток.
+type stepRelay struct {
+	mu       sync.Mutex
+	watching map[string]bool // Калькуляторы, поток операций которых уже читается
+}
+
+// Глобальный ретранслятор операций калькуляторов
+var agentSteps = &stepRelay{watching: make(map[string]bool)}
+
+// start начинает читать операции калькулятора name, если они еще не читаются.
+// Чтение продолжается, пока калькулятор остается в реестре.
+func (r *stepRelay) start(store database.Store, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watching[name] {
+		return
+	}
+	r.watching[name] = true
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.watching, name)
+			r.mu.Unlock()
+		}()
+
+		for {
+			// Адрес читается заново: при повторной регистрации он мог измениться
+			agent, ok := agents.get(name)
+			if !ok {
+				return
+			}
+			if err := relaySteps(store, agent); err != nil {
+				log.Printf("Watching operations of agent %q failed: %v", name, err)
+			}
+			time.Sleep(watchRetryInterval)
+		}
+	}()
+}
+
+// relaySteps читает поток операций калькулятора и публикует их для владельцев вычислений.
+func relaySteps(store database.Store, agent agentInfo) error {
+	client, err := agentConns.client(agent.GRPCAddress)
+	if err != nil {
+		return err
+	}
+
+	// Поток открыт, пока калькулятор работает, поэтому ограничения времени у него нет
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.WatchCalculation(ctx, &pb.WatchRequest{})
+	if err != nil {
+		return err
+	}
+
+	for {
+		step, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		calc, err := store.GetCalculationResultByID(int(step.Id))
+		if err != nil {
+			log.Printf("Error reading calculation ID %d of a step reported by agent %q: %v", step.Id, agent.Name, err)
+			continue
+		}
+		calculationEvents.publish(calculationEvent{UserId: calc.UserId, Progress: &calculationProgress{
+			ID:        int(step.Id),
+			TaskID:    int(step.TaskId),
+			Left:      step.Left,
+			Operator:  step.Operator,
+			Right:     step.Right,
+			Args:      step.Args,
+			Result:    step.Result,
+			Exact:     step.Exact,
+			ElapsedMs: step.ElapsedMs,
+			Agent:     step.Agent,
+		}})
+	}
+}
@@ -21,6 +21,8 @@ const _ = grpc.SupportPackageIsVersion7
 const (
 	CalculatorService_PerformCalculation_FullMethodName = "/calculator.CalculatorService/PerformCalculation"
 	CalculatorService_CheckStatus_FullMethodName        = "/calculator.CalculatorService/CheckStatus"
+	CalculatorService_CancelCalculation_FullMethodName  = "/calculator.CalculatorService/CancelCalculation"
+	CalculatorService_WatchCalculation_FullMethodName   = "/calculator.CalculatorService/WatchCalculation"
 )
 
 // CalculatorServiceClient is the client API for CalculatorService service.
@@ -31,6 +33,10 @@ type CalculatorServiceClient interface {
 	PerformCalculation(ctx context.Context, in *CalculationRequest, opts ...grpc.CallOption) (*CalculationResponse, error)
 	// RPC to check server status
 	CheckStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	// RPC to stop all running operations of a calculation that was cancelled by the user
+	CancelCalculation(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error)
+	// RPC to follow the operations of a calculation as they are performed on this server
+	WatchCalculation(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (CalculatorService_WatchCalculationClient, error)
 }
 
 type calculatorServiceClient struct {
@@ -59,6 +65,47 @@ func (c *calculatorServiceClient) CheckStatus(ctx context.Context, in *StatusReq
 	return out, nil
 }
 
+func (c *calculatorServiceClient) CancelCalculation(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error) {
+	out := new(CancelResponse)
+	err := c.cc.Invoke(ctx, CalculatorService_CancelCalculation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) WatchCalculation(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (CalculatorService_WatchCalculationClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CalculatorService_ServiceDesc.Streams[0], CalculatorService_WatchCalculation_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calculatorServiceWatchCalculationClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CalculatorService_WatchCalculationClient interface {
+	Recv() (*CalculationStep, error)
+	grpc.ClientStream
+}
+
+type calculatorServiceWatchCalculationClient struct {
+	grpc.ClientStream
+}
+
+func (x *calculatorServiceWatchCalculationClient) Recv() (*CalculationStep, error) {
+	m := new(CalculationStep)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // CalculatorServiceServer is the server API for CalculatorService service.
 // All implementations must embed UnimplementedCalculatorServiceServer
 // for forward compatibility
@@ -67,6 +114,10 @@ type CalculatorServiceServer interface {
 	PerformCalculation(context.Context, *CalculationRequest) (*CalculationResponse, error)
 	// RPC to check server status
 	CheckStatus(context.Context, *StatusRequest) (*StatusResponse, error)
+	// RPC to stop all running operations of a calculation that was cancelled by the user
+	CancelCalculation(context.Context, *CancelRequest) (*CancelResponse, error)
+	// RPC to follow the operations of a calculation as they are performed on this server
+	WatchCalculation(*WatchRequest, CalculatorService_WatchCalculationServer) error
 	mustEmbedUnimplementedCalculatorServiceServer()
 }
 
@@ -80,6 +131,12 @@ func (UnimplementedCalculatorServiceServer) PerformCalculation(context.Context,
 func (UnimplementedCalculatorServiceServer) CheckStatus(context.Context, *StatusRequest) (*StatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckStatus not implemented")
 }
+func (UnimplementedCalculatorServiceServer) CancelCalculation(context.Context, *CancelRequest) (*CancelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelCalculation not implemented")
+}
+func (UnimplementedCalculatorServiceServer) WatchCalculation(*WatchRequest, CalculatorService_WatchCalculationServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchCalculation not implemented")
+}
 func (UnimplementedCalculatorServiceServer) mustEmbedUnimplementedCalculatorServiceServer() {}
 
 // UnsafeCalculatorServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -129,6 +186,45 @@ func _CalculatorService_CheckStatus_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CalculatorService_CancelCalculation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).CancelCalculation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalculatorService_CancelCalculation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).CancelCalculation(ctx, req.(*CancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_WatchCalculation_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CalculatorServiceServer).WatchCalculation(m, &calculatorServiceWatchCalculationServer{stream})
+}
+
+type CalculatorService_WatchCalculationServer interface {
+	Send(*CalculationStep) error
+	grpc.ServerStream
+}
+
+type calculatorServiceWatchCalculationServer struct {
+	grpc.ServerStream
+}
+
+func (x *calculatorServiceWatchCalculationServer) Send(m *CalculationStep) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // CalculatorService_ServiceDesc is the grpc.ServiceDesc for CalculatorService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -144,6 +240,186 @@ var CalculatorService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CheckStatus",
 			Handler:    _CalculatorService_CheckStatus_Handler,
 		},
+		{
+			MethodName: "CancelCalculation",
+			Handler:    _CalculatorService_CancelCalculation_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCalculation",
+			Handler:       _CalculatorService_WatchCalculation_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "calculator.proto",
+}
+
+const (
+	OrchestratorService_ReportStatus_FullMethodName  = "/calculator.OrchestratorService/ReportStatus"
+	OrchestratorService_RegisterAgent_FullMethodName = "/calculator.OrchestratorService/RegisterAgent"
+	OrchestratorService_Heartbeat_FullMethodName     = "/calculator.OrchestratorService/Heartbeat"
+)
+
+// OrchestratorServiceClient is the client API for OrchestratorService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OrchestratorServiceClient interface {
+	// RPC to report that a calculation or sub-task started, completed or failed
+	ReportStatus(ctx context.Context, in *StatusReport, opts ...grpc.CallOption) (*StatusReportAck, error)
+	// RPC to add an agent to the orchestrator registry on startup
+	RegisterAgent(ctx context.Context, in *AgentRegistration, opts ...grpc.CallOption) (*AgentRegistrationAck, error)
+	// RPC to periodically confirm that an agent is alive and report its load
+	Heartbeat(ctx context.Context, in *AgentHeartbeat, opts ...grpc.CallOption) (*AgentHeartbeatAck, error)
+}
+
+type orchestratorServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOrchestratorServiceClient(cc grpc.ClientConnInterface) OrchestratorServiceClient {
+	return &orchestratorServiceClient{cc}
+}
+
+func (c *orchestratorServiceClient) ReportStatus(ctx context.Context, in *StatusReport, opts ...grpc.CallOption) (*StatusReportAck, error) {
+	out := new(StatusReportAck)
+	err := c.cc.Invoke(ctx, OrchestratorService_ReportStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orchestratorServiceClient) RegisterAgent(ctx context.Context, in *AgentRegistration, opts ...grpc.CallOption) (*AgentRegistrationAck, error) {
+	out := new(AgentRegistrationAck)
+	err := c.cc.Invoke(ctx, OrchestratorService_RegisterAgent_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orchestratorServiceClient) Heartbeat(ctx context.Context, in *AgentHeartbeat, opts ...grpc.CallOption) (*AgentHeartbeatAck, error) {
+	out := new(AgentHeartbeatAck)
+	err := c.cc.Invoke(ctx, OrchestratorService_Heartbeat_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OrchestratorServiceServer is the server API for OrchestratorService service.
+// All implementations must embed UnimplementedOrchestratorServiceServer
+// for forward compatibility
+type OrchestratorServiceServer interface {
+	// RPC to report that a calculation or sub-task started, completed or failed
+	ReportStatus(context.Context, *StatusReport) (*StatusReportAck, error)
+	// RPC to add an agent to the orchestrator registry on startup
+	RegisterAgent(context.Context, *AgentRegistration) (*AgentRegistrationAck, error)
+	// RPC to periodically confirm that an agent is alive and report its load
+	Heartbeat(context.Context, *AgentHeartbeat) (*AgentHeartbeatAck, error)
+	mustEmbedUnimplementedOrchestratorServiceServer()
+}
+
+// UnimplementedOrchestratorServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedOrchestratorServiceServer struct {
+}
+
+func (UnimplementedOrchestratorServiceServer) ReportStatus(context.Context, *StatusReport) (*StatusReportAck, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportStatus not implemented")
+}
+func (UnimplementedOrchestratorServiceServer) RegisterAgent(context.Context, *AgentRegistration) (*AgentRegistrationAck, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterAgent not implemented")
+}
+func (UnimplementedOrchestratorServiceServer) Heartbeat(context.Context, *AgentHeartbeat) (*AgentHeartbeatAck, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedOrchestratorServiceServer) mustEmbedUnimplementedOrchestratorServiceServer() {}
+
+// UnsafeOrchestratorServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OrchestratorServiceServer will
+// result in compilation errors.
+type UnsafeOrchestratorServiceServer interface {
+	mustEmbedUnimplementedOrchestratorServiceServer()
+}
+
+func RegisterOrchestratorServiceServer(s grpc.ServiceRegistrar, srv OrchestratorServiceServer) {
+	s.RegisterService(&OrchestratorService_ServiceDesc, srv)
+}
+
+func _OrchestratorService_ReportStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrchestratorServiceServer).ReportStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrchestratorService_ReportStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrchestratorServiceServer).ReportStatus(ctx, req.(*StatusReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrchestratorService_RegisterAgent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AgentRegistration)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrchestratorServiceServer).RegisterAgent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrchestratorService_RegisterAgent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrchestratorServiceServer).RegisterAgent(ctx, req.(*AgentRegistration))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OrchestratorService_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AgentHeartbeat)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrchestratorServiceServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: OrchestratorService_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrchestratorServiceServer).Heartbeat(ctx, req.(*AgentHeartbeat))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// OrchestratorService_ServiceDesc is the grpc.ServiceDesc for OrchestratorService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var OrchestratorService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "calculator.OrchestratorService",
+	HandlerType: (*OrchestratorServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReportStatus",
+			Handler:    _OrchestratorService_ReportStatus_Handler,
+		},
+		{
+			MethodName: "RegisterAgent",
+			Handler:    _OrchestratorService_RegisterAgent_Handler,
+		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _OrchestratorService_Heartbeat_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "calculator.proto",
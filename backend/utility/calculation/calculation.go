@@ -3,107 +3,275 @@
 package calculation
 
 import (
+    "context"   // Для отмены вычисления
     "fmt"       // Используется для форматированного вывода строк
+    "math"      // Для проверки переполнения результата
     "strconv"   // Для преобразования строк в числа и обратно
-    "strings"   // Для работы со строками
+    "strings"   // Для записи аргументов функции
     "time"      // Для имитации задержек
 )
 
-// OperationTimes определяет задержки для каждого типа операции.
+// OperationTimes определяет задержки для каждого типа операции: по оператору, например "+",
+// или по имени встроенной функции, например "sqrt".
 type OperationTimes map[string]time.Duration
 
-// EvaluateOperation принимает арифметическую операцию в виде строки
-// и operationTimes, определяющий задержки для каждой операции.
-// Возвращает срез строк с деталями каждого шага вычисления
-// и итоговый результат в виде float64.
-func EvaluateOperation(operation string, operationTimes OperationTimes) ([]string, float64) {
-    var operations []string // Срез для хранения описания операций
-    operands, operators := parseOperation(operation) // Разбор операции на операнды и операторы
+// Step описывает одну выполненную операцию выражения.
+type Step struct {
+    Left     float64       // Левый операнд
+    Operator string        // Оператор или имя функции
+    Right    float64       // Правый операнд
+    Args     []float64     // Аргументы функции; nil для операторов
+    Result   float64       // Результат операции
+    Exact    string        // Точная запись результата в точном режиме (EvaluateExact), иначе пустая
+    Elapsed  time.Duration // Время выполнения операции вместе с задержкой
+}
 
-    // Слияние операндов и операторов в один срез для последовательного вычисления
-    expression := make([]string, 0, len(operands)+len(operators))
-    for i, op := range operands {
-        expression = append(expression, op)
-        if i < len(operators) {
-            expression = append(expression, operators[i])
+// String возвращает запись шага в формате истории операций Evaluate.
+func (s Step) String() string {
+    if s.Args != nil {
+        args := make([]string, len(s.Args))
+        for i, arg := range s.Args {
+            args[i] = formatNumber(arg)
         }
+        return fmt.Sprintf("%s(%s) = %.6f", s.Operator, strings.Join(args, ", "), s.Result)
     }
+    return fmt.Sprintf("%s %s %s = %.6f", formatNumber(s.Left), s.Operator, formatNumber(s.Right), s.Result)
+}
 
-    // Сначала обрабатываем умножение и деление
-    for i := 0; i < len(expression)-1; i++ {
-        if expression[i] == "*" || expression[i] == "/" {
-            left, _ := strconv.ParseFloat(expression[i-1], 64)
-            right, _ := strconv.ParseFloat(expression[i+1], 64)
-            result := performOperation(left, right, expression[i], operationTimes)
-            // Запись выполненной операции
-            operations = append(operations, fmt.Sprintf("%s %s %s = %.6f", expression[i-1], expression[i], expression[i+1], result))
+// StepFunc вызывается после каждой выполненной операции выражения.
+type StepFunc func(Step)
 
-            // Обновление среза expression
-            expression[i+1] = fmt.Sprintf("%.6f", result)
-            expression = append(expression[:i-1], expression[i+1:]...)
-            i = i - 2 // Корректировка индекса после изменения среза
-        }
-    }
+// Evaluate принимает арифметическую операцию в виде строки
+// и operationTimes, определяющий задержки для каждой операции.
+// Возвращает срез строк с деталями каждого шага вычисления и итоговый результат.
+// При ошибке разбора возвращается *SyntaxError, при ошибке выполнения операции - *EvaluationError;
+// причину можно проверить через errors.Is (ErrDivisionByZero, ErrMalformedNumber, ErrUnknownOperator, ErrOverflow,
+// ErrDomain, ErrUnknownFunction, ErrUnknownName, ErrArity, а в точном режиме также ErrInvalidPrecision и ErrInexact).
+func Evaluate(operation string, operationTimes OperationTimes) ([]string, float64, error) {
+    return EvaluateContext(context.Background(), operation, operationTimes)
+}
 
-    // Затем обрабатываем сложение и вычитание
-    var result float64
-    if len(expression) > 0 {
-        result, _ = strconv.ParseFloat(expression[0], 64)
+// EvaluateContext вычисляет выражение так же, как Evaluate, но прекращает работу при отмене ctx:
+// ожидание текущей операции прерывается, и следующая операция не начинается.
+// В этом случае возвращается *EvaluationError, для которой errors.Is(err, context.Canceled)
+// (или context.DeadlineExceeded) истинно.
+func EvaluateContext(ctx context.Context, operation string, operationTimes OperationTimes) ([]string, float64, error) {
+    return EvaluateSteps(ctx, operation, operationTimes, nil)
+}
+
+// EvaluateSteps вычисляет выражение так же, как EvaluateContext, и сообщает о каждой выполненной
+// операции через onStep сразу после ее завершения, а не только в итоговой истории. onStep может быть nil.
+func EvaluateSteps(ctx context.Context, operation string, operationTimes OperationTimes, onStep StepFunc) ([]string, float64, error) {
+    return EvaluateWithVariables(ctx, operation, nil, operationTimes, onStep)
+}
+
+// EvaluateWithVariables вычисляет выражение так же, как EvaluateSteps, подставляя значения переменных из variables.
+func EvaluateWithVariables(ctx context.Context, operation string, variables map[string]float64, operationTimes OperationTimes, onStep StepFunc) ([]string, float64, error) {
+    var operations []string // Срез для хранения описания операций
+
+    // Построение синтаксического дерева выражения
+    tree, err := ParseWithVariables(operation, variables)
+    if err != nil {
+        return operations, 0, err
     }
-    for i := 1; i < len(expression); i += 2 {
-        right, _ := strconv.ParseFloat(expression[i+1], 64)
-        result = performOperation(result, right, expression[i], operationTimes)
-        // Запись текущей операции с результатом
-        operations = append(operations, fmt.Sprintf("%.6f %s %s = %.6f", result, expression[i], expression[i+1], result))
+    if err := checkFloatLiterals(tree); err != nil {
+        return operations, 0, err
     }
 
-    return operations, result // Возврат истории операций и результата
+    record := func(step Step) {
+        operations = append(operations, step.String())
+        if onStep != nil {
+            onStep(step)
+        }
+    }
+    result, err := evaluateNode(ctx, tree, operationTimes, record)
+    if err != nil {
+        return operations, 0, err
+    }
+    return operations, result, nil // Возврат истории операций и результата
 }
 
-// Разбор операции на операнды и операторы
-func parseOperation(operation string) ([]string, []string) {
-    // Извлечение операндов
-    operands := strings.FieldsFunc(operation, func(c rune) bool {
-        return c == '+' || c == '-' || c == '*' || c == '/'
-    })
+// checkFloatLiterals возвращает *SyntaxError с ErrOverflow, если литерал выражения не помещается в float64.
+// Точный режим такие литералы принимает, поэтому диапазон проверяется только перед вычислением в float64.
+func checkFloatLiterals(node Node) error {
+    switch n := node.(type) {
+    case *NumberNode:
+        if math.IsInf(n.Value, 0) {
+            return &SyntaxError{Pos: n.Pos, Msg: fmt.Sprintf("number %q is out of range", n.Text), Err: ErrOverflow}
+        }
+    case *UnaryNode:
+        return checkFloatLiterals(n.Operand)
+    case *BinaryNode:
+        if err := checkFloatLiterals(n.Left); err != nil {
+            return err
+        }
+        return checkFloatLiterals(n.Right)
+    case *CallNode:
+        for _, arg := range n.Args {
+            if err := checkFloatLiterals(arg); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
 
-    // Извлечение операторов
-    operators := make([]string, 0)
-    for _, c := range operation {
-        if strings.ContainsRune("+-*/", c) {
-            operators = append(operators, string(c))
+// evaluateNode рекурсивно вычисляет значение узла дерева.
+// Операнды бинарной операции вычисляются слева направо, затем выполняется сама операция.
+func evaluateNode(ctx context.Context, node Node, operationTimes OperationTimes, record StepFunc) (float64, error) {
+    switch n := node.(type) {
+    case *NumberNode:
+        return n.Value, nil
+    case *UnaryNode:
+        operand, err := evaluateNode(ctx, n.Operand, operationTimes, record)
+        if err != nil {
+            return 0, err
+        }
+        switch n.Op {
+        case "-":
+            return -operand, nil
+        case "+":
+            return operand, nil
+        default:
+            return 0, &EvaluationError{Pos: n.Pos, Op: n.Op, Err: ErrUnknownOperator}
+        }
+    case *BinaryNode:
+        left, err := evaluateNode(ctx, n.Left, operationTimes, record)
+        if err != nil {
+            return 0, err
+        }
+        right, err := evaluateNode(ctx, n.Right, operationTimes, record)
+        if err != nil {
+            return 0, err
+        }
+        started := time.Now()
+        result, err := performOperation(ctx, left, right, n.Op, operationTimes)
+        if err != nil {
+            return 0, &EvaluationError{Pos: n.Pos, Op: n.Op, Err: err}
+        }
+        // Запись выполненной операции
+        record(Step{Left: left, Operator: n.Op, Right: right, Result: result, Elapsed: time.Since(started)})
+        return result, nil
+    case *CallNode:
+        args := make([]float64, len(n.Args))
+        for i, arg := range n.Args {
+            value, err := evaluateNode(ctx, arg, operationTimes, record)
+            if err != nil {
+                return 0, err
+            }
+            args[i] = value
         }
+        started := time.Now()
+        result, err := performCall(ctx, n.Name, args, operationTimes)
+        if err != nil {
+            return 0, &EvaluationError{Pos: n.Pos, Op: n.Name, Err: err}
+        }
+        record(Step{Operator: n.Name, Args: args, Result: result, Elapsed: time.Since(started)})
+        return result, nil
+    default:
+        return 0, &EvaluationError{Pos: node.Position(), Op: fmt.Sprintf("%T", node), Err: ErrUnknownOperator}
     }
+}
 
-    return operands, operators // Возврат операндов и операторов
+// formatNumber возвращает кратчайшую точную запись числа.
+func formatNumber(value float64) string {
+    return strconv.FormatFloat(value, 'g', -1, 64)
 }
 
-// Выполнение операции с учетом задержки
-func performOperation(left, right float64, operator string, operationTimes OperationTimes) float64 {
-    // Имитация времени выполнения операции
+// simulateDelay имитирует время выполнения операции или функции operator. Отмена ctx прерывает ожидание.
+func simulateDelay(ctx context.Context, operator string, operationTimes OperationTimes) error {
+    // Отмененное вычисление не начинает следующую операцию
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+
     if duration, ok := operationTimes[operator]; ok {
         fmt.Printf("Performing %s operation, waiting for %v\n", operator, duration)
-        time.Sleep(duration) // Задержка
+        timer := time.NewTimer(duration) // Задержка
+        select {
+        case <-timer.C:
+        case <-ctx.Done():
+            timer.Stop()
+            return ctx.Err()
+        }
     } else {
         fmt.Println("Unknown operation, no delay applied")
     }
+    return nil
+}
+
+// Выполнение операции с учетом задержки. Отмена ctx прерывает ожидание.
+func performOperation(ctx context.Context, left, right float64, operator string, operationTimes OperationTimes) (float64, error) {
+    if err := simulateDelay(ctx, operator, operationTimes); err != nil {
+        return 0, err
+    }
 
     // Выполнение арифметической операции
+    var result float64
     switch operator {
     case "+":
-        return left + right
+        result = left + right
     case "-":
-        return left - right
+        result = left - right
     case "*":
-        return left * right
+        result = left * right
     case "/":
         if right == 0 {
-            fmt.Println("Error: Division by zero")
-            return 0
+            return 0, ErrDivisionByZero
+        }
+        result = left / right
+    case "//":
+        // Целочисленное деление округляет частное вниз, как в Python: 7 // -2 = -4
+        if right == 0 {
+            return 0, ErrDivisionByZero
+        }
+        result = math.Floor(left / right)
+    case "%":
+        // Остаток имеет знак делителя, чтобы выполнялось left = right*(left // right) + left % right
+        if right == 0 {
+            return 0, ErrDivisionByZero
         }
-        return left / right
+        result = math.Mod(left, right)
+        if result != 0 && (result < 0) != (right < 0) {
+            result += right
+        }
+    case "^":
+        result = math.Pow(left, right)
     default:
-        fmt.Println("Unknown operator", operator)
-        return 0
+        return 0, ErrUnknownOperator
+    }
+
+    return checkResult(result)
+}
+
+// performCall вызывает встроенную функцию name с учетом задержки. Отмена ctx прерывает ожидание.
+func performCall(ctx context.Context, name string, args []float64, operationTimes OperationTimes) (float64, error) {
+    fn, ok := functions[name]
+    if !ok {
+        return 0, ErrUnknownFunction
+    }
+    if !fn.accepts(len(args)) {
+        return 0, ErrArity
+    }
+    if err := simulateDelay(ctx, name, operationTimes); err != nil {
+        return 0, err
     }
-}
\ No newline at end of file
+
+    result, err := fn.call(args)
+    if err != nil {
+        return 0, err
+    }
+    return checkResult(result)
+}
+
+// checkResult проверяет, что результат операции - конечное число. Результат, вышедший за пределы float64,
+// считается переполнением, а NaN (например, дробная степень отрицательного числа) - выходом из области определения.
+func checkResult(result float64) (float64, error) {
+    if math.IsNaN(result) {
+        return 0, ErrDomain
+    }
+    if math.IsInf(result, 0) {
+        return 0, ErrOverflow
+    }
+    return result, nil
+}
@@ -1,52 +1,397 @@
 package calculation
 
 import (
+    "context"
+    "errors"
+    "fmt"
+    "math"
+    "math/big"
     "strings"
     "testing"
     "time"
 )
 
-func TestParseOperation(t *testing.T) {
+func TestParse(t *testing.T) {
     tests := []struct {
-        name           string
-        operation      string
-        wantOperands   []string
-        wantOperators  []string
+        name      string
+        operation string
+        want      string
     }{
         {
-            name:          "Simple Addition",
-            operation:     "3 + 4",
-            wantOperands:  []string{"3", "4"},
-            wantOperators: []string{"+"},
+            name:      "Simple Addition",
+            operation: "3 + 4",
+            want:      "(+ 3 4)",
         },
         {
-            name:          "Complex Operation",
-            operation:     "5 + 6 * 3",
-            wantOperands:  []string{"5", "6", "3"},
-            wantOperators: []string{"+", "*"},
+            name:      "Operator Precedence",
+            operation: "5 + 6 * 3",
+            want:      "(+ 5 (* 6 3))",
         },
         {
-            name:          "Operation With Spaces",
-            operation:     "12    /   4 - 1",
-            wantOperands:  []string{"12", "4", "1"},
-            wantOperators: []string{"/", "-"},
+            name:      "Operation With Spaces",
+            operation: "12    /   4 - 1",
+            want:      "(- (/ 12 4) 1)",
+        },
+        {
+            name:      "Parentheses",
+            operation: "(2+3)*4",
+            want:      "(* (+ 2 3) 4)",
+        },
+        {
+            name:      "Leading Unary Minus",
+            operation: "-5+2",
+            want:      "(+ (-5) 2)",
+        },
+        {
+            name:      "Unary Minus After Operator",
+            operation: "2*-3",
+            want:      "(* 2 (-3))",
+        },
+        {
+            name:      "Scientific Notation",
+            operation: "1.5e3 - 2E-2",
+            want:      "(- 1.5e3 2E-2)",
+        },
+        {
+            name:      "Left Associativity",
+            operation: "8-4-2",
+            want:      "(- (- 8 4) 2)",
+        },
+        {
+            name:      "Power Right Associativity",
+            operation: "2^3^2",
+            want:      "(^ 2 (^ 3 2))",
+        },
+        {
+            name:      "Power Binds Tighter Than Unary Minus",
+            operation: "-2^-2",
+            want:      "(-(^ 2 (-2)))",
+        },
+        {
+            name:      "Modulo And Integer Division",
+            operation: "7 // 2 % 3 * 2^2",
+            want:      "(* (% (// 7 2) 3) (^ 2 2))",
+        },
+        {
+            name:      "Functions And Constants",
+            operation: "sqrt(2) * pi + max(3, abs(-7)) + log(100, 10)",
+            want:      "(+ (+ (* sqrt(2) pi) max(3, abs((-7)))) log(100, 10))",
+        },
+        {
+            name:      "Power Of Function",
+            operation: "-exp(1)^2",
+            want:      "(-(^ exp(1) 2))",
+        },
+        {
+            name:      "Fraction Literal",
+            operation: "[10/3] ^ 2 - [-1/2]",
+            want:      "(- (^ 10/3 2) -1/2)",
         },
     }
 
     for _, tt := range tests {
         t.Run(tt.name, func(t *testing.T) {
-            operands, operators := parseOperation(tt.operation)
-            trimmedOperands := trimSpacesFromOperands(operands)
-            if !equalSlices(trimmedOperands, tt.wantOperands) {
-                t.Errorf("parseOperation() got operands = %v, want %v", operands, tt.wantOperands)
+            tree, err := Parse(tt.operation)
+            if err != nil {
+                t.Fatalf("Parse(%q) returned error: %v", tt.operation, err)
+            }
+            if got := renderNode(tree); got != tt.want {
+                t.Errorf("Parse(%q) = %s, want %s", tt.operation, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    tests := []struct {
+        name      string
+        operation string
+        wantPos   int
+    }{
+        {name: "Empty Expression", operation: "   ", wantPos: 4},
+        {name: "Unclosed Parenthesis", operation: "(2+3", wantPos: 5},
+        {name: "Unexpected Closing Parenthesis", operation: "2+3)", wantPos: 4},
+        {name: "Missing Operand", operation: "2*", wantPos: 3},
+        {name: "Malformed Exponent", operation: "1e+", wantPos: 1},
+        {name: "Unknown Character", operation: "2 & 3", wantPos: 3},
+        {name: "Lonely Dot", operation: "2 + .", wantPos: 5},
+        {name: "Triple Slash", operation: "7 /// 2", wantPos: 5},
+        {name: "Missing Exponent", operation: "2^", wantPos: 3},
+        {name: "Unknown Function", operation: "1 + foo(2)", wantPos: 5},
+        {name: "Unknown Name", operation: "2 * tau", wantPos: 5},
+        {name: "Too Few Arguments", operation: "pow(2)", wantPos: 1},
+        {name: "Too Many Arguments", operation: "sqrt(4, 9)", wantPos: 1},
+        {name: "Unclosed Call", operation: "max(1, 2", wantPos: 9},
+        {name: "Trailing Comma", operation: "max(1, )", wantPos: 8},
+        {name: "Unclosed Fraction", operation: "2 * [10/3", wantPos: 5},
+        {name: "Decimal Fraction", operation: "[1.5/2]", wantPos: 1},
+        {name: "Zero Denominator", operation: "1 + [1/0]", wantPos: 5},
+        {name: "Fraction Without Denominator", operation: "[10]", wantPos: 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := Parse(tt.operation)
+            var syntaxErr *SyntaxError
+            if !errors.As(err, &syntaxErr) {
+                t.Fatalf("Parse(%q) error = %v, want *SyntaxError", tt.operation, err)
+            }
+            if syntaxErr.Pos != tt.wantPos {
+                t.Errorf("Parse(%q) error position = %d, want %d (%v)", tt.operation, syntaxErr.Pos, tt.wantPos, err)
+            }
+        })
+    }
+}
+
+func TestEvaluate(t *testing.T) {
+    tests := []struct {
+        operation string
+        want      float64
+    }{
+        {operation: "2+2", want: 4},
+        {operation: "(2+3)*4", want: 20},
+        {operation: "-5+2", want: -3},
+        {operation: "2*-3", want: -6},
+        {operation: "2 * 3 + 4 * 5", want: 26},
+        {operation: "-(1+2)*+3", want: -9},
+        {operation: "1e2/4", want: 25},
+        {operation: "2^3^2", want: 512},
+        {operation: "-2^2", want: -4},
+        {operation: "(-2)^2", want: 4},
+        {operation: "2^-1", want: 0.5},
+        {operation: "7 // 2", want: 3},
+        {operation: "-7 // 2", want: -4},
+        {operation: "7 % 3", want: 1},
+        {operation: "-7 % 3", want: 2},
+        {operation: "7 % -3", want: -2},
+        {operation: "5.5 % 2", want: 1.5},
+        {operation: "2 * 7 // 2 % 4", want: 3},
+        {operation: "sqrt(16) + abs(-2)", want: 6},
+        {operation: "max(3, abs(-7), 5) - min(4, 2)", want: 5},
+        {operation: "log(100, 10) + log(1000)", want: 5},
+        {operation: "ln(e) + exp(0)", want: 2},
+        {operation: "pow(2, 10)", want: 1024},
+        {operation: "floor(-2.5) + ceil(2.1) + round(2.5)", want: 3},
+        {operation: "sin(0) + cos(0) + tan(0)", want: 1},
+        {operation: "round(pi * 100)", want: 314},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.operation, func(t *testing.T) {
+            _, got, err := Evaluate(tt.operation, OperationTimes{})
+            if err != nil {
+                t.Fatalf("Evaluate(%q) returned error: %v", tt.operation, err)
+            }
+            if got != tt.want {
+                t.Errorf("Evaluate(%q) = %v, want %v", tt.operation, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestEvaluateErrors(t *testing.T) {
+    tests := []struct {
+        operation string
+        wantErr   error
+    }{
+        {operation: "1/0", wantErr: ErrDivisionByZero},
+        {operation: "2+(3-3)/(1-1)", wantErr: ErrDivisionByZero},
+        {operation: "1.2.3+1", wantErr: ErrMalformedNumber},
+        {operation: "1e400+1", wantErr: ErrOverflow},
+        {operation: "1e308*10", wantErr: ErrOverflow},
+        {operation: "5 // 0", wantErr: ErrDivisionByZero},
+        {operation: "5 % 0", wantErr: ErrDivisionByZero},
+        {operation: "10^400", wantErr: ErrOverflow},
+        {operation: "(-8)^(1/3)", wantErr: ErrDomain},
+        {operation: "sqrt(-1)", wantErr: ErrDomain},
+        {operation: "log(0)", wantErr: ErrDomain},
+        {operation: "log(8, 1)", wantErr: ErrDomain},
+        {operation: "ln(-e)", wantErr: ErrDomain},
+        {operation: "pow(-8, 1/3)", wantErr: ErrDomain},
+        {operation: "exp(1000)", wantErr: ErrOverflow},
+        {operation: "max(1, 2/0)", wantErr: ErrDivisionByZero},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.operation, func(t *testing.T) {
+            _, result, err := Evaluate(tt.operation, OperationTimes{})
+            if !errors.Is(err, tt.wantErr) {
+                t.Fatalf("Evaluate(%q) error = %v, want %v", tt.operation, err, tt.wantErr)
             }
-            if !equalSlices(operators, tt.wantOperators) {
-                t.Errorf("parseOperation() got operators = %v, want %v", operators, tt.wantOperators)
+            if result != 0 {
+                t.Errorf("Evaluate(%q) result = %v, want 0 on error", tt.operation, result)
             }
         })
     }
 }
 
+func TestEvaluateContextCancel(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    time.AfterFunc(50*time.Millisecond, cancel)
+
+    // Отмена прерывает ожидание первой операции, вторая операция не начинается
+    start := time.Now()
+    operations, _, err := EvaluateContext(ctx, "1+2+3", OperationTimes{"+": 10 * time.Second})
+    if !errors.Is(err, context.Canceled) {
+        t.Fatalf("EvaluateContext() error = %v, want %v", err, context.Canceled)
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("EvaluateContext() stopped after %v, want within one operator step", elapsed)
+    }
+    if len(operations) != 0 {
+        t.Errorf("EvaluateContext() performed %v after cancellation", operations)
+    }
+}
+
+func TestEvaluateSteps(t *testing.T) {
+    var steps []Step
+    operations, result, err := EvaluateSteps(context.Background(), "2*3 + 4", OperationTimes{"*": 10 * time.Millisecond}, func(step Step) {
+        steps = append(steps, step)
+    })
+    if err != nil || result != 10 {
+        t.Fatalf("EvaluateSteps() = %v, %v, want 10", result, err)
+    }
+    if len(steps) != 2 || len(operations) != 2 {
+        t.Fatalf("EvaluateSteps() reported %v, want two steps", steps)
+    }
+    if steps[0].Left != 2 || steps[0].Operator != "*" || steps[0].Right != 3 || steps[0].Result != 6 || steps[0].Elapsed < 10*time.Millisecond {
+        t.Errorf("first step = %+v, want 2 * 3 = 6 after the delay", steps[0])
+    }
+    for i, step := range steps {
+        if step.String() != operations[i] {
+            t.Errorf("step %d = %q, want %q", i, step.String(), operations[i])
+        }
+    }
+}
+
+func TestEvaluateStepsFunction(t *testing.T) {
+    var steps []Step
+    operations, result, err := EvaluateSteps(context.Background(), "max(1, sqrt(9))", OperationTimes{"sqrt": 10 * time.Millisecond}, func(step Step) {
+        steps = append(steps, step)
+    })
+    if err != nil || result != 3 {
+        t.Fatalf("EvaluateSteps() = %v, %v, want 3", result, err)
+    }
+    if len(steps) != 2 || steps[0].Operator != "sqrt" || steps[0].Elapsed < 10*time.Millisecond {
+        t.Fatalf("EvaluateSteps() reported %+v, want sqrt after its delay and then max", steps)
+    }
+    if operations[1] != "max(1, 3) = 3.000000" {
+        t.Errorf("function step = %q, want max(1, 3) = 3.000000", operations[1])
+    }
+}
+
+func TestPerformOperationUnknownOperator(t *testing.T) {
+    if _, err := performOperation(context.Background(), 1, 2, "?", OperationTimes{}); !errors.Is(err, ErrUnknownOperator) {
+        t.Errorf("performOperation() error = %v, want %v", err, ErrUnknownOperator)
+    }
+}
+
+func TestDecompose(t *testing.T) {
+    plan, err := Decompose("2*3 + 4*5")
+    if err != nil {
+        t.Fatalf("Decompose() returned error: %v", err)
+    }
+    if len(plan.Tasks) != 3 {
+        t.Fatalf("Decompose() got %d tasks, want 3", len(plan.Tasks))
+    }
+
+    // Оба умножения не зависят друг от друга, сложение ждет их результатов
+    first, second, root := plan.Tasks[0], plan.Tasks[1], plan.Tasks[2]
+    if first.Op != "*" || second.Op != "*" || root.Op != "+" {
+        t.Errorf("Decompose() operators = %s %s %s, want * * +", first.Op, second.Op, root.Op)
+    }
+    if first.Parent != 2 || first.Position != 0 || second.Parent != 2 || second.Position != 1 || root.Parent != -1 {
+        t.Errorf("Decompose() wrong links: %+v", plan.Tasks)
+    }
+    if root.Operands[0].Task != 0 || root.Operands[1].Task != 1 {
+        t.Errorf("Decompose() root operands = %+v, want references to tasks 0 and 1", root.Operands)
+    }
+    if first.Operands[0].Value != "2" || first.Operands[1].Value != "3" {
+        t.Errorf("Decompose() first task operands = %+v, want 2 and 3", first.Operands)
+    }
+}
+
+func TestDecomposeUnary(t *testing.T) {
+    tests := []struct {
+        operation string
+        wantOps   []string
+        wantValue string
+    }{
+        {operation: "-5", wantValue: "-5"},
+        {operation: "-(-(5))", wantValue: "5"},
+        {operation: "2*-3", wantOps: []string{"*"}},
+        {operation: "-(2+3)", wantOps: []string{"+", OperatorNegate}},
+        {operation: "-pi", wantValue: "-3.141592653589793"},
+        {operation: "max(1, 2*3, sqrt(4))", wantOps: []string{"*", "sqrt", "max"}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.operation, func(t *testing.T) {
+            plan, err := Decompose(tt.operation)
+            if err != nil {
+                t.Fatalf("Decompose(%q) returned error: %v", tt.operation, err)
+            }
+            var ops []string
+            for _, task := range plan.Tasks {
+                ops = append(ops, task.Op)
+            }
+            if !equalSlices(ops, tt.wantOps) || plan.Value != tt.wantValue {
+                t.Errorf("Decompose(%q) = %v / %q, want %v / %q", tt.operation, ops, plan.Value, tt.wantOps, tt.wantValue)
+            }
+        })
+    }
+}
+
+func TestTaskExpression(t *testing.T) {
+    tests := []struct {
+        op       string
+        operands []string
+        want     float64
+    }{
+        {op: "-", operands: []string{"2", "-3"}, want: 5},
+        {op: "*", operands: []string{"-1.5", "4"}, want: -6},
+        {op: OperatorNegate, operands: []string{"-7"}, want: 7},
+        {op: "/", operands: []string{"1e+21", "1e+20"}, want: 10},
+        {op: "^", operands: []string{"-2", "2"}, want: 4},
+        {op: "//", operands: []string{"-7", "2"}, want: -4},
+        {op: "%", operands: []string{"-7", "3"}, want: 2},
+        {op: "max", operands: []string{"-7", "-3", "-5"}, want: -3},
+        {op: "sqrt", operands: []string{"2.25"}, want: 1.5},
+    }
+
+    for _, tt := range tests {
+        expression := TaskExpression(tt.op, tt.operands)
+        _, got, err := Evaluate(expression, OperationTimes{})
+        if err != nil || got != tt.want {
+            t.Errorf("Evaluate(TaskExpression(%s, %v)) = %v, %v; want %v", tt.op, tt.operands, got, err, tt.want)
+        }
+    }
+}
+
+func TestTaskExpressionFractions(t *testing.T) {
+    tests := []struct {
+        op       string
+        operands []string
+        want     string
+        steps    int
+    }{
+        {op: "^", operands: []string{"2/3", "2"}, want: "4/9", steps: 1},
+        {op: "*", operands: []string{"10/3", "-1/2"}, want: "-5/3", steps: 1},
+        {op: "-", operands: []string{"1", "-1/3"}, want: "4/3", steps: 1},
+        {op: OperatorNegate, operands: []string{"10/3"}, want: "-10/3", steps: 0},
+        {op: "max", operands: []string{"1/3", "1/2"}, want: "1/2", steps: 1},
+    }
+
+    for _, tt := range tests {
+        // Дробь читается одним числом, поэтому задача выполняет только свою операцию без лишнего деления
+        expression := TaskExpression(tt.op, tt.operands)
+        history, got, err := EvaluateExact(context.Background(), expression, nil, Precision{Mode: ModeRational}, OperationTimes{}, nil)
+        if err != nil || got != tt.want || len(history) != tt.steps {
+            t.Errorf("EvaluateExact(TaskExpression(%s, %v)) = %v, %q, %v; want %q in %d steps", tt.op, tt.operands, history, got, err, tt.want, tt.steps)
+        }
+    }
+}
+
 // Helper function to compare slices
 func equalSlices(a, b []string) bool {
     if len(a) != len(b) {
@@ -60,11 +405,230 @@ func equalSlices(a, b []string) bool {
     return true
 }
 
-// Helper function to remove spaces from operands for testing
-func trimSpacesFromOperands(operands []string) []string {
-    trimmed := make([]string, len(operands))
-    for i, op := range operands {
-        trimmed[i] = strings.TrimSpace(op)
+// Helper function to render a syntax tree as an S-expression
+func renderNode(node Node) string {
+    switch n := node.(type) {
+    case *NumberNode:
+        return n.Text
+    case *UnaryNode:
+        return fmt.Sprintf("(%s%s)", n.Op, renderNode(n.Operand))
+    case *BinaryNode:
+        return fmt.Sprintf("(%s %s %s)", n.Op, renderNode(n.Left), renderNode(n.Right))
+    case *CallNode:
+        args := make([]string, len(n.Args))
+        for i, arg := range n.Args {
+            args[i] = renderNode(arg)
+        }
+        return fmt.Sprintf("%s(%s)", n.Name, strings.Join(args, ", "))
+    default:
+        return fmt.Sprintf("<%T>", node)
     }
-    return trimmed
-}
\ No newline at end of file
+}
+
+func TestEvaluateWithVariables(t *testing.T) {
+    variables := map[string]float64{"rate": 0.07, "n": 12, "principal_1": 1000}
+    _, got, err := EvaluateWithVariables(context.Background(), "principal_1 * (1 + rate / n) ^ n", variables, OperationTimes{}, nil)
+    if err != nil {
+        t.Fatalf("EvaluateWithVariables() returned error: %v", err)
+    }
+    if want := 1000 * math.Pow(1+0.07/12, 12); math.Abs(got-want) > 1e-9 {
+        t.Errorf("EvaluateWithVariables() = %v, want %v", got, want)
+    }
+
+    // Переменная, которой нет в наборе, - синтаксическая ошибка с позицией имени
+    var syntaxErr *SyntaxError
+    if _, _, err := EvaluateWithVariables(context.Background(), "rate * years", variables, OperationTimes{}, nil); !errors.As(err, &syntaxErr) || syntaxErr.Pos != 8 || !errors.Is(err, ErrUnknownName) {
+        t.Errorf("EvaluateWithVariables() error = %v, want unknown name at position 8", err)
+    }
+}
+
+func TestDecomposeWithVariables(t *testing.T) {
+    plan, err := DecomposeWithVariables("-x * y", map[string]float64{"x": 1.5, "y": -2})
+    if err != nil || len(plan.Tasks) != 1 {
+        t.Fatalf("DecomposeWithVariables() = %+v, %v, want one task", plan, err)
+    }
+    if operands := plan.Tasks[0].Operands; operands[0].Value != "-1.5" || operands[1].Value != "-2" {
+        t.Errorf("DecomposeWithVariables() operands = %+v, want substituted values -1.5 and -2", operands)
+    }
+
+    // Вычисление в float64 отклоняет литерал за пределами диапазона еще при разбиении
+    if _, err := DecomposeWithVariables("1e400 + 1", nil); !errors.Is(err, ErrOverflow) {
+        t.Errorf("DecomposeWithVariables() error = %v, want ErrOverflow", err)
+    }
+}
+
+func TestDecomposeExact(t *testing.T) {
+    // Переменные берутся по значению, а иррациональные константы в точном режиме недопустимы
+    if plan, err := DecomposeExact("x * 2", map[string]float64{"x": 0.5}); err != nil || len(plan.Tasks) != 1 {
+        t.Errorf("DecomposeExact() = %+v, %v, want one task", plan, err)
+    }
+    for _, expression := range []string{"pi * 2", "max(1, e)", "-pi"} {
+        if _, err := DecomposeExact(expression, nil); !errors.Is(err, ErrInexact) {
+            t.Errorf("DecomposeExact(%q) error = %v, want ErrInexact", expression, err)
+        }
+    }
+    if _, err := DecomposeExact("1e1000000000 + 1", nil); !errors.Is(err, ErrOverflow) {
+        t.Errorf("DecomposeExact() error = %v, want ErrOverflow", err)
+    }
+}
+
+func TestDecomposeExactBeyondFloat64(t *testing.T) {
+    // Промежуточный результат 2^2000 не помещается в float64 и передается родительской задаче точной записью
+    plan, err := DecomposeExact("2^2000+1", nil)
+    if err != nil || len(plan.Tasks) != 2 {
+        t.Fatalf("DecomposeExact() = %+v, %v, want two tasks", plan, err)
+    }
+    results := make([]string, len(plan.Tasks))
+    for i, task := range plan.Tasks {
+        operands := make([]string, len(task.Operands))
+        for j, operand := range task.Operands {
+            if operand.Task >= 0 {
+                operands[j] = results[operand.Task]
+            } else {
+                operands[j] = operand.Value
+            }
+        }
+        _, results[i], err = EvaluateExact(context.Background(), TaskExpression(task.Op, operands), nil, Precision{Mode: ModeRational}, OperationTimes{}, nil)
+        if err != nil {
+            t.Fatalf("EvaluateExact() for task %d returned error: %v", i, err)
+        }
+    }
+    want := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 2000), big.NewInt(1)).String()
+    if got := results[len(results)-1]; got != want {
+        t.Errorf("2^2000+1 = %s, want %s", got, want)
+    }
+}
+
+func TestValidateVariables(t *testing.T) {
+    tests := []struct {
+        name      string
+        variables map[string]float64
+        wantErr   bool
+    }{
+        {name: "Valid", variables: map[string]float64{"rate": 0.07, "_n2": 12}},
+        {name: "Empty", variables: nil},
+        {name: "Starts With Digit", variables: map[string]float64{"2x": 1}, wantErr: true},
+        {name: "Contains Dash", variables: map[string]float64{"a-b": 1}, wantErr: true},
+        {name: "Function Name", variables: map[string]float64{"sqrt": 1}, wantErr: true},
+        {name: "Constant Name", variables: map[string]float64{"pi": 3}, wantErr: true},
+        {name: "Infinite Value", variables: map[string]float64{"x": math.Inf(1)}, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := ValidateVariables(tt.variables)
+            if tt.wantErr != (err != nil) || (err != nil && !errors.Is(err, ErrInvalidVariable)) {
+                t.Errorf("ValidateVariables(%v) error = %v, want error: %v", tt.variables, err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestEvaluateExact(t *testing.T) {
+    tests := []struct {
+        name       string
+        expression string
+        precision  Precision
+        want       string
+    }{
+        {name: "Decimal Fractions", expression: "0.1 + 0.2", precision: Decimal(20, ""), want: "0.3"},
+        {name: "Long Literal", expression: "12345678901234567890.123456789 * 10", precision: Decimal(9, ""), want: "123456789012345678901.23456789"},
+        {name: "Division Half Even", expression: "1 / 8", precision: Decimal(2, ""), want: "0.12"},
+        {name: "Division Half Up", expression: "1 / 8", precision: Decimal(2, RoundHalfUp), want: "0.13"},
+        {name: "Division Floor", expression: "-2 / 3", precision: Decimal(3, RoundFloor), want: "-0.667"},
+        {name: "Rounded Intermediate", expression: "1 / 3 * 3", precision: Decimal(4, ""), want: "0.9999"},
+        {name: "Integer Division And Modulo", expression: "-7.5 // 2 + -7.5 % 2", precision: Decimal(2, ""), want: "-3.5"},
+        {name: "Negative Power", expression: "2 ^ -3", precision: Decimal(10, ""), want: "0.125"},
+        {name: "Functions", expression: "sqrt(2) + round(-2.5) + max(0.1, 0.25)", precision: Decimal(6, ""), want: "-1.335786"},
+        {name: "Variables", expression: "price * (1 + rate)", precision: Decimal(2, ""), want: "107"},
+        {name: "Rational Division", expression: "10 / 3", precision: Precision{Mode: ModeRational}, want: "10/3"},
+        {name: "Rational Sum", expression: "0.1 + 0.2 + 1 / 3", precision: Precision{Mode: ModeRational}, want: "19/30"},
+        {name: "Rational Without Rounding", expression: "1 / 3 * 3", precision: Precision{Mode: ModeRational, Scale: Decimal(4, "").Scale}, want: "1"},
+        {name: "Rational Big Power", expression: "2 ^ 200", precision: Precision{Mode: ModeRational}, want: "1606938044258990275541962092341162602522202993782792835301376"},
+        {name: "Rational Power Of One", expression: "1 ^ 100000", precision: Precision{Mode: ModeRational}, want: "1"},
+        {name: "Rational Odd Power Of Minus One", expression: "(-1) ^ 100001", precision: Precision{Mode: ModeRational}, want: "-1"},
+        {name: "Huge Power Of One", expression: "1 ^ 100000000", precision: Decimal(2, ""), want: "1"},
+        {name: "Huge Odd Power Of Minus One", expression: "(-1) ^ 100000001", precision: Precision{Mode: ModeRational}, want: "-1"},
+        {name: "Huge Even Negative Power Of Minus One", expression: "(-1) ^ -100000000", precision: Decimal(2, ""), want: "1"},
+        {name: "Rational Negative Power", expression: "(2 / 3) ^ -2", precision: Precision{Mode: ModeRational}, want: "9/4"},
+        {name: "Rational Integer Division And Modulo", expression: "(10 / 3) // 1 + (-10 / 3) % 1", precision: Precision{Mode: ModeRational}, want: "11/3"},
+        {name: "Rational Square Root", expression: "sqrt(9 / 4) + abs(-1 / 6)", precision: Precision{Mode: ModeRational}, want: "5/3"},
+        {name: "Literal Beyond Float64", expression: "1e400 / 1e399", precision: Precision{Mode: ModeRational}, want: "10"},
+        {name: "Tiny Literal", expression: "1e-400 * 1e400", precision: Decimal(2, ""), want: "1"},
+    }
+    variables := map[string]float64{"price": 100, "rate": 0.07}
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, got, err := EvaluateExact(context.Background(), tt.expression, variables, tt.precision, OperationTimes{}, nil)
+            if err != nil || got != tt.want {
+                t.Errorf("EvaluateExact(%q) = %q, %v, want %q", tt.expression, got, err, tt.want)
+            }
+        })
+    }
+}
+
+func TestEvaluateExactErrors(t *testing.T) {
+    tests := []struct {
+        name       string
+        expression string
+        precision  Precision
+        wantErr    error
+    }{
+        {name: "Division By Zero", expression: "1 / (2 - 2)", precision: Decimal(2, ""), wantErr: ErrDivisionByZero},
+        {name: "Fractional Power", expression: "2 ^ 0.5", precision: Decimal(2, ""), wantErr: ErrInexact},
+        {name: "Transcendental Function", expression: "sin(1)", precision: Decimal(2, ""), wantErr: ErrInexact},
+        {name: "Huge Power", expression: "10 ^ 100000000", precision: Decimal(2, ""), wantErr: ErrOverflow},
+        {name: "Huge Exponent Literal", expression: "1e1000000000 + 1", precision: Decimal(2, ""), wantErr: ErrOverflow},
+        {name: "Negative Scale", expression: "1 + 1", precision: Decimal(-1, ""), wantErr: ErrInvalidPrecision},
+        {name: "Missing Scale", expression: "1 + 1", precision: Precision{Mode: ModeDecimal}, wantErr: ErrInvalidPrecision},
+        {name: "Unknown Rounding", expression: "1 + 1", precision: Decimal(2, "nearest"), wantErr: ErrInvalidPrecision},
+        {name: "Unknown Mode", expression: "1 + 1", precision: Precision{Mode: "binary"}, wantErr: ErrInvalidPrecision},
+        {name: "Decimal Constant", expression: "pi * 2", precision: Decimal(10, ""), wantErr: ErrInexact},
+        {name: "Rational Constant", expression: "pi * 2", precision: Precision{Mode: ModeRational}, wantErr: ErrInexact},
+        {name: "Rational Constant Alone", expression: "-e", precision: Precision{Mode: ModeRational}, wantErr: ErrInexact},
+        {name: "Rational Irrational Root", expression: "sqrt(2)", precision: Precision{Mode: ModeRational}, wantErr: ErrInexact},
+        {name: "Rational Fractional Power", expression: "4 ^ 0.5", precision: Precision{Mode: ModeRational}, wantErr: ErrInexact},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if _, _, err := EvaluateExact(context.Background(), tt.expression, nil, tt.precision, OperationTimes{}, nil); !errors.Is(err, tt.wantErr) {
+                t.Errorf("EvaluateExact(%q) error = %v, want %v", tt.expression, err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestExactValue(t *testing.T) {
+    tests := []struct {
+        value    string
+        rounding string
+        want     string
+    }{
+        {value: "2.345", rounding: RoundHalfEven, want: "2.34"},
+        {value: "2.355", rounding: RoundHalfEven, want: "2.36"},
+        {value: "-2.345", rounding: RoundHalfUp, want: "-2.35"},
+        {value: "-2.345", rounding: RoundHalfDown, want: "-2.34"},
+        {value: "2.341", rounding: RoundUp, want: "2.35"},
+        {value: "-2.349", rounding: RoundDown, want: "-2.34"},
+        {value: "-2.341", rounding: RoundCeiling, want: "-2.34"},
+        {value: "-2.341", rounding: RoundFloor, want: "-2.35"},
+        {value: "7", rounding: RoundUp, want: "7"},
+    }
+    for _, tt := range tests {
+        got, err := ExactValue(tt.value, Decimal(2, tt.rounding))
+        if err != nil || got != tt.want {
+            t.Errorf("ExactValue(%q, %s) = %q, %v, want %q", tt.value, tt.rounding, got, err, tt.want)
+        }
+    }
+    for value, want := range map[string]string{"0.25": "1/4", "-6/4": "-3/2", "2.5e3": "2500"} {
+        if got, err := ExactValue(value, Precision{Mode: ModeRational}); err != nil || got != want {
+            t.Errorf("ExactValue(%q, rational) = %q, %v, want %q", value, got, err, want)
+        }
+    }
+    if _, err := ExactValue("1.2.3", Decimal(2, "")); !errors.Is(err, ErrMalformedNumber) {
+        t.Errorf("ExactValue() error = %v, want ErrMalformedNumber", err)
+    }
+    if got := ApproximateExact("10/3"); got != 10.0/3 {
+        t.Errorf("ApproximateExact(10/3) = %v, want %v", got, 10.0/3)
+    }
+}
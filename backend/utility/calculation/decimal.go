@@ -0,0 +1,531 @@
+package calculation
+
+import (
+    "context"  // Для отмены вычисления
+    "fmt"      // Для форматирования истории операций и ошибок
+    "math"     // Для оценки точности квадратного корня
+    "math/big" // Для вычислений с произвольной точностью
+    "strings"  // Для записи аргументов функции
+    "time"     // Для измерения времени операций
+)
+
+// Режимы точного вычисления.
+const (
+    ModeDecimal  = "decimal"  // Десятичные дроби с округлением до заданного числа знаков (по умолчанию)
+    ModeRational = "rational" // Целые и рациональные числа без округления
+)
+
+// Режимы округления точных десятичных вычислений.
+const (
+    RoundHalfEven = "half_even" // К ближайшему, половина - к четной цифре (по умолчанию)
+    RoundHalfUp   = "half_up"   // К ближайшему, половина - от нуля
+    RoundHalfDown = "half_down" // К ближайшему, половина - к нулю
+    RoundUp       = "up"        // От нуля
+    RoundDown     = "down"      // К нулю (отбрасывание цифр)
+    RoundCeiling  = "ceiling"   // К +бесконечности
+    RoundFloor    = "floor"     // К -бесконечности
+)
+
+// MaxScale - наибольшее допустимое число знаков после запятой в точном режиме.
+const MaxScale = 1000
+
+// maxExactBits ограничивает размер числителя и знаменателя результата возведения в степень,
+// чтобы выражение вроде 10^100000000 не занимало всю память калькулятора.
+const maxExactBits = 1 << 20
+
+// Precision задает точный режим вычисления. В десятичном режиме каждый промежуточный результат
+// округляется до Scale знаков после запятой по правилу Rounding. В рациональном режиме результаты
+// хранятся несократимыми дробями big.Rat без округления (10/3 остается 10/3), а Scale и Rounding не используются.
+// Масштаб задается указателем, чтобы пропущенное поле scale не принималось за 0 знаков после запятой.
+type Precision struct {
+    Mode     string `json:"mode,omitempty"`     // Режим вычисления, по умолчанию ModeDecimal
+    Scale    *int   `json:"scale,omitempty"`    // Число знаков после запятой, обязательно в десятичном режиме
+    Rounding string `json:"rounding,omitempty"` // Режим округления, по умолчанию RoundHalfEven
+}
+
+// Decimal возвращает точность десятичного режима со scale знаками после запятой и режимом округления rounding.
+func Decimal(scale int, rounding string) Precision {
+    return Precision{Scale: &scale, Rounding: rounding}
+}
+
+// Validate проверяет режим вычисления, масштаб и режим округления. Ошибка оборачивает ErrInvalidPrecision.
+func (p Precision) Validate() error {
+    switch p.Mode {
+    case "", ModeDecimal:
+    case ModeRational:
+        return nil
+    default:
+        return fmt.Errorf("%w: unknown arithmetic mode %q", ErrInvalidPrecision, p.Mode)
+    }
+    if p.Scale == nil {
+        return fmt.Errorf("%w: scale is required in %s mode", ErrInvalidPrecision, ModeDecimal)
+    }
+    if *p.Scale < 0 || *p.Scale > MaxScale {
+        return fmt.Errorf("%w: scale must be between 0 and %d, got %d", ErrInvalidPrecision, MaxScale, *p.Scale)
+    }
+    switch p.Rounding {
+    case "", RoundHalfEven, RoundHalfUp, RoundHalfDown, RoundUp, RoundDown, RoundCeiling, RoundFloor:
+        return nil
+    default:
+        return fmt.Errorf("%w: unknown rounding mode %q", ErrInvalidPrecision, p.Rounding)
+    }
+}
+
+// scale возвращает масштаб десятичного режима; 0, если он не задан.
+func (p Precision) scale() int {
+    if p.Scale == nil {
+        return 0
+    }
+    return *p.Scale
+}
+
+// mode возвращает режим округления с учетом значения по умолчанию.
+func (p Precision) mode() string {
+    if p.Rounding == "" {
+        return RoundHalfEven
+    }
+    return p.Rounding
+}
+
+// rational сообщает, что вычисление выполняется в рациональном режиме.
+func (p Precision) rational() bool {
+    return p.Mode == ModeRational
+}
+
+// round округляет x до масштаба точности. В рациональном режиме x не меняется.
+func (p Precision) round(x *big.Rat) *big.Rat {
+    if p.rational() {
+        return x
+    }
+    return roundRat(x, p.scale(), p.mode())
+}
+
+// format возвращает точную запись x: десятичную или дробь вида 10/3 в рациональном режиме.
+func (p Precision) format(x *big.Rat) string {
+    if p.rational() {
+        return x.RatString()
+    }
+    return formatDecimal(x)
+}
+
+// exactFunctions - встроенные функции, у которых есть точная реализация.
+// Число аргументов проверяется по реестру functions.
+var exactFunctions = map[string]func(args []*big.Rat, precision Precision) (*big.Rat, error){
+    "abs": func(args []*big.Rat, precision Precision) (*big.Rat, error) {
+        return new(big.Rat).Abs(args[0]), nil
+    },
+    "floor": func(args []*big.Rat, precision Precision) (*big.Rat, error) {
+        return floorRat(args[0]), nil
+    },
+    "ceil": func(args []*big.Rat, precision Precision) (*big.Rat, error) {
+        return new(big.Rat).Neg(floorRat(new(big.Rat).Neg(args[0]))), nil
+    },
+    "round": func(args []*big.Rat, precision Precision) (*big.Rat, error) {
+        // Как и math.Round, половина округляется от нуля
+        return roundRat(args[0], 0, RoundHalfUp), nil
+    },
+    "min": func(args []*big.Rat, precision Precision) (*big.Rat, error) {
+        result := args[0]
+        for _, arg := range args[1:] {
+            if arg.Cmp(result) < 0 {
+                result = arg
+            }
+        }
+        return result, nil
+    },
+    "max": func(args []*big.Rat, precision Precision) (*big.Rat, error) {
+        result := args[0]
+        for _, arg := range args[1:] {
+            if arg.Cmp(result) > 0 {
+                result = arg
+            }
+        }
+        return result, nil
+    },
+    "pow": func(args []*big.Rat, precision Precision) (*big.Rat, error) {
+        return powRat(args[0], args[1])
+    },
+    "sqrt": func(args []*big.Rat, precision Precision) (*big.Rat, error) {
+        x := args[0]
+        if x.Sign() < 0 {
+            return nil, ErrDomain
+        }
+        if precision.rational() {
+            return sqrtRat(x)
+        }
+        // Запас двоичных разрядов сверх масштаба, чтобы погрешность не влияла на округление
+        prec := uint(x.Num().BitLen()+x.Denom().BitLen()) + uint(math.Ceil(float64(precision.scale())*math.Log2(10))) + 64
+        root := new(big.Float).SetPrec(prec).SetRat(x)
+        root.Sqrt(root)
+        result, _ := root.Rat(nil)
+        return result, nil
+    },
+}
+
+// EvaluateExact вычисляет выражение так же, как EvaluateWithVariables, но в арифметике произвольной
+// точности: литералы берутся в записи из выражения, каждый результат операции округляется по precision,
+// а итог возвращается точной записью без округления до float64 - десятичной или дробью в рациональном режиме.
+// Операции без точного результата (дробная степень, sin, ln и другие трансцендентные функции,
+// а в рациональном режиме и корень не из квадрата) завершаются ошибкой ErrInexact. Шаги onStep содержат приближенные значения и точный результат в Step.Exact.
+func EvaluateExact(ctx context.Context, operation string, variables map[string]float64, precision Precision, operationTimes OperationTimes, onStep StepFunc) ([]string, string, error) {
+    var operations []string // Срез для хранения описания операций
+
+    if err := precision.Validate(); err != nil {
+        return operations, "", err
+    }
+    tree, err := ParseWithVariables(operation, variables)
+    if err != nil {
+        return operations, "", err
+    }
+
+    record := func(step Step, history string) {
+        operations = append(operations, history)
+        if onStep != nil {
+            onStep(step)
+        }
+    }
+    result, err := evaluateExactNode(ctx, tree, precision, operationTimes, record)
+    if err != nil {
+        return operations, "", err
+    }
+    return operations, precision.format(result), nil
+}
+
+// ExactValue приводит запись числа value (десятичную или дробь вида 10/3) к точному результату режима precision:
+// округляет ее в десятичном режиме и сокращает дробь в рациональном.
+func ExactValue(value string, precision Precision) (string, error) {
+    if err := precision.Validate(); err != nil {
+        return "", err
+    }
+    x, ok := new(big.Rat).SetString(value)
+    if !ok {
+        return "", fmt.Errorf("%w %q", ErrMalformedNumber, value)
+    }
+    return precision.format(precision.round(x)), nil
+}
+
+// evaluateExactNode рекурсивно вычисляет значение узла дерева в точном режиме.
+func evaluateExactNode(ctx context.Context, node Node, precision Precision, operationTimes OperationTimes, record func(Step, string)) (*big.Rat, error) {
+    switch n := node.(type) {
+    case *NumberNode:
+        x, err := numberRat(n)
+        if err != nil {
+            return nil, &EvaluationError{Pos: n.Pos, Op: n.Text, Err: err}
+        }
+        return x, nil
+    case *UnaryNode:
+        operand, err := evaluateExactNode(ctx, n.Operand, precision, operationTimes, record)
+        if err != nil {
+            return nil, err
+        }
+        switch n.Op {
+        case "-":
+            return new(big.Rat).Neg(operand), nil
+        case "+":
+            return operand, nil
+        default:
+            return nil, &EvaluationError{Pos: n.Pos, Op: n.Op, Err: ErrUnknownOperator}
+        }
+    case *BinaryNode:
+        left, err := evaluateExactNode(ctx, n.Left, precision, operationTimes, record)
+        if err != nil {
+            return nil, err
+        }
+        right, err := evaluateExactNode(ctx, n.Right, precision, operationTimes, record)
+        if err != nil {
+            return nil, err
+        }
+        started := time.Now()
+        result, err := performExactOperation(ctx, left, right, n.Op, precision, operationTimes)
+        if err != nil {
+            return nil, &EvaluationError{Pos: n.Pos, Op: n.Op, Err: err}
+        }
+        step := Step{Left: ratFloat(left), Operator: n.Op, Right: ratFloat(right), Result: ratFloat(result), Exact: precision.format(result), Elapsed: time.Since(started)}
+        record(step, fmt.Sprintf("%s %s %s = %s", precision.format(left), n.Op, precision.format(right), step.Exact))
+        return result, nil
+    case *CallNode:
+        args := make([]*big.Rat, len(n.Args))
+        for i, arg := range n.Args {
+            value, err := evaluateExactNode(ctx, arg, precision, operationTimes, record)
+            if err != nil {
+                return nil, err
+            }
+            args[i] = value
+        }
+        started := time.Now()
+        result, err := performExactCall(ctx, n.Name, args, precision, operationTimes)
+        if err != nil {
+            return nil, &EvaluationError{Pos: n.Pos, Op: n.Name, Err: err}
+        }
+        approx := make([]float64, len(args))
+        texts := make([]string, len(args))
+        for i, arg := range args {
+            approx[i] = ratFloat(arg)
+            texts[i] = precision.format(arg)
+        }
+        step := Step{Operator: n.Name, Args: approx, Result: ratFloat(result), Exact: precision.format(result), Elapsed: time.Since(started)}
+        record(step, fmt.Sprintf("%s(%s) = %s", n.Name, strings.Join(texts, ", "), step.Exact))
+        return result, nil
+    default:
+        return nil, &EvaluationError{Pos: node.Position(), Op: fmt.Sprintf("%T", node), Err: ErrUnknownOperator}
+    }
+}
+
+// performExactOperation выполняет бинарную операцию в точном режиме с учетом задержки и округляет результат.
+func performExactOperation(ctx context.Context, left, right *big.Rat, operator string, precision Precision, operationTimes OperationTimes) (*big.Rat, error) {
+    if err := simulateDelay(ctx, operator, operationTimes); err != nil {
+        return nil, err
+    }
+
+    var result *big.Rat
+    switch operator {
+    case "+":
+        result = new(big.Rat).Add(left, right)
+    case "-":
+        result = new(big.Rat).Sub(left, right)
+    case "*":
+        result = new(big.Rat).Mul(left, right)
+    case "/":
+        if right.Sign() == 0 {
+            return nil, ErrDivisionByZero
+        }
+        result = new(big.Rat).Quo(left, right)
+    case "//":
+        if right.Sign() == 0 {
+            return nil, ErrDivisionByZero
+        }
+        result = floorRat(new(big.Rat).Quo(left, right))
+    case "%":
+        // Остаток имеет знак делителя, как и в режиме float64
+        if right.Sign() == 0 {
+            return nil, ErrDivisionByZero
+        }
+        quotient := floorRat(new(big.Rat).Quo(left, right))
+        result = new(big.Rat).Sub(left, quotient.Mul(quotient, right))
+    case "^":
+        var err error
+        if result, err = powRat(left, right); err != nil {
+            return nil, err
+        }
+    default:
+        return nil, ErrUnknownOperator
+    }
+    return precision.round(result), nil
+}
+
+// performExactCall вызывает встроенную функцию name в точном режиме с учетом задержки и округляет результат.
+func performExactCall(ctx context.Context, name string, args []*big.Rat, precision Precision, operationTimes OperationTimes) (*big.Rat, error) {
+    fn, ok := functions[name]
+    if !ok {
+        return nil, ErrUnknownFunction
+    }
+    if !fn.accepts(len(args)) {
+        return nil, ErrArity
+    }
+    exact, ok := exactFunctions[name]
+    if !ok {
+        return nil, ErrInexact
+    }
+    if err := simulateDelay(ctx, name, operationTimes); err != nil {
+        return nil, err
+    }
+
+    result, err := exact(args, precision)
+    if err != nil {
+        return nil, err
+    }
+    return precision.round(result), nil
+}
+
+// numberRat возвращает точное значение литерала. Литерал берется в исходной записи без ограничения
+// диапазона float64, а переменные - в кратчайшей записи их значения float64. Константы pi и e иррациональны,
+// поэтому их значение float64 не считается точным и возвращается ErrInexact. Литерал со слишком большим
+// показателем степени, который big.Rat не принимает, возвращает ErrOverflow.
+func numberRat(n *NumberNode) (*big.Rat, error) {
+    if isConstant(n) {
+        return nil, ErrInexact
+    }
+    x, ok := new(big.Rat).SetString(numberText(n))
+    if !ok {
+        return nil, ErrOverflow
+    }
+    return x, nil
+}
+
+// checkExactNumbers проверяет, что все числа выражения имеют точное значение (numberRat),
+// и возвращает ошибку, обернутую в EvaluationError, для первого числа без него.
+func checkExactNumbers(node Node) error {
+    switch n := node.(type) {
+    case *NumberNode:
+        if _, err := numberRat(n); err != nil {
+            return &EvaluationError{Pos: n.Pos, Op: n.Text, Err: err}
+        }
+    case *UnaryNode:
+        return checkExactNumbers(n.Operand)
+    case *BinaryNode:
+        if err := checkExactNumbers(n.Left); err != nil {
+            return err
+        }
+        return checkExactNumbers(n.Right)
+    case *CallNode:
+        for _, arg := range n.Args {
+            if err := checkExactNumbers(arg); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// powRat возводит base в целую степень exponent. Дробная степень не имеет точного результата.
+func powRat(base, exponent *big.Rat) (*big.Rat, error) {
+    if !exponent.IsInt() {
+        return nil, ErrInexact
+    }
+    e := exponent.Num()
+    if base.Sign() == 0 {
+        if e.Sign() < 0 {
+            return nil, ErrDivisionByZero
+        }
+        if e.Sign() == 0 {
+            return big.NewRat(1, 1), nil
+        }
+        return new(big.Rat), nil
+    }
+    // Степень 1 и -1 не растет, поэтому ограничение размера к ней не применяется; знак -1 зависит от четности показателя
+    if base.IsInt() && base.Num().CmpAbs(big.NewInt(1)) == 0 {
+        if base.Sign() < 0 && e.Bit(0) == 1 {
+            return big.NewRat(-1, 1), nil
+        }
+        return big.NewRat(1, 1), nil
+    }
+
+    power := new(big.Int).Abs(e)
+    bits := base.Num().BitLen()
+    if denBits := base.Denom().BitLen(); denBits > bits {
+        bits = denBits
+    }
+    if !power.IsInt64() || power.Int64() > maxExactBits || int64(bits)*power.Int64() > maxExactBits {
+        return nil, ErrOverflow
+    }
+
+    num := new(big.Int).Exp(base.Num(), power, nil)
+    den := new(big.Int).Exp(base.Denom(), power, nil)
+    if e.Sign() < 0 {
+        num, den = den, num
+    }
+    return new(big.Rat).SetFrac(num, den), nil
+}
+
+// sqrtRat извлекает корень из неотрицательного x, если числитель и знаменатель - точные квадраты.
+func sqrtRat(x *big.Rat) (*big.Rat, error) {
+    num := new(big.Int).Sqrt(x.Num())
+    den := new(big.Int).Sqrt(x.Denom())
+    if new(big.Int).Mul(num, num).Cmp(x.Num()) != 0 || new(big.Int).Mul(den, den).Cmp(x.Denom()) != 0 {
+        return nil, ErrInexact
+    }
+    return new(big.Rat).SetFrac(num, den), nil
+}
+
+// floorRat округляет x вниз до целого.
+func floorRat(x *big.Rat) *big.Rat {
+    // Знаменатель big.Rat всегда положителен, поэтому евклидово деление Div округляет вниз
+    return new(big.Rat).SetInt(new(big.Int).Div(x.Num(), x.Denom()))
+}
+
+// roundRat округляет x до scale знаков после запятой по режиму mode.
+func roundRat(x *big.Rat, scale int, mode string) *big.Rat {
+    factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+    scaled := new(big.Rat).Mul(x, new(big.Rat).SetInt(factor))
+
+    // Частное отсекает дробную часть в сторону нуля, остаток имеет знак x
+    quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+    if remainder.Sign() != 0 {
+        sign := int64(x.Sign())
+        // Сравнение отброшенной части с половиной: 2*|remainder| против знаменателя
+        half := new(big.Int).Abs(remainder)
+        half.Lsh(half, 1)
+        cmp := half.Cmp(scaled.Denom())
+
+        away := false
+        switch mode {
+        case RoundUp:
+            away = true
+        case RoundDown:
+        case RoundCeiling:
+            away = sign > 0
+        case RoundFloor:
+            away = sign < 0
+        case RoundHalfUp:
+            away = cmp >= 0
+        case RoundHalfDown:
+            away = cmp > 0
+        default: // RoundHalfEven
+            away = cmp > 0 || (cmp == 0 && quotient.Bit(0) == 1)
+        }
+        if away {
+            quotient.Add(quotient, big.NewInt(sign))
+        }
+    }
+    return new(big.Rat).SetFrac(quotient, factor)
+}
+
+// formatDecimal возвращает точную десятичную запись x без лишних нулей в дробной части.
+func formatDecimal(x *big.Rat) string {
+    if x.IsInt() {
+        return x.Num().String()
+    }
+    // Знаменатель десятичной дроби вида 2^a * 5^b требует max(a, b) знаков после запятой
+    den := new(big.Int).Set(x.Denom())
+    two, five := big.NewInt(2), big.NewInt(5)
+    twos, fives := 0, 0
+    remainder := new(big.Int)
+    for {
+        quotient, r := new(big.Int).QuoRem(den, two, remainder)
+        if r.Sign() != 0 {
+            break
+        }
+        den, twos = quotient, twos+1
+    }
+    for {
+        quotient, r := new(big.Int).QuoRem(den, five, remainder)
+        if r.Sign() != 0 {
+            break
+        }
+        den, fives = quotient, fives+1
+    }
+    places := max(twos, fives)
+    if den.Cmp(big.NewInt(1)) != 0 {
+        // Бесконечная дробь, например результат sqrt до округления
+        places = MaxScale
+    }
+
+    text := x.FloatString(places)
+    for text[len(text)-1] == '0' {
+        text = text[:len(text)-1]
+    }
+    if text[len(text)-1] == '.' {
+        text = text[:len(text)-1]
+    }
+    return text
+}
+
+// ApproximateExact возвращает ближайшее к точной записи value (десятичной или дроби) значение float64 для полей,
+// которые хранят приближенный результат. Значения за пределами float64 ограничиваются ±math.MaxFloat64,
+// некорректная запись дает 0.
+func ApproximateExact(value string) float64 {
+    x, ok := new(big.Rat).SetString(value)
+    if !ok {
+        return 0
+    }
+    return ratFloat(x)
+}
+
+// ratFloat возвращает ближайшее к x конечное значение float64 для приближенных полей Step.
+func ratFloat(x *big.Rat) float64 {
+    value, _ := x.Float64()
+    if math.IsInf(value, 0) {
+        return math.Copysign(math.MaxFloat64, value)
+    }
+    return value
+}
@@ -0,0 +1,38 @@
+package calculation
+
+import (
+    "errors" // Для объявления типизированных ошибок
+    "fmt"    // Для форматирования сообщений об ошибках
+)
+
+// Типизированные ошибки вычисления. Проверяются через errors.Is.
+var (
+    ErrDivisionByZero  = errors.New("division by zero")               // Деление на ноль
+    ErrMalformedNumber = errors.New("malformed number")               // Некорректная запись числа
+    ErrUnknownOperator = errors.New("unknown operator")               // Оператор не поддерживается калькулятором
+    ErrOverflow        = errors.New("result is not a finite number") // Результат равен ±Inf
+    ErrDomain          = errors.New("argument out of domain")          // Операция не определена для аргументов, например sqrt(-1) или log(0)
+    ErrUnknownFunction = errors.New("unknown function")                // Функция не входит в реестр встроенных функций
+    ErrUnknownName     = errors.New("unknown name")                    // Имя не является встроенной константой или переменной
+    ErrInvalidVariable = errors.New("invalid variable")                // Имя переменной занято или некорректно, либо значение не конечно
+    ErrArity           = errors.New("wrong number of arguments")       // Функция вызвана с неподходящим числом аргументов
+    ErrInvalidPrecision = errors.New("invalid precision")              // Режим вычисления, масштаб или режим округления точного режима некорректны
+    ErrInexact         = errors.New("no exact result")                 // Операция не имеет точного результата, например sin(x) или 2^0.5
+)
+
+// EvaluationError описывает ошибку, возникшую при выполнении конкретной операции выражения.
+type EvaluationError struct {
+    Pos int    // Позиция оператора в выражении (начиная с 1)
+    Op  string // Оператор, при выполнении которого возникла ошибка
+    Err error  // Типизированная причина ошибки
+}
+
+// Error реализует интерфейс error.
+func (e *EvaluationError) Error() string {
+    return fmt.Sprintf("evaluation error at position %d (%s): %v", e.Pos, e.Op, e.Err)
+}
+
+// Unwrap возвращает типизированную причину ошибки для errors.Is.
+func (e *EvaluationError) Unwrap() error {
+    return e.Err
+}
@@ -0,0 +1,151 @@
+package calculation
+
+import (
+    "fmt"  // Для описания числа аргументов и ошибок переменных
+    "math" // Для реализации встроенных функций
+    "sort" // Для проверки переменных в предсказуемом порядке
+)
+
+// Function описывает встроенную функцию выражения.
+type Function struct {
+    MinArgs int                                  // Минимальное число аргументов
+    MaxArgs int                                  // Максимальное число аргументов; -1, если не ограничено
+    call    func(args []float64) (float64, error) // Реализация; аргументы уже проверены по числу
+}
+
+// arity возвращает описание допустимого числа аргументов для сообщений об ошибках.
+func (f Function) arity() string {
+    switch {
+    case f.MaxArgs < 0:
+        return fmt.Sprintf("at least %d", f.MinArgs)
+    case f.MinArgs == f.MaxArgs:
+        return fmt.Sprintf("%d", f.MinArgs)
+    default:
+        return fmt.Sprintf("%d to %d", f.MinArgs, f.MaxArgs)
+    }
+}
+
+// accepts проверяет, можно ли вызвать функцию с count аргументами.
+func (f Function) accepts(count int) bool {
+    return count >= f.MinArgs && (f.MaxArgs < 0 || count <= f.MaxArgs)
+}
+
+// functions - реестр встроенных функций по имени. Длительность вызова задается в OperationTimes по имени функции.
+var functions = map[string]Function{
+    "sqrt": unary(func(x float64) (float64, error) {
+        if x < 0 {
+            return 0, ErrDomain
+        }
+        return math.Sqrt(x), nil
+    }),
+    "abs":   unary(pure(math.Abs)),
+    "floor": unary(pure(math.Floor)),
+    "ceil":  unary(pure(math.Ceil)),
+    "round": unary(pure(math.Round)),
+    "exp":   unary(pure(math.Exp)),
+    "sin":   unary(pure(math.Sin)),
+    "cos":   unary(pure(math.Cos)),
+    "tan":   unary(pure(math.Tan)),
+    "ln": unary(func(x float64) (float64, error) {
+        if x <= 0 {
+            return 0, ErrDomain
+        }
+        return math.Log(x), nil
+    }),
+    // log(x) - десятичный логарифм, log(x, b) - логарифм по основанию b
+    "log": {MinArgs: 1, MaxArgs: 2, call: func(args []float64) (float64, error) {
+        if args[0] <= 0 {
+            return 0, ErrDomain
+        }
+        if len(args) == 1 {
+            return math.Log10(args[0]), nil
+        }
+        if args[1] <= 0 || args[1] == 1 {
+            return 0, ErrDomain
+        }
+        return math.Log(args[0]) / math.Log(args[1]), nil
+    }},
+    "pow": {MinArgs: 2, MaxArgs: 2, call: func(args []float64) (float64, error) {
+        return math.Pow(args[0], args[1]), nil
+    }},
+    "min": {MinArgs: 1, MaxArgs: -1, call: func(args []float64) (float64, error) {
+        result := args[0]
+        for _, arg := range args[1:] {
+            result = math.Min(result, arg)
+        }
+        return result, nil
+    }},
+    "max": {MinArgs: 1, MaxArgs: -1, call: func(args []float64) (float64, error) {
+        result := args[0]
+        for _, arg := range args[1:] {
+            result = math.Max(result, arg)
+        }
+        return result, nil
+    }},
+}
+
+// constants - именованные константы, которые подставляются в выражение при разборе.
+var constants = map[string]float64{
+    "pi": math.Pi,
+    "e":  math.E,
+}
+
+// unary создает функцию одного аргумента.
+func unary(fn func(x float64) (float64, error)) Function {
+    return Function{MinArgs: 1, MaxArgs: 1, call: func(args []float64) (float64, error) {
+        return fn(args[0])
+    }}
+}
+
+// pure приводит функцию, определенную для всех аргументов, к сигнатуре с ошибкой.
+func pure(fn func(x float64) float64) func(x float64) (float64, error) {
+    return func(x float64) (float64, error) {
+        return fn(x), nil
+    }
+}
+
+// LookupFunction возвращает встроенную функцию по имени.
+func LookupFunction(name string) (Function, bool) {
+    fn, ok := functions[name]
+    return fn, ok
+}
+
+// ValidateVariables проверяет переменные выражения: имя должно начинаться с буквы или '_' и состоять из букв,
+// цифр и '_', не совпадать с именем встроенной функции или константы, а значение должно быть конечным числом.
+// Ошибка оборачивает ErrInvalidVariable.
+func ValidateVariables(variables map[string]float64) error {
+    names := make([]string, 0, len(variables))
+    for name := range variables {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        if !isName(name) {
+            return fmt.Errorf("%w %q: name must start with a letter or '_' and contain only letters, digits and '_'", ErrInvalidVariable, name)
+        }
+        if _, ok := functions[name]; ok {
+            return fmt.Errorf("%w %q: name of a built-in function", ErrInvalidVariable, name)
+        }
+        if _, ok := constants[name]; ok {
+            return fmt.Errorf("%w %q: name of a built-in constant", ErrInvalidVariable, name)
+        }
+        if value := variables[name]; math.IsInf(value, 0) || math.IsNaN(value) {
+            return fmt.Errorf("%w %q: value is not a finite number", ErrInvalidVariable, name)
+        }
+    }
+    return nil
+}
+
+// isName проверяет, что строка записана как имя функции, константы или переменной.
+func isName(name string) bool {
+    if name == "" || !isLetter(name[0]) {
+        return false
+    }
+    for i := 1; i < len(name); i++ {
+        if !isLetter(name[i]) && !isDigit(name[i]) {
+            return false
+        }
+    }
+    return true
+}
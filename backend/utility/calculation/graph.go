@@ -0,0 +1,179 @@
+package calculation
+
+import (
+    "strings" // Для сборки выражения задачи
+)
+
+// OperatorNegate обозначает задачу унарного минуса над подвыражением.
+const OperatorNegate = "neg"
+
+// Operand - операнд задачи: либо известное значение, либо результат другой задачи.
+type Operand struct {
+    Value string // Запись числа, если значение операнда уже известно
+    Task  int    // Индекс задачи-источника в Plan.Tasks или -1, если операнд - число
+}
+
+// Task - одна операция графа вычисления. Задачу можно выполнить независимо от остальных,
+// как только известны значения всех ее операндов.
+type Task struct {
+    Op       string    // Оператор: "+", "-", "*", "/", "//", "%", "^", OperatorNegate или имя функции
+    Operands []Operand // Операнды в порядке следования в выражении
+    Parent   int       // Индекс родительской задачи в Plan.Tasks или -1 для корня
+    Position int       // Номер операнда в родительской задаче
+    Pos      int       // Позиция оператора в исходном выражении
+}
+
+// Plan - результат разбиения выражения на граф независимых задач.
+type Plan struct {
+    Tasks []Task // Задачи в порядке, при котором зависимости идут раньше зависящих от них задач; корень последний
+    Value string // Значение выражения, если оно не содержит ни одной операции (Tasks пуст)
+}
+
+// Decompose разбирает выражение и разбивает его на граф бинарных операций и вызовов функций.
+// Унарные плюс и минус над числами сворачиваются в сами числа,
+// унарный минус над подвыражением становится задачей OperatorNegate.
+// Вызов функции становится задачей с именем функции и аргументами в качестве операндов.
+func Decompose(expression string) (*Plan, error) {
+    return DecomposeWithVariables(expression, nil)
+}
+
+// DecomposeWithVariables разбивает выражение так же, как Decompose, подставляя в задачи
+// значения переменных из variables. Литералы за пределами float64 отклоняются с ErrOverflow, как и при Evaluate.
+func DecomposeWithVariables(expression string, variables map[string]float64) (*Plan, error) {
+    tree, err := ParseWithVariables(expression, variables)
+    if err != nil {
+        return nil, err
+    }
+    if err := checkFloatLiterals(tree); err != nil {
+        return nil, err
+    }
+    return decompose(tree), nil
+}
+
+// DecomposeExact разбивает выражение так же, как DecomposeWithVariables, для точного режима.
+// Литералы читаются в исходной записи без ограничения диапазона float64. Константы pi и e не имеют точного
+// значения, поэтому выражение с ними отклоняется с ErrInexact еще до выполнения, как и при EvaluateExact.
+func DecomposeExact(expression string, variables map[string]float64) (*Plan, error) {
+    tree, err := ParseWithVariables(expression, variables)
+    if err != nil {
+        return nil, err
+    }
+    if err := checkExactNumbers(tree); err != nil {
+        return nil, err
+    }
+    return decompose(tree), nil
+}
+
+// decompose строит граф задач по синтаксическому дереву.
+func decompose(tree Node) *Plan {
+    plan := &Plan{}
+    root := plan.add(tree)
+    if root.Task < 0 {
+        plan.Value = root.Value
+    }
+    return plan
+}
+
+// add добавляет в план задачи для узла и возвращает операнд, которым узел представлен в родителе.
+func (p *Plan) add(node Node) Operand {
+    switch n := node.(type) {
+    case *NumberNode:
+        return Operand{Value: numberText(n), Task: -1}
+    case *UnaryNode:
+        operand := p.add(n.Operand)
+        if n.Op == "+" {
+            return operand
+        }
+        // Минус над числом сворачивается без отдельной задачи
+        if operand.Task < 0 {
+            return Operand{Value: negateLiteral(operand.Value), Task: -1}
+        }
+        return p.push(Task{Op: OperatorNegate, Operands: []Operand{operand}, Pos: n.Pos})
+    case *BinaryNode:
+        left := p.add(n.Left)
+        right := p.add(n.Right)
+        return p.push(Task{Op: n.Op, Operands: []Operand{left, right}, Pos: n.Pos})
+    case *CallNode:
+        args := make([]Operand, len(n.Args))
+        for i, arg := range n.Args {
+            args[i] = p.add(arg)
+        }
+        return p.push(Task{Op: n.Name, Operands: args, Pos: n.Pos})
+    default:
+        return Operand{Value: "0", Task: -1}
+    }
+}
+
+// push добавляет задачу в план, связывает с ней задачи-операнды и возвращает ссылку на нее.
+func (p *Plan) push(task Task) Operand {
+    index := len(p.Tasks)
+    task.Parent = -1
+    for position, operand := range task.Operands {
+        if operand.Task >= 0 {
+            p.Tasks[operand.Task].Parent = index
+            p.Tasks[operand.Task].Position = position
+        }
+    }
+    p.Tasks = append(p.Tasks, task)
+    return Operand{Task: index}
+}
+
+// numberText возвращает запись числа для операнда задачи. Литерал сохраняется в исходной записи,
+// чтобы в точном режиме калькулятор получил все его цифры; константы и переменные записываются по значению.
+func numberText(n *NumberNode) string {
+    if n.Text != "" && !isLetter(n.Text[0]) {
+        return n.Text
+    }
+    return formatNumber(n.Value)
+}
+
+// isConstant сообщает, что узел - встроенная константа, а не литерал или переменная.
+// Имена переменных не могут совпадать с константами (ValidateVariables).
+func isConstant(n *NumberNode) bool {
+    _, ok := constants[n.Text]
+    return ok
+}
+
+// negateLiteral меняет знак записи числа.
+func negateLiteral(value string) string {
+    if strings.HasPrefix(value, "-") {
+        return value[1:]
+    }
+    if value == "0" {
+        return value
+    }
+    return "-" + value
+}
+
+// TaskExpression собирает выражение, вычисляющее одну задачу, по ее оператору и значениям операндов.
+// Результат можно передать в Evaluate; отрицательные операнды заключаются в скобки, а дроби рационального
+// режима (10/3) записываются литералом [10/3], который читается одним числом, а не делением.
+func TaskExpression(op string, operands []string) string {
+    literals := make([]string, len(operands))
+    for i, operand := range operands {
+        if strings.Contains(operand, "/") {
+            literals[i] = "[" + operand + "]"
+        } else {
+            literals[i] = operand
+        }
+    }
+
+    // Аргументы функции разделены запятыми, поэтому скобки вокруг отрицательных значений не нужны
+    if _, ok := functions[op]; ok {
+        return op + "(" + strings.Join(literals, ", ") + ")"
+    }
+
+    wrapped := make([]string, len(literals))
+    for i, literal := range literals {
+        if strings.HasPrefix(literal, "-") {
+            wrapped[i] = "(" + literal + ")"
+        } else {
+            wrapped[i] = literal
+        }
+    }
+
+    if op == OperatorNegate && len(wrapped) == 1 {
+        return "-" + wrapped[0]
+    }
+    return strings.Join(wrapped, " "+op+" ")
+}
@@ -0,0 +1,201 @@
+package calculation
+
+import (
+    "errors"   // Для проверки ошибок преобразования чисел
+    "fmt"      // Для форматирования сообщений об ошибках
+    "math/big" // Для значения литерала-дроби
+    "strconv"  // Для преобразования литералов в числа
+    "strings"  // Для разбора литерала-дроби
+)
+
+// tokenKind определяет тип лексемы выражения.
+type tokenKind int
+
+const (
+    tokenNumber   tokenKind = iota // Числовой литерал, например 12, 0.5, 1e-3 или дробь [10/3]
+    tokenOperator                  // Арифметический оператор: + - * / // % ^
+    tokenLParen                    // Открывающая скобка
+    tokenRParen                    // Закрывающая скобка
+    tokenIdent                     // Имя функции, константы или переменной, например sqrt, pi или rate
+    tokenComma                     // Запятая между аргументами функции
+    tokenEOF                       // Конец выражения
+)
+
+// token описывает одну лексему и ее позицию в исходной строке.
+type token struct {
+    kind  tokenKind // Тип лексемы
+    text  string    // Исходный текст лексемы
+    value float64   // Значение числового литерала
+    pos   int       // Позиция первого символа лексемы (начиная с 1)
+}
+
+// SyntaxError описывает ошибку разбора выражения с указанием позиции.
+type SyntaxError struct {
+    Pos int    // Позиция символа в выражении (начиная с 1)
+    Msg string // Описание ошибки
+    Err error  // Типизированная причина ошибки, например ErrMalformedNumber, может быть nil
+}
+
+// Error реализует интерфейс error.
+func (e *SyntaxError) Error() string {
+    return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// Unwrap возвращает типизированную причину ошибки для errors.Is.
+func (e *SyntaxError) Unwrap() error {
+    return e.Err
+}
+
+// tokenize разбивает строку выражения на лексемы.
+// Пробельные символы игнорируются, числа могут быть записаны в экспоненциальной форме или дробью [10/3].
+func tokenize(input string) ([]token, error) {
+    var tokens []token
+    i := 0
+    for i < len(input) {
+        c := input[i]
+        switch {
+        case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+            i++
+        case c == '/' && i+1 < len(input) && input[i+1] == '/':
+            // Целочисленное деление записывается двумя символами
+            tokens = append(tokens, token{kind: tokenOperator, text: "//", pos: i + 1})
+            i += 2
+        case c == '+' || c == '-' || c == '*' || c == '/' || c == '%' || c == '^':
+            tokens = append(tokens, token{kind: tokenOperator, text: string(c), pos: i + 1})
+            i++
+        case c == '(':
+            tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i + 1})
+            i++
+        case c == ')':
+            tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i + 1})
+            i++
+        case c == ',':
+            tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i + 1})
+            i++
+        case isLetter(c):
+            start := i
+            for i < len(input) && (isLetter(input[i]) || isDigit(input[i])) {
+                i++
+            }
+            tokens = append(tokens, token{kind: tokenIdent, text: input[start:i], pos: start + 1})
+        case isDigit(c) || c == '.':
+            tok, next, err := scanNumber(input, i)
+            if err != nil {
+                return nil, err
+            }
+            tokens = append(tokens, tok)
+            i = next
+        case c == '[':
+            tok, next, err := scanFraction(input, i)
+            if err != nil {
+                return nil, err
+            }
+            tokens = append(tokens, tok)
+            i = next
+        default:
+            return nil, &SyntaxError{Pos: i + 1, Msg: fmt.Sprintf("unexpected character %q", c)}
+        }
+    }
+    tokens = append(tokens, token{kind: tokenEOF, pos: len(input) + 1})
+    return tokens, nil
+}
+
+// scanNumber считывает числовой литерал, начиная с позиции start.
+// Возвращает лексему и индекс первого символа после литерала.
+func scanNumber(input string, start int) (token, int, error) {
+    i := start
+    digits := 0
+    for i < len(input) && isDigit(input[i]) {
+        i++
+        digits++
+    }
+    if i < len(input) && input[i] == '.' {
+        i++
+        for i < len(input) && isDigit(input[i]) {
+            i++
+            digits++
+        }
+    }
+    if digits == 0 {
+        return token{}, 0, &SyntaxError{Pos: start + 1, Msg: fmt.Sprintf("malformed number %q", input[start:i]), Err: ErrMalformedNumber}
+    }
+
+    // Экспоненциальная часть: e или E, необязательный знак и хотя бы одна цифра
+    if i < len(input) && (input[i] == 'e' || input[i] == 'E') {
+        j := i + 1
+        if j < len(input) && (input[j] == '+' || input[j] == '-') {
+            j++
+        }
+        if j >= len(input) || !isDigit(input[j]) {
+            return token{}, 0, &SyntaxError{Pos: start + 1, Msg: fmt.Sprintf("malformed number %q", input[start:j]), Err: ErrMalformedNumber}
+        }
+        for j < len(input) && isDigit(input[j]) {
+            j++
+        }
+        i = j
+    }
+
+    // Литерал вида 1.2.3 считается одним некорректным числом, а не двумя лексемами
+    if i < len(input) && input[i] == '.' {
+        for i < len(input) && (isDigit(input[i]) || input[i] == '.') {
+            i++
+        }
+        return token{}, 0, &SyntaxError{Pos: start + 1, Msg: fmt.Sprintf("malformed number %q", input[start:i]), Err: ErrMalformedNumber}
+    }
+
+    text := input[start:i]
+    // Литерал за пределами float64 сохраняется со значением ±Inf (или 0 для слишком маленьких чисел):
+    // точный режим читает его исходную запись, а вычисление в float64 отклоняет его (checkFloatLiterals)
+    value, err := strconv.ParseFloat(text, 64)
+    if err != nil && !errors.Is(err, strconv.ErrRange) {
+        return token{}, 0, &SyntaxError{Pos: start + 1, Msg: fmt.Sprintf("malformed number %q", text), Err: ErrMalformedNumber}
+    }
+    return token{kind: tokenNumber, text: text, value: value, pos: start + 1}, i, nil
+}
+
+// scanFraction считывает литерал-дробь вида [10/3] или [-10/3], начиная с открывающей квадратной скобки.
+// Так задаче передается точный результат рационального режима: дробь читается одним числом,
+// а не делением, и текст лексемы (без скобок) сохраняет ее точную запись.
+func scanFraction(input string, start int) (token, int, error) {
+    i := start + 1
+    for i < len(input) && input[i] != ']' {
+        i++
+    }
+    if i >= len(input) {
+        return token{}, 0, &SyntaxError{Pos: start + 1, Msg: fmt.Sprintf("malformed fraction %q", input[start:i]), Err: ErrMalformedNumber}
+    }
+    text := input[start+1 : i]
+    i++
+
+    // Допускаются только целые числитель со знаком и положительный знаменатель
+    numerator, denominator, ok := strings.Cut(strings.TrimPrefix(text, "-"), "/")
+    if !ok || !isDigits(numerator) || !isDigits(denominator) || strings.Trim(denominator, "0") == "" {
+        return token{}, 0, &SyntaxError{Pos: start + 1, Msg: fmt.Sprintf("malformed fraction %q", input[start:i]), Err: ErrMalformedNumber}
+    }
+    x, _ := new(big.Rat).SetString(text)
+    value, _ := x.Float64()
+    return token{kind: tokenNumber, text: text, value: value, pos: start + 1}, i, nil
+}
+
+// isDigits проверяет, что строка непуста и состоит только из десятичных цифр.
+func isDigits(s string) bool {
+    if s == "" {
+        return false
+    }
+    for i := 0; i < len(s); i++ {
+        if !isDigit(s[i]) {
+            return false
+        }
+    }
+    return true
+}
+
+// isLetter проверяет, может ли байт начинать имя функции, константы или переменной.
+func isLetter(c byte) bool {
+    return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// isDigit проверяет, является ли байт десятичной цифрой.
+func isDigit(c byte) bool {
+    return c >= '0' && c <= '9'
+}
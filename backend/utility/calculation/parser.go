@@ -0,0 +1,251 @@
+package calculation
+
+import (
+    "fmt" // Для форматирования сообщений об ошибках
+)
+
+// Node - узел синтаксического дерева выражения.
+type Node interface {
+    // Position возвращает позицию узла в исходном выражении (начиная с 1).
+    Position() int
+}
+
+// NumberNode - числовой литерал.
+type NumberNode struct {
+    Value float64 // Значение литерала
+    Text  string  // Исходная запись литерала
+    Pos   int     // Позиция литерала в выражении
+}
+
+// UnaryNode - унарная операция (+x или -x).
+type UnaryNode struct {
+    Op      string // Оператор: "+" или "-"
+    Operand Node   // Операнд
+    Pos     int    // Позиция оператора в выражении
+}
+
+// BinaryNode - бинарная операция над двумя подвыражениями.
+type BinaryNode struct {
+    Op    string // Оператор: "+", "-", "*", "/", "//", "%" или "^"
+    Left  Node   // Левый операнд
+    Right Node   // Правый операнд
+    Pos   int    // Позиция оператора в выражении
+}
+
+// CallNode - вызов встроенной функции.
+type CallNode struct {
+    Name string // Имя функции из реестра
+    Args []Node // Аргументы в порядке следования
+    Pos  int    // Позиция имени функции в выражении
+}
+
+// Position реализует интерфейс Node.
+func (n *NumberNode) Position() int { return n.Pos }
+
+// Position реализует интерфейс Node.
+func (n *UnaryNode) Position() int { return n.Pos }
+
+// Position реализует интерфейс Node.
+func (n *BinaryNode) Position() int { return n.Pos }
+
+// Position реализует интерфейс Node.
+func (n *CallNode) Position() int { return n.Pos }
+
+// Parse разбирает строку выражения без переменных и строит синтаксическое дерево.
+func Parse(expression string) (Node, error) {
+    return ParseWithVariables(expression, nil)
+}
+
+// ParseWithVariables разбирает строку выражения и строит синтаксическое дерево.
+// Поддерживаются скобки, унарные плюс и минус, возведение в степень, остаток от деления,
+// целочисленное деление, числа в экспоненциальной форме, литералы-дроби вида [10/3], встроенные функции и константы.
+// Константы и переменные из variables заменяются числами, а неизвестные имена и вызовы с неподходящим
+// числом аргументов считаются синтаксическими ошибками. Переменные должны быть проверены ValidateVariables.
+// При ошибке возвращается *SyntaxError с позицией проблемного символа.
+func ParseWithVariables(expression string, variables map[string]float64) (Node, error) {
+    tokens, err := tokenize(expression)
+    if err != nil {
+        return nil, err
+    }
+
+    p := &parser{tokens: tokens, variables: variables}
+    if p.peek().kind == tokenEOF {
+        return nil, &SyntaxError{Pos: p.peek().pos, Msg: "empty expression"}
+    }
+
+    node, err := p.parseExpression()
+    if err != nil {
+        return nil, err
+    }
+
+    // После разбора выражения не должно оставаться лишних лексем
+    if tok := p.peek(); tok.kind != tokenEOF {
+        return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+    }
+    return node, nil
+}
+
+// parser реализует разбор методом рекурсивного спуска по грамматике:
+//
+//  expression = term { ("+" | "-") term }
+//  term       = unary { ("*" | "/" | "//" | "%") unary }
+//  unary      = ("+" | "-") unary | power
+//  power      = primary [ "^" unary ]
+//  primary    = number | name | name "(" expression { "," expression } ")" | "(" expression ")"
+type parser struct {
+    tokens    []token            // Лексемы выражения, последняя всегда tokenEOF
+    pos       int                // Индекс текущей лексемы
+    variables map[string]float64 // Значения переменных выражения
+}
+
+// peek возвращает текущую лексему, не сдвигая позицию.
+func (p *parser) peek() token {
+    return p.tokens[p.pos]
+}
+
+// next возвращает текущую лексему и переходит к следующей.
+func (p *parser) next() token {
+    tok := p.tokens[p.pos]
+    if tok.kind != tokenEOF {
+        p.pos++
+    }
+    return tok
+}
+
+// parseExpression разбирает сложение и вычитание (левоассоциативные).
+func (p *parser) parseExpression() (Node, error) {
+    left, err := p.parseTerm()
+    if err != nil {
+        return nil, err
+    }
+    for {
+        tok := p.peek()
+        if tok.kind != tokenOperator || (tok.text != "+" && tok.text != "-") {
+            return left, nil
+        }
+        p.next()
+        right, err := p.parseTerm()
+        if err != nil {
+            return nil, err
+        }
+        left = &BinaryNode{Op: tok.text, Left: left, Right: right, Pos: tok.pos}
+    }
+}
+
+// parseTerm разбирает умножение, деление, целочисленное деление и остаток (левоассоциативные).
+func (p *parser) parseTerm() (Node, error) {
+    left, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    for {
+        tok := p.peek()
+        if tok.kind != tokenOperator || (tok.text != "*" && tok.text != "/" && tok.text != "//" && tok.text != "%") {
+            return left, nil
+        }
+        p.next()
+        right, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        left = &BinaryNode{Op: tok.text, Left: left, Right: right, Pos: tok.pos}
+    }
+}
+
+// parseUnary разбирает унарные плюс и минус.
+func (p *parser) parseUnary() (Node, error) {
+    tok := p.peek()
+    if tok.kind == tokenOperator && (tok.text == "+" || tok.text == "-") {
+        p.next()
+        operand, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        return &UnaryNode{Op: tok.text, Operand: operand, Pos: tok.pos}, nil
+    }
+    return p.parsePower()
+}
+
+// parsePower разбирает возведение в степень. Оно связывает сильнее унарного минуса (-2^2 = -4)
+// и правоассоциативно (2^3^2 = 2^9), а показатель может иметь знак (2^-1).
+func (p *parser) parsePower() (Node, error) {
+    base, err := p.parsePrimary()
+    if err != nil {
+        return nil, err
+    }
+    tok := p.peek()
+    if tok.kind != tokenOperator || tok.text != "^" {
+        return base, nil
+    }
+    p.next()
+    exponent, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    return &BinaryNode{Op: tok.text, Left: base, Right: exponent, Pos: tok.pos}, nil
+}
+
+// parsePrimary разбирает числовой литерал, константу, вызов функции или выражение в скобках.
+func (p *parser) parsePrimary() (Node, error) {
+    tok := p.next()
+    switch tok.kind {
+    case tokenNumber:
+        return &NumberNode{Value: tok.value, Text: tok.text, Pos: tok.pos}, nil
+    case tokenIdent:
+        if p.peek().kind == tokenLParen {
+            return p.parseCall(tok)
+        }
+        if value, ok := constants[tok.text]; ok {
+            return &NumberNode{Value: value, Text: tok.text, Pos: tok.pos}, nil
+        }
+        if value, ok := p.variables[tok.text]; ok {
+            return &NumberNode{Value: value, Text: tok.text, Pos: tok.pos}, nil
+        }
+        return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unknown name %q", tok.text), Err: ErrUnknownName}
+    case tokenLParen:
+        node, err := p.parseExpression()
+        if err != nil {
+            return nil, err
+        }
+        if closing := p.next(); closing.kind != tokenRParen {
+            return nil, &SyntaxError{Pos: closing.pos, Msg: fmt.Sprintf("expected ')' to close '(' at position %d", tok.pos)}
+        }
+        return node, nil
+    case tokenEOF:
+        return nil, &SyntaxError{Pos: tok.pos, Msg: "unexpected end of expression"}
+    default:
+        return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+    }
+}
+
+// parseCall разбирает аргументы вызова функции name и проверяет их число.
+func (p *parser) parseCall(name token) (Node, error) {
+    fn, ok := functions[name.text]
+    if !ok {
+        return nil, &SyntaxError{Pos: name.pos, Msg: fmt.Sprintf("unknown function %q", name.text), Err: ErrUnknownFunction}
+    }
+
+    opening := p.next()
+    var args []Node
+    if p.peek().kind != tokenRParen {
+        for {
+            arg, err := p.parseExpression()
+            if err != nil {
+                return nil, err
+            }
+            args = append(args, arg)
+            if p.peek().kind != tokenComma {
+                break
+            }
+            p.next()
+        }
+    }
+    if closing := p.next(); closing.kind != tokenRParen {
+        return nil, &SyntaxError{Pos: closing.pos, Msg: fmt.Sprintf("expected ')' to close '(' at position %d", opening.pos)}
+    }
+
+    if !fn.accepts(len(args)) {
+        return nil, &SyntaxError{Pos: name.pos, Msg: fmt.Sprintf("%s expects %s arguments, got %d", name.text, fn.arity(), len(args)), Err: ErrArity}
+    }
+    return &CallNode{Name: name.text, Args: args, Pos: name.pos}, nil
+}
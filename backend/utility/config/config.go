@@ -0,0 +1,307 @@
+// Пакет config загружает настройки оркестратора: подключение к базе данных, ключ подписи JWT,
+// адреса серверов и интервалы фоновых задач. Значения берутся из значений по умолчанию,
+// затем из необязательного JSON-файла и затем из переменных окружения.
+package config
+
+import (
+	"encoding/json" // Для разбора файла конфигурации
+	"errors"        // Для объединения ошибок проверки
+	"fmt"           // Для форматирования ошибок
+	"os"            // Для чтения файла и переменных окружения
+	"strconv"       // Для разбора числовых переменных окружения
+	"strings"       // Для разбора списков в переменных окружения
+	"time"          // Для интервалов
+)
+
+// Хранилища, которые может использовать оркестратор.
+const (
+	StorePostgres = "postgres" // PostgreSQL по параметрам Database
+	StoreSQLite   = "sqlite"   // Встроенный SQLite в файле SQLitePath
+	StoreMemory   = "memory"   // Память процесса; данные теряются при остановке
+)
+
+// Стратегии выбора калькулятора для подзадачи.
+const (
+	SchedulerLeastLoaded = "least-loaded" // Наименее загруженный по отчету о горутинах и ожидаемому времени операций
+	SchedulerRoundRobin  = "round-robin"  // Калькуляторы по очереди
+	SchedulerWeighted    = "weighted"     // Пропорционально весам калькуляторов
+)
+
+// Значения секретов по умолчанию. Они известны всем, поэтому без режима разработки запуск с ними запрещен.
+const (
+	DefaultJWTKey     = "secret_key"
+	DefaultDBPassword = "123QWEasdf"
+	DefaultAgentToken = "agent_token"
+)
+
+// Duration - длительность, которая в JSON записывается строкой вида "2s" или "1m30s".
+type Duration time.Duration
+
+// UnmarshalJSON разбирает длительность из строки в формате time.ParseDuration.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string like \"2s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON записывает длительность строкой.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Database - параметры подключения к PostgreSQL.
+type Database struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Name     string `json:"name"`
+	SSLMode  string `json:"sslmode"`
+}
+
+// DSN возвращает строку подключения для драйвера lib/pq.
+func (d Database) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s", d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode)
+}
+
+// Config - настройки оркестратора.
+type Config struct {
+	Store            string   `json:"store"`            // Хранилище: postgres, sqlite или memory
+	SQLitePath       string   `json:"sqlitePath"`       // Файл базы SQLite для хранилища sqlite
+	Database         Database `json:"database"`
+	JWTKey           string   `json:"jwtKey"`           // Ключ подписи токенов HS256
+	AgentToken       string   `json:"agentToken"`       // Общий секрет калькуляторов для /internal/task
+	HTTPAddr         string   `json:"httpAddr"`         // Адрес HTTP API
+	GRPCAddr         string   `json:"grpcAddr"`         // Адрес gRPC сервера для калькуляторов
+	SubmitInterval   Duration `json:"submitInterval"`   // Период отправки готовых подзадач калькуляторам
+	RestartInterval  Duration `json:"restartInterval"`  // Период проверки зависших подзадач
+	HeartbeatTimeout Duration `json:"heartbeatTimeout"` // Время без heartbeat, после которого калькулятор удаляется из реестра
+	InstanceID       string   `json:"instanceId"`       // Имя экземпляра оркестратора, владельца аренды подзадач
+	LeaseDuration    Duration `json:"leaseDuration"`    // Время, на которое экземпляр арендует подзадачу для отправки калькулятору
+	Scheduler        string   `json:"scheduler"`        // Стратегия выбора калькулятора: least-loaded, round-robin или weighted
+	AgentWeights     map[string]int `json:"agentWeights"` // Веса калькуляторов по имени для стратегии weighted; по умолчанию вес равен числу горутин
+	MaxAttempts      int      `json:"maxAttempts"`      // Число попыток вычисления, после которого оно переходит в статус failed
+	RetryBackoff     Duration `json:"retryBackoff"`     // Пауза перед второй попыткой, удваивается с каждой следующей
+	MaxRetryBackoff  Duration `json:"maxRetryBackoff"`  // Наибольшая пауза между попытками
+	AdminLogins      []string `json:"adminLogins"`      // Логины пользователей с доступом к /api/v1/admin
+	TaskTimeout      Duration `json:"taskTimeout"`      // Ожидание подзадачи сверх длительности операции, если inactive_server_time не задано
+	MaxTaskTimeout   Duration `json:"maxTaskTimeout"`   // Наибольшее ожидание подзадачи сверх длительности операции
+	Dev              bool     `json:"dev"`              // Режим разработки: разрешает секреты по умолчанию
+}
+
+// Default возвращает настройки по умолчанию, совпадающие с прежними значениями в коде.
+func Default() Config {
+	return Config{
+		Store:      StorePostgres,
+		SQLitePath: "calculator.db",
+		Database: Database{
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			Password: DefaultDBPassword,
+			Name:     "postgres",
+			SSLMode:  "disable",
+		},
+		JWTKey:           DefaultJWTKey,
+		AgentToken:       DefaultAgentToken,
+		HTTPAddr:         ":8080",
+		GRPCAddr:         ":50050",
+		SubmitInterval:   Duration(2 * time.Second),
+		RestartInterval:  Duration(time.Minute),
+		HeartbeatTimeout: Duration(15 * time.Second),
+		InstanceID:       defaultInstanceID(),
+		LeaseDuration:    Duration(30 * time.Second),
+		Scheduler:        SchedulerLeastLoaded,
+		MaxAttempts:      3,
+		RetryBackoff:     Duration(5 * time.Second),
+		MaxRetryBackoff:  Duration(5 * time.Minute),
+		TaskTimeout:      Duration(3 * time.Minute),
+		MaxTaskTimeout:   Duration(30 * time.Minute),
+	}
+}
+
+// defaultInstanceID возвращает имя экземпляра, уникальное для процесса: имя хоста и PID.
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "orchestrator"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// Load собирает настройки из значений по умолчанию, JSON-файла path (если он задан) и переменных окружения,
+// после чего проверяет их. Переменные окружения имеют приоритет над файлом; dev включает режим разработки
+// независимо от файла и окружения (флаг -dev).
+func Load(path string, dev bool) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+	if dev {
+		cfg.Dev = true
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// applyEnv переопределяет настройки значениями переменных окружения.
+func applyEnv(cfg *Config) error {
+	setString(&cfg.Store, "STORE")
+	setString(&cfg.SQLitePath, "SQLITE_PATH")
+	setString(&cfg.Database.Host, "DB_HOST")
+	setString(&cfg.Database.User, "DB_USER")
+	setString(&cfg.Database.Password, "DB_PASSWORD")
+	setString(&cfg.Database.Name, "DB_NAME")
+	setString(&cfg.Database.SSLMode, "DB_SSLMODE")
+	setString(&cfg.JWTKey, "JWT_KEY")
+	setString(&cfg.AgentToken, "AGENT_TOKEN")
+	setString(&cfg.HTTPAddr, "ORCHESTRATOR_HTTP_LISTEN")
+	setString(&cfg.GRPCAddr, "ORCHESTRATOR_GRPC_LISTEN")
+	setString(&cfg.InstanceID, "ORCHESTRATOR_INSTANCE_ID")
+	setString(&cfg.Scheduler, "SCHEDULER")
+
+	if value := os.Getenv("DB_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("DB_PORT: %w", err)
+		}
+		cfg.Database.Port = port
+	}
+	if value := os.Getenv("MAX_ATTEMPTS"); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("MAX_ATTEMPTS: %w", err)
+		}
+		cfg.MaxAttempts = attempts
+	}
+	if value := os.Getenv("ADMIN_LOGINS"); value != "" {
+		cfg.AdminLogins = strings.Split(value, ",")
+	}
+	if value := os.Getenv("DEV_MODE"); value != "" {
+		dev, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("DEV_MODE: %w", err)
+		}
+		cfg.Dev = dev
+	}
+
+	durations := []struct {
+		name   string
+		target *Duration
+	}{
+		{"SUBMIT_INTERVAL", &cfg.SubmitInterval},
+		{"RESTART_INTERVAL", &cfg.RestartInterval},
+		{"HEARTBEAT_TIMEOUT", &cfg.HeartbeatTimeout},
+		{"LEASE_DURATION", &cfg.LeaseDuration},
+		{"RETRY_BACKOFF", &cfg.RetryBackoff},
+		{"MAX_RETRY_BACKOFF", &cfg.MaxRetryBackoff},
+		{"TASK_TIMEOUT", &cfg.TaskTimeout},
+		{"MAX_TASK_TIMEOUT", &cfg.MaxTaskTimeout},
+	}
+	for _, d := range durations {
+		if value := os.Getenv(d.name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", d.name, err)
+			}
+			*d.target = Duration(parsed)
+		}
+	}
+	return nil
+}
+
+// setString заменяет значение, если переменная окружения задана.
+func setString(target *string, name string) {
+	if value := os.Getenv(name); value != "" {
+		*target = value
+	}
+}
+
+// Validate проверяет настройки и возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Store {
+	case StorePostgres:
+		if c.Database.Host == "" || c.Database.User == "" || c.Database.Name == "" {
+			errs = append(errs, errors.New("database host, user and name are required"))
+		}
+		if c.Database.Port <= 0 || c.Database.Port > 65535 {
+			errs = append(errs, fmt.Errorf("database port %d is out of range", c.Database.Port))
+		}
+	case StoreSQLite:
+		if c.SQLitePath == "" {
+			errs = append(errs, errors.New("SQLite path is required for the sqlite store"))
+		}
+	case StoreMemory:
+	default:
+		errs = append(errs, fmt.Errorf("unknown store %q, expected postgres, sqlite or memory", c.Store))
+	}
+	if c.JWTKey == "" {
+		errs = append(errs, errors.New("JWT key is required"))
+	}
+	if c.AgentToken == "" {
+		errs = append(errs, errors.New("agent token is required"))
+	}
+	if c.HTTPAddr == "" || c.GRPCAddr == "" {
+		errs = append(errs, errors.New("HTTP and gRPC listen addresses are required"))
+	}
+	if c.SubmitInterval <= 0 || c.RestartInterval <= 0 || c.HeartbeatTimeout <= 0 || c.LeaseDuration <= 0 {
+		errs = append(errs, errors.New("submit, restart, heartbeat and lease intervals must be positive"))
+	}
+	if c.InstanceID == "" {
+		errs = append(errs, errors.New("instance ID is required"))
+	}
+	switch c.Scheduler {
+	case SchedulerLeastLoaded, SchedulerRoundRobin, SchedulerWeighted:
+	default:
+		errs = append(errs, fmt.Errorf("unknown scheduler %q, expected least-loaded, round-robin or weighted", c.Scheduler))
+	}
+	if c.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("max attempts must be at least 1, got %d", c.MaxAttempts))
+	}
+	if c.RetryBackoff < 0 || c.MaxRetryBackoff < c.RetryBackoff {
+		errs = append(errs, errors.New("retry backoff must not be negative or exceed the max retry backoff"))
+	}
+	if c.TaskTimeout <= 0 || c.MaxTaskTimeout < c.TaskTimeout {
+		errs = append(errs, errors.New("task timeout must be positive and not exceed the max task timeout"))
+	}
+	for name, weight := range c.AgentWeights {
+		if weight <= 0 {
+			errs = append(errs, fmt.Errorf("weight of agent %q must be positive", name))
+		}
+	}
+
+	// Секреты по умолчанию допустимы только при явно включенном режиме разработки
+	if !c.Dev {
+		if c.JWTKey == DefaultJWTKey {
+			errs = append(errs, errors.New("refusing to use the default JWT key outside dev mode; set JWT_KEY"))
+		}
+		if c.AgentToken == DefaultAgentToken {
+			errs = append(errs, errors.New("refusing to use the default agent token outside dev mode; set AGENT_TOKEN"))
+		}
+		if c.Store == StorePostgres && c.Database.Password == DefaultDBPassword {
+			errs = append(errs, errors.New("refusing to use the default database password outside dev mode; set DB_PASSWORD"))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadFileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{
+		"database": {"host": "db", "password": "file_password"},
+		"jwtKey": "file_key_file_key",
+		"agentToken": "file_agent_token",
+		"submitInterval": "5s"
+	}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Переменные окружения имеют приоритет над файлом
+	t.Setenv("DB_HOST", "db.internal")
+	t.Setenv("HEARTBEAT_TIMEOUT", "30s")
+
+	cfg, err := Load(path, false)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Database.Host != "db.internal" || cfg.Database.Password != "file_password" || cfg.Database.Port != 5432 {
+		t.Errorf("Unexpected database settings: %+v", cfg.Database)
+	}
+	if cfg.JWTKey != "file_key_file_key" || cfg.AgentToken != "file_agent_token" {
+		t.Errorf("Expected JWT key and agent token from file, got %q, %q", cfg.JWTKey, cfg.AgentToken)
+	}
+	if time.Duration(cfg.SubmitInterval) != 5*time.Second || time.Duration(cfg.HeartbeatTimeout) != 30*time.Second {
+		t.Errorf("Unexpected intervals: submit %v, heartbeat %v", time.Duration(cfg.SubmitInterval), time.Duration(cfg.HeartbeatTimeout))
+	}
+}
+
+func TestLoadRefusesDefaultSecrets(t *testing.T) {
+	_, err := Load("", false)
+	if err == nil {
+		t.Fatal("Expected default secrets to be rejected outside dev mode")
+	}
+	if !strings.Contains(err.Error(), "JWT key") || !strings.Contains(err.Error(), "agent token") || !strings.Contains(err.Error(), "database password") {
+		t.Errorf("Expected all default secrets to be reported, got %v", err)
+	}
+
+	if _, err := Load("", true); err != nil {
+		t.Errorf("Expected default secrets to be accepted with the dev flag, got %v", err)
+	}
+
+	t.Setenv("DEV_MODE", "true")
+	if _, err := Load("", false); err != nil {
+		t.Errorf("Expected default secrets to be accepted with DEV_MODE, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Dev = true
+	cfg.Database.Port = 0
+	cfg.SubmitInterval = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected invalid port and interval to be rejected")
+	}
+}
+
+func TestValidateStore(t *testing.T) {
+	cfg := Default()
+	cfg.JWTKey = "file_key_file_key"
+	cfg.AgentToken = "file_agent_token"
+	cfg.Store = StoreMemory
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected memory store to ignore database settings, got %v", err)
+	}
+
+	cfg.Store = "mysql"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unknown store") {
+		t.Errorf("Expected unknown store to be rejected, got %v", err)
+	}
+}
+
+func TestValidateScheduler(t *testing.T) {
+	cfg := Default()
+	cfg.Dev = true
+	t.Setenv("SCHEDULER", SchedulerRoundRobin)
+	if err := applyEnv(&cfg); err != nil || cfg.Scheduler != SchedulerRoundRobin {
+		t.Fatalf("Expected scheduler from SCHEDULER, got %q (%v)", cfg.Scheduler, err)
+	}
+
+	cfg.Scheduler = "random"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unknown scheduler") {
+		t.Errorf("Expected unknown scheduler to be rejected, got %v", err)
+	}
+
+	cfg.Scheduler = SchedulerWeighted
+	cfg.AgentWeights = map[string]int{"calculator1": 0}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "calculator1") {
+		t.Errorf("Expected non-positive weight to be rejected, got %v", err)
+	}
+}
+
+func TestRetrySettings(t *testing.T) {
+	cfg := Default()
+	cfg.Dev = true
+	t.Setenv("MAX_ATTEMPTS", "5")
+	t.Setenv("RETRY_BACKOFF", "1s")
+	t.Setenv("ADMIN_LOGINS", "alice,bob")
+	t.Setenv("TASK_TIMEOUT", "1m")
+	if err := applyEnv(&cfg); err != nil {
+		t.Fatalf("applyEnv returned error: %v", err)
+	}
+	if cfg.MaxAttempts != 5 || time.Duration(cfg.RetryBackoff) != time.Second || len(cfg.AdminLogins) != 2 || cfg.AdminLogins[1] != "bob" || time.Duration(cfg.TaskTimeout) != time.Minute {
+		t.Errorf("Unexpected retry settings: %+v", cfg)
+	}
+
+	cfg.MaxAttempts = 0
+	cfg.RetryBackoff = Duration(time.Hour)
+	cfg.MaxTaskTimeout = Duration(time.Second)
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "max attempts") || !strings.Contains(err.Error(), "retry backoff") || !strings.Contains(err.Error(), "task timeout") {
+		t.Errorf("Expected invalid retry settings to be rejected, got %v", err)
+	}
+}
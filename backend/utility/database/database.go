@@ -3,41 +3,44 @@ package database
 
 import (
 	"database/sql" // Импорт пакета для работы с SQL базами данных
+	"errors"       // Для объявления ошибок
 	"fmt"          // Форматированный вывод
 	"time"         // Работа со временем
 	"log"          // Логирование
 	"sync"         // Синхронизация горутин
+	"calculatorapi/utility/calculation" // Точный режим вычисления
+	"calculatorapi/utility/config" // Параметры подключения к базе данных
 	"calculatorapi/utility/models" // Структуры данных для калькулятора
 
 	_ "github.com/lib/pq" // Драйвер PostgreSQL
     "golang.org/x/crypto/bcrypt" // Драйвер для хэширования паролей
 )
 
-// Параметры подключения к базе данных
-const (
-	host     = "localhost"
-	port     = 5432
-	user     = "postgres"
-	password = "123QWEasdf"
-	dbname   = "postgres"
-)
-
 var (
 	db   *sql.DB       // Глобальный объект базы данных
 	dbMu sync.Mutex    // Мьютекс для защиты доступа к объекту базы данных
+	settings = config.Default().Database // Параметры подключения, переданные в InitializeDB
 )
 
-// InitializeDB устанавливает новое соединение с базой данных.
-func InitializeDB() {
+// ErrUserNotFound возвращается, если пользователя с таким логином нет.
+var ErrUserNotFound = errors.New("user not found")
+
+// InitializeDB устанавливает новое соединение с базой данных с указанными параметрами.
+// Параметры запоминаются для повторных подключений в GetDB и для SetupDatabase.
+func InitializeDB(cfg config.Database) {
 	dbMu.Lock() // Блокировка мьютекса
 	defer dbMu.Unlock() // Освобождение мьютекса при выходе из функции
 
+	settings = cfg
 	if db != nil {
 		return // Если соединение уже установлено, ничего не делаем
 	}
+	connect()
+}
 
-	// Формирование строки подключения
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+// connect открывает соединение по сохраненным параметрам. Вызывается под dbMu.
+func connect() {
+	psqlInfo := settings.DSN() // Формирование строки подключения
 	var err error
 	db, err = sql.Open("postgres", psqlInfo) // Открытие соединения
 	if err != nil {
@@ -72,13 +75,14 @@ func GetDB() *sql.DB {
 	defer dbMu.Unlock() // Освобождение мьютекса
 
 	if db == nil {
-		InitializeDB() // Инициализация соединения, если оно ещё не установлено
+		connect() // Инициализация соединения, если оно ещё не установлено
 	}
 
 	// Проверка, живо ли соединение
 	if err := db.Ping(); err != nil {
 		fmt.Println("Reconnecting to the database...")
-		InitializeDB() // Повторная инициализация при необходимости
+		db.Close()
+		connect() // Повторная инициализация при необходимости
 	}
 
 	return db
@@ -86,7 +90,7 @@ func GetDB() *sql.DB {
 
 // ConnectToDatabase создает и возвращает новое соединение с базой данных (используется для демонстрации; в реальных условиях лучше использовать GetDB).
 func ConnectToDatabase() (*sql.DB, error) {
-    psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+    psqlInfo := settings.DSN()
     db, err := sql.Open("postgres", psqlInfo)
     if err != nil {
         return nil, err // Возвращение ошибки при неудаче
@@ -94,10 +98,12 @@ func ConnectToDatabase() (*sql.DB, error) {
     return db, nil // Возвращение объекта соединения
 }
 
-// SetupDatabase проверяет наличие базы данных и таблицы; при отсутствии создает их.
+// SetupDatabase проверяет наличие базы данных, создает ее при отсутствии и применяет миграции схемы.
+// Возвращает ErrSchemaAhead, если база данных мигрирована более новой версией программы.
 func SetupDatabase() (*sql.DB, error) {
 	// Аналогично ConnectToDatabase, но с дополнительными шагами настройки
-    psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+    psqlInfo := settings.DSN()
+    dbname := settings.Name
     db, err := sql.Open("postgres", psqlInfo)
     if err != nil {
         return nil, err
@@ -115,16 +121,8 @@ func SetupDatabase() (*sql.DB, error) {
         }
         fmt.Printf("Database '%s' created successfully.\n", dbname)
     }
-    fmt.Println("Checking and creating tables if necessary...")
-    err = CreateTableIfNotExists(db)
-    if err != nil {
-        log.Fatalf("Failed to create Calculations tables: %v", err)
-        return nil, err
-    }
-
-    err = CreateUserTableIfNotExists(db)
-    if err != nil {
-        log.Fatalf("Failed to create User tables: %v", err)
+    fmt.Println("Applying schema migrations if necessary...")
+    if err := MigrateUp(db); err != nil {
         return nil, err
     }
 
@@ -142,50 +140,8 @@ func DatabaseExists(db *sql.DB, dbName string) (bool, error) {
 	return exists, nil
 }
 
-// CreateTableIfNotExists создает таблицу, если она еще не существует.
-func CreateTableIfNotExists(db *sql.DB) error {
-	// Выполнение SQL запроса для создания таблицы
-	var tableExists bool
-	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'calculations')").Scan(&tableExists)
-	if err != nil {
-		return err
-	}
-
-	if tableExists {
-		fmt.Println("Table 'calculations' already exists.")
-		return nil
-	}
-
-	query := `
-		CREATE TABLE calculations (
-			id SERIAL PRIMARY KEY,
-            userId INTEGER NOT NULL,
-			operation TEXT,
-			result DOUBLE PRECISION,
-			status TEXT,
-			created_time TIMESTAMP,
-			start_time TIMESTAMP,
-			end_time TIMESTAMP,
-			operation_server TEXT,
-			server_status TEXT,
-			add_duration INTEGER,
-			subtract_duration INTEGER,
-			multiply_duration INTEGER,
-			divide_duration INTEGER,
-			inactive_server_time INTEGER
-		)
-	`
-
-	_, err = db.Exec(query)
-	if err != nil {
-		return err
-	}
-	fmt.Println("Table 'calculations' created successfully.")
-	return nil
-}
-
 // InsertCalculation вставляет новую запись о вычислении в таблицу 'calculations'.
-func InsertCalculation(db *sql.DB, userId int, operation string, addDuration, subtractDuration, multiplyDuration, divideDuration, inactiveServerTime int) (int, error) {
+func InsertCalculation(db *sql.DB, userId int, operation string, addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration, inactiveServerTime int, functionDurations map[string]int, variables map[string]float64, precision *calculation.Precision) (int, error) {
     // Вставка данных о вычислении и возвращение идентификатора записи
     if err := db.Ping(); err != nil {
         // If not, attempt to reconnect
@@ -199,17 +155,27 @@ func InsertCalculation(db *sql.DB, userId int, operation string, addDuration, su
         }
     }
 
+    functions, err := encodeMap(functionDurations)
+    if err != nil {
+        return 0, err
+    }
+    values, err := encodeMap(variables)
+    if err != nil {
+        return 0, err
+    }
+    scale, rounding, mode := encodePrecision(precision)
+
     // Proceed with the insertion
     query := `
-        INSERT INTO calculations (userId, operation, status, created_time, add_duration, subtract_duration, multiply_duration, divide_duration, inactive_server_time)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        INSERT INTO calculations (userId, operation, status, created_time, add_duration, subtract_duration, multiply_duration, divide_duration, power_duration, modulo_duration, int_divide_duration, inactive_server_time, function_durations, variables, precision_scale, rounding_mode, precision_mode)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
         RETURNING id
     `
     status := `created`
     createdTime := time.Now().UTC()
 
     var id int
-    err := db.QueryRow(query, userId, operation, status, createdTime, addDuration, subtractDuration, multiplyDuration, divideDuration, inactiveServerTime).Scan(&id)
+    err = db.QueryRow(query, userId, operation, status, createdTime, addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration, inactiveServerTime, functions, values, scale, rounding, mode).Scan(&id)
     if err != nil {
         return 0, err
     }
@@ -280,17 +246,19 @@ func checkAndPrintCreatedRecords(db *sql.DB) error {
 }
 
 // UpdateCalculation обновляет запись о вычислении в таблице 'calculations' по ID.
-func UpdateCalculation(db *sql.DB, id int, result float64, status string) error {
+// exact - точная запись результата для вычисления в точном режиме, пустая в режиме float64.
+// Завершенное, отмененное или исчерпавшее попытки вычисление не изменяется.
+func UpdateCalculation(db *sql.DB, id int, result float64, exact string, status string) error {
     // SQL-запрос для обновления записи.
     query := `
         UPDATE calculations
-        SET result = $1, status = $2, end_time = $3
-        WHERE id = $4
+        SET result = $1, exact_result = $2, status = $3, end_time = $4
+        WHERE id = $5 AND status IN ('created', 'work')
     `
     endTime := time.Now().UTC()
 
     // Выполнение запроса.
-    _, err := db.Exec(query, result, status, endTime, id)
+    _, err := db.Exec(query, result, exactResult(exact), status, endTime, id)
     if err != nil {
         return err
     }
@@ -299,49 +267,41 @@ func UpdateCalculation(db *sql.DB, id int, result float64, status string) error
     return nil
 }
 
-// UpdateCalculationStatusToWork обновляет статус вычисления на 'work' и устанавливает start_time.
-func UpdateCalculationStatusToWork(db *sql.DB, id int) error {
-    // SQL-запрос для обновления статуса и времени начала.
+// UpdateCalculationError переводит незавершенное вычисление в статус 'error' и сохраняет сообщение об ошибке.
+func UpdateCalculationError(db *sql.DB, id int, message string) error {
+    // SQL-запрос для обновления статуса, сообщения об ошибке и времени завершения.
     query := `
         UPDATE calculations
-        SET status = 'work', start_time = timezone('UTC', NOW())
-        WHERE id = $1
+        SET result = NULL, status = 'error', error_message = $1, end_time = $2, exact_result = NULL
+        WHERE id = $3 AND status IN ('created', 'work')
     `
+    endTime := time.Now().UTC()
 
-    _, err := db.Exec(query, id)
+    _, err := db.Exec(query, message, endTime, id)
     if err != nil {
-        return fmt.Errorf("error updating calculation status to work and setting start time: %w", err)
+        return fmt.Errorf("error updating calculation status to error: %w", err)
     }
 
-    fmt.Printf("Calculation record with ID %d status updated to work and start time set.\n", id)
+    fmt.Printf("Calculation record with ID %d marked as error: %s\n", id, message)
     return nil
 }
 
-// FetchCalculationsToProcess извлекает максимум 5 строк со статусом "created".
-func FetchCalculationsToProcess(db *sql.DB) ([]models.CalculationRequest, error) {
-    var calculations []models.CalculationRequest // Слайс для хранения результатов.
+// UpdateCalculationStatusToWork обновляет статус незавершенного вычисления на 'work' и устанавливает start_time.
+func UpdateCalculationStatusToWork(db *sql.DB, id int) error {
+    // SQL-запрос для обновления статуса и времени начала.
+    query := `
+        UPDATE calculations
+        SET status = 'work', start_time = $2
+        WHERE id = $1 AND status IN ('created', 'work')
+    `
 
-    query := `SELECT id, userId, operation, add_duration, subtract_duration, multiply_duration, divide_duration FROM calculations WHERE status = 'created' LIMIT 5`
-    rows, err := db.Query(query) // Выполнение запроса.
-	// Возврат ошибки в случае ее возникновения.
+    _, err := db.Exec(query, id, time.Now().UTC())
     if err != nil {
-        return nil, err
-    }
-    defer rows.Close() // Закрытие результата запроса при выходе из функции.
-
-    for rows.Next() { // Перебор всех полученных записей.
-        var calc models.CalculationRequest
-        if err := rows.Scan(&calc.ID, &calc.UserId, &calc.Operation, &calc.AddDuration, &calc.SubtractDuration, &calc.MultiplyDuration, &calc.DivideDuration); err != nil {
-            return nil, err // Возврат ошибки при возникновении.
-        }
-        calculations = append(calculations, calc) // Добавление записи в слайс.
-    }
-
-    if err = rows.Err(); err != nil {
-        return nil, err // Возврат ошибки при возникновении.
+        return fmt.Errorf("error updating calculation status to work and setting start time: %w", err)
     }
 
-    return calculations, nil// Возвращение слайса с результатами и nil в случае успешного выполнения функции.
+    fmt.Printf("Calculation record with ID %d status updated to work and start time set.\n", id)
+    return nil
 }
 
 // GetCalculationResultByID извлекает результат вычисления по его ID.
@@ -351,23 +311,41 @@ func GetCalculationResultByID(db *sql.DB, id int) (*models.CalculationResponse,
         result sql.NullFloat64 // Использование sql.NullFloat64 для обработки NULL значений.
         status string
         userId int
+        errorMessage sql.NullString // Сообщение об ошибке, заполнено для статусов 'error' и 'failed' и после неудачных попыток.
+        attempts int // Число неудачных попыток вычисления.
+        variables sql.NullString // Переменные выражения в формате JSON, NULL если их нет.
+        exact sql.NullString // Точный результат, NULL в режиме float64.
+        scale sql.NullInt64 // Масштаб точного режима, NULL в режиме float64.
+        rounding sql.NullString // Режим округления точного режима.
+        mode sql.NullString // Режим точного вычисления, NULL для десятичного.
     )
-    query := `SELECT operation, result, status, userId FROM calculations WHERE id = $1` // SQL-запрос для выборки.
-    err := db.QueryRow(query, id).Scan(&operation, &result, &status, &userId) // Выполнение запроса и считывание результатов.
+    query := `SELECT operation, result, status, userId, error_message, attempts, variables, exact_result, precision_scale, rounding_mode, precision_mode FROM calculations WHERE id = $1` // SQL-запрос для выборки.
+    err := db.QueryRow(query, id).Scan(&operation, &result, &status, &userId, &errorMessage, &attempts, &variables, &exact, &scale, &rounding, &mode) // Выполнение запроса и считывание результатов.
     if err != nil {
         return nil, err // Возврат ошибки при возникновении.
     }
+    values, err := decodeMap[float64](variables)
+    if err != nil {
+        return nil, fmt.Errorf("calculation %d: %w", id, err)
+    }
 
     calcResult := &models.CalculationResponse{
         ID:     id,
         Operation: operation,
         UserId: userId,
         Status: status,
+        Attempts: attempts,
+        Variables: values,
+        ExactResult: exact.String,
+        Precision: decodePrecision(scale, rounding, mode),
     }
 
     if result.Valid {
         calcResult.Result = result.Float64 // Присвоение результата, если он не NULL.
     }
+    if errorMessage.Valid {
+        calcResult.Error = errorMessage.String
+    }
 
     return calcResult, nil // Возвращение ответа и nil в случае успешного выполнения функции.
 }
@@ -411,7 +389,7 @@ func FetchAllCalculations(db *sql.DB) ([]models.OperationResponse, error) {
 func FetchCalculationsByUser(db *sql.DB, userId int) ([]models.OperationResponse, error) {
     var calculations []models.OperationResponse
 
-    query := `SELECT id, userId, operation, result, status FROM calculations WHERE userId = $1`
+    query := `SELECT id, userId, operation, result, status, variables, exact_result FROM calculations WHERE userId = $1`
     rows, err := db.Query(query, userId) // Выполнение запроса с фильтрацией по userId.
     if err != nil {
         return nil, fmt.Errorf("querying calculations for user %d: %w", userId, err)
@@ -421,14 +399,20 @@ func FetchCalculationsByUser(db *sql.DB, userId int) ([]models.OperationResponse
     for rows.Next() {
         var calc models.OperationResponse
         var result sql.NullFloat64 // Для обработки NULL значений.
+        var variables sql.NullString // Переменные выражения в формате JSON.
+        var exact sql.NullString // Точный результат в точном режиме.
 
-        if err := rows.Scan(&calc.ID, &calc.UserId, &calc.Operation, &result, &calc.Status); err != nil {
+        if err := rows.Scan(&calc.ID, &calc.UserId, &calc.Operation, &result, &calc.Status, &variables, &exact); err != nil {
             return nil, fmt.Errorf("scanning calculation: %w", err)
         }
+        if calc.Variables, err = decodeMap[float64](variables); err != nil {
+            return nil, fmt.Errorf("calculation %d: %w", calc.ID, err)
+        }
 
         if result.Valid {
             calc.Result = result.Float64
         }
+        calc.ExactResult = exact.String
 
         calculations = append(calculations, calc)
     }
@@ -452,29 +436,14 @@ func ClearAllCalculations(db *sql.DB) error {
     return nil // Возвращение nil в случае успешного выполнения функции.
 }
 
-// CreateUserTableIfNotExists проверяет наличие в базе данных таблицы users и создает таковую при ее отсутствии
-func CreateUserTableIfNotExists(db *sql.DB) error {
-    var tableExists bool
-    err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'users')").Scan(&tableExists)
+// ClearCalculationsByUser удаляет все вычисления пользователя.
+func ClearCalculationsByUser(db *sql.DB, userId int) error {
+    query := `DELETE FROM calculations WHERE userId = $1` // Подзадачи удаляются каскадно.
+    _, err := db.Exec(query, userId)
     if err != nil {
-        return err
-    }
-
-    if !tableExists {
-        query := `
-        CREATE TABLE users (
-            id SERIAL PRIMARY KEY,
-            login TEXT UNIQUE NOT NULL,
-            password TEXT NOT NULL
-        )`
-        _, err = db.Exec(query)
-        if err != nil {
-            return err
-        }
-        fmt.Println("Table 'users' created successfully.")
-    } else {
-        fmt.Println("Table 'users' already exists.")
+        return fmt.Errorf("clearing calculations of user %d: %w", userId, err)
     }
+    fmt.Printf("All calculations of user %d cleared successfully.\n", userId)
     return nil
 }
 
@@ -504,7 +473,7 @@ func GetUserByLogin(db *sql.DB, login string) (*models.User, error) {
     err := row.Scan(&user.ID, &user.Login, &user.Password)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("user not found")
+            return nil, ErrUserNotFound
         }
         return nil, err
     }
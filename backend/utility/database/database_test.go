@@ -24,4 +24,75 @@ func TestInitializeDB(t *testing.T) {
     if err := mock.ExpectationsWereMet(); err != nil {
         t.Errorf("there were unfulfilled expectations: %s", err)
     }
-}
\ No newline at end of file
+}
+func TestUpdateCalculationError(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    mock.ExpectExec("UPDATE calculations SET result = NULL, status = 'error'").
+        WithArgs("division by zero", sqlmock.AnyArg(), 7).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+
+    if err := UpdateCalculationError(db, 7, "division by zero"); err != nil {
+        t.Errorf("UpdateCalculationError returned error: %s", err)
+    }
+
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("there were unfulfilled expectations: %s", err)
+    }
+}
+
+func TestCompleteTaskReadiesParent(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    mock.ExpectBegin()
+    mock.ExpectQuery("UPDATE tasks SET status = 'completed'").
+        WithArgs(6.0, sqlmock.AnyArg(), 2).
+        WillReturnRows(sqlmock.NewRows([]string{"calculation_id", "parent_id", "parent_position"}).AddRow(1, 3, 1))
+    mock.ExpectQuery("SELECT operands FROM tasks WHERE id = (.+) FOR UPDATE").
+        WithArgs(int64(3)).
+        WillReturnRows(sqlmock.NewRows([]string{"operands"}).AddRow(`[{"value":"20"},{"taskId":2}]`))
+    // Второй операнд родителя получил значение, поэтому родитель готов к отправке
+    mock.ExpectExec("UPDATE tasks SET operands").
+        WithArgs(`[{"value":"20"},{"value":"6"}]`, "ready", int64(3)).
+        WillReturnResult(sqlmock.NewResult(0, 1))
+    mock.ExpectCommit()
+
+    if err := CompleteTask(db, 2, 6, ""); err != nil {
+        t.Errorf("CompleteTask returned error: %s", err)
+    }
+
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("there were unfulfilled expectations: %s", err)
+    }
+}
+
+func TestUpdateTaskStatusToWorkLeaseLost(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    // Аренда задачи истекла и перешла к другому экземпляру оркестратора
+    mock.ExpectBegin()
+    mock.ExpectQuery("UPDATE tasks SET status = 'work'").
+        WithArgs(4, "orchestrator-a", sqlmock.AnyArg(), sqlmock.AnyArg()).
+        WillReturnRows(sqlmock.NewRows([]string{"calculation_id"}))
+    mock.ExpectRollback()
+
+    if err := UpdateTaskStatusToWork(db, 4, "orchestrator-a", "calculator1"); err != ErrTaskNotReady {
+        t.Errorf("expected ErrTaskNotReady, got %v", err)
+    }
+
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("there were unfulfilled expectations: %s", err)
+    }
+}
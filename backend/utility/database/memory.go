@@ -0,0 +1,647 @@
+package database
+
+import (
+	"database/sql" // Для ошибки sql.ErrNoRows, общей для всех хранилищ
+	"fmt"          // Форматированный вывод
+	"maps"         // Для копирования длительностей функций
+	"sort"         // Для упорядочивания записей по идентификатору
+	"sync"         // Синхронизация доступа к данным
+	"time"         // Работа со временем
+
+	"calculatorapi/utility/calculation" // Граф задач выражения
+	"calculatorapi/utility/models"      // Структуры данных для калькулятора
+
+	"golang.org/x/crypto/bcrypt" // Для хэширования паролей
+)
+
+// MemoryStore хранит вычисления, подзадачи и пользователей в памяти процесса.
+// Данные теряются при остановке оркестратора; хранилище предназначено для разработки и тестов.
+type MemoryStore struct {
+	mu                sync.Mutex
+	calculations      map[int]*memoryCalculation
+	tasks             map[int]*memoryTask
+	users             map[string]models.User
+	nextCalculationID int
+	nextTaskID        int
+	nextUserID        int
+}
+
+// memoryCalculation - запись о вычислении в памяти.
+type memoryCalculation struct {
+	request      models.CalculationRequest // Выражение, пользователь и длительности операций
+	status       string
+	result       float64
+	exact        string // Точный результат в точном режиме
+	hasResult    bool
+	errorMessage string
+	createdTime  time.Time
+	startTime    time.Time
+	endTime      time.Time
+	attempts     int       // Число неудачных попыток
+	retryAfter   time.Time // Время, до которого подзадачи вычисления не отправляются
+	taskIDs      []int     // Идентификаторы подзадач вычисления
+}
+
+// memoryTask - запись о подзадаче в памяти.
+type memoryTask struct {
+	task           models.Task
+	createdTime    time.Time
+	startTime      time.Time
+	endTime        time.Time
+	leaseOwner     string
+	leaseExpiresAt time.Time
+	agent          string // Калькулятор, выполняющий подзадачу
+}
+
+// NewMemoryStore создает пустое хранилище в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		calculations: make(map[int]*memoryCalculation),
+		tasks:        make(map[int]*memoryTask),
+		users:        make(map[string]models.User),
+	}
+}
+
+func (s *MemoryStore) InsertCalculation(userId int, operation string, addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration, inactiveServerTime int, functionDurations map[string]int, variables map[string]float64, precision *calculation.Precision) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextCalculationID++
+	id := s.nextCalculationID
+	s.calculations[id] = &memoryCalculation{
+		request: models.CalculationRequest{
+			ID:                 id,
+			UserId:             userId,
+			Operation:          operation,
+			AddDuration:        addDuration,
+			SubtractDuration:   subtractDuration,
+			MultiplyDuration:   multiplyDuration,
+			DivideDuration:     divideDuration,
+			PowerDuration:      powerDuration,
+			ModuloDuration:     moduloDuration,
+			IntDivideDuration:  intDivideDuration,
+			InactiveServerTime: inactiveServerTime,
+			FunctionDurations:  maps.Clone(functionDurations),
+			Variables:          maps.Clone(variables),
+			Precision:          clonePrecision(precision),
+		},
+		status:      "created",
+		createdTime: time.Now().UTC(),
+	}
+	return id, nil
+}
+
+func (s *MemoryStore) UpdateCalculation(id int, result float64, exact string, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if calc, ok := s.calculations[id]; ok && (calc.status == "created" || calc.status == "work") {
+		calc.result, calc.exact, calc.hasResult = result, exact, true
+		calc.status = status
+		calc.endTime = time.Now().UTC()
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateCalculationError(id int, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if calc, ok := s.calculations[id]; ok && (calc.status == "created" || calc.status == "work") {
+		s.failCalculation(calc, message, time.Now().UTC())
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateCalculationStatusToWork(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if calc, ok := s.calculations[id]; ok && (calc.status == "created" || calc.status == "work") {
+		calc.status = "work"
+		calc.startTime = time.Now().UTC()
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetCalculationResultByID(id int) (*models.CalculationResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calc, ok := s.calculations[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	response := &models.CalculationResponse{
+		ID:        id,
+		Operation: calc.request.Operation,
+		UserId:    calc.request.UserId,
+		Status:    calc.status,
+		Error:     calc.errorMessage,
+		Attempts:  calc.attempts,
+		Variables: calc.request.Variables,
+		Precision: clonePrecision(calc.request.Precision),
+	}
+	if calc.hasResult {
+		response.Result, response.ExactResult = calc.result, calc.exact
+	}
+	return response, nil
+}
+
+func (s *MemoryStore) FetchCalculationsByUser(userId int) ([]models.OperationResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calculations []models.OperationResponse
+	for _, id := range sortedKeys(s.calculations) {
+		calc := s.calculations[id]
+		if calc.request.UserId != userId {
+			continue
+		}
+		operation := models.OperationResponse{ID: id, UserId: userId, Operation: calc.request.Operation, Status: calc.status, Variables: calc.request.Variables}
+		if calc.hasResult {
+			operation.Result, operation.ExactResult = calc.result, calc.exact
+		}
+		calculations = append(calculations, operation)
+	}
+	return calculations, nil
+}
+
+func (s *MemoryStore) ClearCalculationsByUser(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, calc := range s.calculations {
+		if calc.request.UserId != userId {
+			continue
+		}
+		for _, taskID := range calc.taskIDs {
+			delete(s.tasks, taskID)
+		}
+		delete(s.calculations, id)
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateCalculationTasks(calculationID int, plan *calculation.Plan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calc, ok := s.calculations[calculationID]
+	if !ok {
+		return fmt.Errorf("calculation %d not found", calculationID)
+	}
+
+	createdTime := time.Now().UTC()
+	ids := make([]int, len(plan.Tasks)) // Идентификаторы подзадач по индексам задач плана
+	for i, task := range plan.Tasks {
+		operands := make([]models.TaskOperand, len(task.Operands))
+		status := "ready"
+		for j, operand := range task.Operands {
+			if operand.Task >= 0 {
+				operands[j] = models.TaskOperand{TaskID: ids[operand.Task]}
+				status = "waiting"
+			} else {
+				operands[j] = models.TaskOperand{Value: operand.Value}
+			}
+		}
+
+		s.nextTaskID++
+		ids[i] = s.nextTaskID
+		s.tasks[ids[i]] = &memoryTask{
+			task: models.Task{
+				ID:            ids[i],
+				CalculationID: calculationID,
+				Operator:      task.Op,
+				Operands:      operands,
+				Status:        status,
+			},
+			createdTime: createdTime,
+		}
+		calc.taskIDs = append(calc.taskIDs, ids[i])
+	}
+
+	// Связывание задач с родителями, которые получат их результаты
+	for i, task := range plan.Tasks {
+		if task.Parent >= 0 {
+			s.tasks[ids[i]].task.ParentID = ids[task.Parent]
+			s.tasks[ids[i]].task.Position = task.Position
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) FetchUnplannedCalculations() ([]models.CalculationRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calculations []models.CalculationRequest
+	for _, id := range sortedKeys(s.calculations) {
+		calc := s.calculations[id]
+		if calc.status == "created" && len(calc.taskIDs) == 0 {
+			calculations = append(calculations, models.CalculationRequest{ID: id, UserId: calc.request.UserId, Operation: calc.request.Operation, Variables: calc.request.Variables, Precision: clonePrecision(calc.request.Precision)})
+		}
+	}
+	return calculations, nil
+}
+
+func (s *MemoryStore) ClaimTasks(owner string, limit int, lease time.Duration) ([]models.CalculationRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	var (
+		tasks   []models.CalculationRequest
+		claimed []*memoryTask
+	)
+	for _, id := range sortedKeys(s.tasks) {
+		if len(tasks) >= limit {
+			break
+		}
+		entry := s.tasks[id]
+		leaseExpired := entry.task.Status == "dispatched" && entry.leaseExpiresAt.Before(now)
+		if entry.task.Status != "ready" && !leaseExpired {
+			continue
+		}
+		calc := s.calculations[entry.task.CalculationID]
+		if (calc.status != "created" && calc.status != "work") || calc.retryAfter.After(now) {
+			continue
+		}
+
+		values, err := knownValues(entry.task.Operands)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", id, err)
+		}
+		task := calc.request
+		task.TaskID = id
+		task.Precision = clonePrecision(calc.request.Precision)
+		task.Operation = calculation.TaskExpression(entry.task.Operator, values)
+		task.InactiveServerTime = 0
+		tasks = append(tasks, task)
+		claimed = append(claimed, entry)
+	}
+
+	// Аренда выдается только если все выбранные подзадачи удалось подготовить, как при откате транзакции
+	for _, entry := range claimed {
+		entry.task.Status = "dispatched"
+		entry.leaseOwner = owner
+		entry.leaseExpiresAt = now.Add(lease)
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) ClaimTask(owner, agent string, lease time.Duration) (*models.CalculationRequest, error) {
+	tasks, err := s.ClaimTasks(owner, 1, lease)
+	if err != nil || len(tasks) == 0 {
+		return nil, err
+	}
+
+	task := tasks[0]
+	if err := s.UpdateTaskStatusToWork(task.TaskID, owner, agent); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (s *MemoryStore) UpdateTaskStatusToWork(taskID int, owner, agent string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.tasks[taskID]
+	if !ok || entry.task.Status != "dispatched" || entry.leaseOwner != owner {
+		return ErrTaskNotReady
+	}
+	now := time.Now().UTC()
+	entry.task.Status = "work"
+	entry.startTime = now
+	entry.agent = agent
+
+	if calc := s.calculations[entry.task.CalculationID]; calc.status == "created" {
+		calc.status = "work"
+		calc.startTime = now
+	}
+	return nil
+}
+
+func (s *MemoryStore) ReleaseTask(taskID int, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.tasks[taskID]; ok && entry.task.Status == "dispatched" && entry.leaseOwner == owner {
+		entry.task.Status = "ready"
+		entry.leaseOwner = ""
+		entry.leaseExpiresAt = time.Time{}
+	}
+	return nil
+}
+
+func (s *MemoryStore) RetryTask(taskID int, message string, policy RetryPolicy) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.tasks[taskID]
+	if !ok || entry.task.Status != "work" {
+		return false, fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+	resetTask(entry)
+
+	calc := s.calculations[entry.task.CalculationID]
+	if calc.status != "created" && calc.status != "work" {
+		// Вычисление уже закончилось, попытка не засчитывается
+		return false, nil
+	}
+	calc.attempts++
+	calc.errorMessage = message
+
+	now := time.Now().UTC()
+	if calc.attempts < policy.MaxAttempts {
+		calc.retryAfter = now.Add(policy.Delay(calc.attempts))
+		return false, nil
+	}
+
+	calc.status = "failed"
+	calc.retryAfter = time.Time{}
+	calc.endTime = now
+	for _, id := range calc.taskIDs {
+		switch task := s.tasks[id]; task.task.Status {
+		case "dispatched", "work":
+			resetTask(task)
+		}
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) CompleteTask(taskID int, result float64, exact string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+	switch entry.task.Status {
+	case "ready", "dispatched", "work":
+	default:
+		return fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+
+	endTime := time.Now().UTC()
+	if entry.task.ParentID == 0 {
+		// Корневая задача: результат относится ко всему вычислению
+		entry.task.Status, entry.task.Result, entry.endTime = "completed", result, endTime
+		if calc := s.calculations[entry.task.CalculationID]; calc.status == "created" || calc.status == "work" {
+			calc.status = "completed"
+			calc.result, calc.exact, calc.hasResult = result, exact, true
+			calc.endTime = endTime
+		}
+		return nil
+	}
+
+	// Подстановка результата в операнды родительской задачи
+	parent, ok := s.tasks[entry.task.ParentID]
+	if !ok {
+		return fmt.Errorf("loading parent task %d: %w", entry.task.ParentID, sql.ErrNoRows)
+	}
+	if entry.task.Position >= len(parent.task.Operands) {
+		return fmt.Errorf("task %d refers to missing operand %d of task %d", taskID, entry.task.Position, parent.task.ID)
+	}
+	entry.task.Status, entry.task.Result, entry.endTime = "completed", result, endTime
+	if parent.task.Status != "waiting" {
+		return nil
+	}
+
+	parent.task.Operands[entry.task.Position] = models.TaskOperand{Value: operandValue(result, exact)}
+	parent.task.Status = "ready"
+	for _, operand := range parent.task.Operands {
+		if operand.TaskID != 0 {
+			parent.task.Status = "waiting"
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) FailTask(taskID int, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+	switch entry.task.Status {
+	case "ready", "dispatched", "work":
+	default:
+		return fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+	calc := s.calculations[entry.task.CalculationID]
+	if calc.status != "created" && calc.status != "work" {
+		return fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+	endTime := time.Now().UTC()
+	entry.task.Status, entry.task.Error, entry.endTime = "error", message, endTime
+
+	// Оставшиеся невыполненные задачи вычисления отменяются
+	for _, id := range calc.taskIDs {
+		switch task := s.tasks[id]; task.task.Status {
+		case "waiting", "ready", "dispatched":
+			task.task.Status = "cancelled"
+		}
+	}
+	s.failCalculation(calc, message, endTime)
+	return nil
+}
+
+func (s *MemoryStore) CancelCalculation(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calc, ok := s.calculations[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if calc.status != "created" && calc.status != "work" {
+		return fmt.Errorf("calculation %d is %s: %w", id, calc.status, ErrCalculationFinished)
+	}
+	calc.status = "cancelled"
+	calc.endTime = time.Now().UTC()
+
+	for _, taskID := range calc.taskIDs {
+		switch entry := s.tasks[taskID]; entry.task.Status {
+		case "waiting", "ready", "dispatched", "work":
+			entry.task.Status = "cancelled"
+			entry.leaseOwner = ""
+			entry.leaseExpiresAt = time.Time{}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) FetchTasksByCalculation(calculationID int) ([]models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := []models.Task{}
+	calc, ok := s.calculations[calculationID]
+	if !ok {
+		return tasks, nil
+	}
+	for _, id := range calc.taskIDs {
+		task := s.tasks[id].task
+		task.Operands = append([]models.TaskOperand(nil), task.Operands...) // Копия, чтобы вызывающий не изменил хранилище
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) GetTaskByID(taskID int) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.tasks[taskID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	task := entry.task
+	task.Operands = append([]models.TaskOperand(nil), task.Operands...)
+	return &task, nil
+}
+
+func (s *MemoryStore) FetchWorkingTasks() ([]models.WorkingTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tasks []models.WorkingTask
+	for _, id := range sortedKeys(s.tasks) {
+		entry := s.tasks[id]
+		calc := s.calculations[entry.task.CalculationID]
+		if entry.task.Status != "work" || (calc.status != "created" && calc.status != "work") {
+			continue
+		}
+		tasks = append(tasks, models.WorkingTask{
+			ID:               id,
+			CalculationID:    calc.request.ID,
+			UserId:           calc.request.UserId,
+			Operator:         entry.task.Operator,
+			StartTime:          entry.startTime,
+			Agent:              entry.agent,
+			AddDuration:        calc.request.AddDuration,
+			SubtractDuration:   calc.request.SubtractDuration,
+			MultiplyDuration:   calc.request.MultiplyDuration,
+			DivideDuration:     calc.request.DivideDuration,
+			PowerDuration:      calc.request.PowerDuration,
+			ModuloDuration:     calc.request.ModuloDuration,
+			IntDivideDuration:  calc.request.IntDivideDuration,
+			FunctionDurations:  calc.request.FunctionDurations,
+			InactiveServerTime: calc.request.InactiveServerTime,
+		})
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) FetchFailedCalculations() ([]models.FailedCalculation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calculations := []models.FailedCalculation{}
+	for _, id := range sortedKeys(s.calculations) {
+		calc := s.calculations[id]
+		if calc.status != "failed" {
+			continue
+		}
+		calculations = append(calculations, models.FailedCalculation{
+			ID:        id,
+			UserId:    calc.request.UserId,
+			Operation: calc.request.Operation,
+			Attempts:  calc.attempts,
+			Error:     calc.errorMessage,
+			FailedAt:  calc.endTime,
+		})
+	}
+	return calculations, nil
+}
+
+func (s *MemoryStore) RequeueCalculation(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calc, ok := s.calculations[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if calc.status != "failed" {
+		return fmt.Errorf("calculation %d is %s: %w", id, calc.status, ErrCalculationNotFailed)
+	}
+	calc.status = "created"
+	calc.attempts = 0
+	calc.retryAfter = time.Time{}
+	calc.errorMessage = ""
+	calc.endTime = time.Time{}
+	return nil
+}
+
+func (s *MemoryStore) RegisterUser(login, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[login]; exists {
+		return fmt.Errorf("user %q already exists", login)
+	}
+	s.nextUserID++
+	s.users[login] = models.User{ID: s.nextUserID, Login: login, Password: string(hashedPassword)}
+	return nil
+}
+
+func (s *MemoryStore) GetUserByLogin(login string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[login]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// failCalculation переводит вычисление в статус 'error'. Вызывается под s.mu.
+func (s *MemoryStore) failCalculation(calc *memoryCalculation, message string, endTime time.Time) {
+	calc.status = "error"
+	calc.hasResult, calc.exact = false, ""
+	calc.errorMessage = message
+	calc.endTime = endTime
+}
+
+// resetTask возвращает подзадачу в очередь. Вызывается под s.mu.
+func resetTask(entry *memoryTask) {
+	entry.task.Status = "ready"
+	entry.startTime = time.Time{}
+	entry.leaseOwner = ""
+	entry.leaseExpiresAt = time.Time{}
+}
+
+// sortedKeys возвращает идентификаторы записей по возрастанию, как ORDER BY id в SQL хранилищах.
+func sortedKeys[V any](records map[int]V) []int {
+	keys := make([]int, 0, len(records))
+	for id := range records {
+		keys = append(keys, id)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// clonePrecision копирует точность вычисления, чтобы результаты не разделяли ее с хранилищем.
+func clonePrecision(precision *calculation.Precision) *calculation.Precision {
+	if precision == nil {
+		return nil
+	}
+	clone := *precision
+	return &clone
+}
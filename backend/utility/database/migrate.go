@@ -0,0 +1,241 @@
+package database
+
+import (
+	"database/sql" // Для работы с SQL базами данных
+	"embed"        // Для встраивания файлов миграций в бинарный файл
+	"errors"       // Для объявления и проверки ошибок
+	"fmt"          // Форматированный вывод
+	"io/fs"        // Для чтения встроенных файлов
+	"sort"         // Для упорядочивания миграций
+	"strconv"      // Для разбора номера версии
+	"strings"      // Для разбора имен файлов
+	"time"         // Работа со временем
+)
+
+// Файлы миграций: NNNN_описание.up.sql применяет изменение схемы, NNNN_описание.down.sql отменяет его.
+// Для каждого диалекта свой каталог с одинаковыми номерами версий.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaAhead возвращается, если база данных мигрирована более новой версией программы.
+var ErrSchemaAhead = errors.New("database schema is newer than this binary")
+
+// Ключ advisory-блокировки, не дающей нескольким оркестраторам мигрировать базу одновременно
+const migrationLockID = 4207318
+
+// Migration - одна версия схемы базы данных.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// LoadMigrations читает встроенные миграции PostgreSQL и проверяет, что версии идут подряд с 1 и у каждой есть up и down.
+func LoadMigrations() ([]Migration, error) {
+	return loadMigrations(postgresDialect)
+}
+
+// loadMigrations читает встроенные миграции диалекта d.
+func loadMigrations(d dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, d.migrations)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		base, direction, ok := cutDirection(fileName)
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", fileName)
+		}
+		versionText, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name prefix", fileName)
+		}
+		version, err := strconv.Atoi(versionText)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", fileName, err)
+		}
+
+		content, err := migrationFiles.ReadFile(d.migrations + "/" + fileName)
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", fileName, err)
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be consecutive from 1, found %d at position %d", migration.Version, i+1)
+		}
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// cutDirection отделяет от имени файла суффикс .up.sql или .down.sql.
+func cutDirection(fileName string) (string, string, bool) {
+	if base, ok := strings.CutSuffix(fileName, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok := strings.CutSuffix(fileName, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
+
+// SchemaVersion возвращает номер последней примененной миграции PostgreSQL и последнюю версию, известную программе.
+func SchemaVersion(db *sql.DB) (int, int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockSchema(tx, postgresDialect)
+	if err != nil {
+		return 0, 0, err
+	}
+	return current, latestVersion(migrations), tx.Commit()
+}
+
+// MigrateUp применяет все еще не примененные миграции PostgreSQL в одной транзакции.
+// Возвращает ErrSchemaAhead, если база данных мигрирована более новой версией программы.
+func MigrateUp(db *sql.DB) error {
+	return migrateUp(db, postgresDialect)
+}
+
+// migrateUp применяет миграции диалекта d.
+func migrateUp(db *sql.DB, d dialect) error {
+	migrations, err := loadMigrations(d)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockSchema(tx, d)
+	if err != nil {
+		return err
+	}
+	if latest := latestVersion(migrations); current > latest {
+		return fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrSchemaAhead, current, latest)
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		if _, err := tx.Exec(migration.Up); err != nil {
+			return fmt.Errorf("applying migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		query := `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(query, migration.Version, migration.Name, time.Now().UTC()); err != nil {
+			return fmt.Errorf("recording migration %d: %w", migration.Version, err)
+		}
+		fmt.Printf("Applied migration %d_%s\n", migration.Version, migration.Name)
+	}
+
+	return tx.Commit()
+}
+
+// MigrateDown отменяет steps последних примененных миграций PostgreSQL в одной транзакции.
+func MigrateDown(db *sql.DB, steps int) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockSchema(tx, postgresDialect)
+	if err != nil {
+		return err
+	}
+	if latest := latestVersion(migrations); current > latest {
+		// Отменить миграции, о которых программа не знает, невозможно
+		return fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrSchemaAhead, current, latest)
+	}
+
+	for version := current; version > current-steps && version > 0; version-- {
+		migration := migrations[version-1]
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version); err != nil {
+			return fmt.Errorf("removing migration record %d: %w", migration.Version, err)
+		}
+		fmt.Printf("Reverted migration %d_%s\n", migration.Version, migration.Name)
+	}
+
+	return tx.Commit()
+}
+
+// lockSchema берет блокировку миграций до конца транзакции, создает таблицу schema_migrations
+// при ее отсутствии и возвращает номер последней примененной миграции.
+func lockSchema(tx *sql.Tx, d dialect) (int, error) {
+	if d.lockSchema != "" {
+		if _, err := tx.Exec(d.lockSchema, migrationLockID); err != nil {
+			return 0, fmt.Errorf("locking schema migrations: %w", err)
+		}
+	}
+
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`
+	if _, err := tx.Exec(query); err != nil {
+		return 0, fmt.Errorf("creating schema_migrations table: %w", err)
+	}
+
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return current, nil
+}
+
+// latestVersion возвращает номер последней миграции, известной программе.
+func latestVersion(migrations []Migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
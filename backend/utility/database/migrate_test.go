@@ -0,0 +1,82 @@
+package database
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLoadMigrations(t *testing.T) {
+    migrations, err := LoadMigrations()
+    if err != nil {
+        t.Fatalf("LoadMigrations returned error: %v", err)
+    }
+    if len(migrations) < 5 {
+        t.Fatalf("Expected at least 5 migrations, got %d", len(migrations))
+    }
+    for i, migration := range migrations {
+        if migration.Version != i+1 {
+            t.Errorf("Expected version %d at position %d, got %d", i+1, i, migration.Version)
+        }
+        if migration.Up == "" || migration.Down == "" {
+            t.Errorf("Migration %d_%s must have up and down SQL", migration.Version, migration.Name)
+        }
+    }
+}
+
+func TestMigrateUpAppliesPending(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    migrations, err := LoadMigrations()
+    if err != nil {
+        t.Fatal(err)
+    }
+    applied := len(migrations) - 2
+
+    mock.ExpectBegin()
+    mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+    mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+    mock.ExpectQuery("SELECT COALESCE\\(MAX\\(version\\), 0\\) FROM schema_migrations").
+        WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(applied))
+    // Применяются только две последние миграции, каждая с записью в schema_migrations
+    for _, migration := range migrations[applied:] {
+        mock.ExpectExec(".+").WillReturnResult(sqlmock.NewResult(0, 0))
+        mock.ExpectExec("INSERT INTO schema_migrations").
+            WithArgs(migration.Version, migration.Name, sqlmock.AnyArg()).
+            WillReturnResult(sqlmock.NewResult(0, 1))
+    }
+    mock.ExpectCommit()
+
+    if err := MigrateUp(db); err != nil {
+        t.Errorf("MigrateUp returned error: %v", err)
+    }
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("there were unfulfilled expectations: %s", err)
+    }
+}
+
+func TestMigrateUpRefusesNewerSchema(t *testing.T) {
+    db, mock, err := sqlmock.New()
+    if err != nil {
+        t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+    }
+    defer db.Close()
+
+    mock.ExpectBegin()
+    mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+    mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+    mock.ExpectQuery("SELECT COALESCE").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(999))
+    mock.ExpectRollback()
+
+    if err := MigrateUp(db); !errors.Is(err, ErrSchemaAhead) {
+        t.Errorf("Expected ErrSchemaAhead, got %v", err)
+    }
+    if err := mock.ExpectationsWereMet(); err != nil {
+        t.Errorf("there were unfulfilled expectations: %s", err)
+    }
+}
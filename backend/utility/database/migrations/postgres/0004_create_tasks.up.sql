@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS tasks (
+    id SERIAL PRIMARY KEY,
+    calculation_id INTEGER NOT NULL REFERENCES calculations(id) ON DELETE CASCADE,
+    parent_id INTEGER,
+    parent_position INTEGER,
+    operator TEXT NOT NULL,
+    operands TEXT NOT NULL,
+    status TEXT NOT NULL,
+    result DOUBLE PRECISION,
+    error_message TEXT,
+    created_time TIMESTAMP,
+    start_time TIMESTAMP,
+    end_time TIMESTAMP
+);
@@ -0,0 +1,3 @@
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS lease_owner TEXT,
+    ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP;
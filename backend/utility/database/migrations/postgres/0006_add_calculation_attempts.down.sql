@@ -0,0 +1,3 @@
+ALTER TABLE calculations
+    DROP COLUMN IF EXISTS attempts,
+    DROP COLUMN IF EXISTS retry_after;
@@ -0,0 +1,4 @@
+ALTER TABLE calculations
+    DROP COLUMN IF EXISTS power_duration,
+    DROP COLUMN IF EXISTS modulo_duration,
+    DROP COLUMN IF EXISTS int_divide_duration;
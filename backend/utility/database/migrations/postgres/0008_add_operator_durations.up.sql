@@ -0,0 +1,4 @@
+ALTER TABLE calculations
+    ADD COLUMN IF NOT EXISTS power_duration INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS modulo_duration INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS int_divide_duration INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE calculations ADD COLUMN IF NOT EXISTS function_durations TEXT;
@@ -0,0 +1,4 @@
+ALTER TABLE calculations
+    DROP COLUMN IF EXISTS precision_scale,
+    DROP COLUMN IF EXISTS rounding_mode,
+    DROP COLUMN IF EXISTS exact_result;
@@ -0,0 +1,4 @@
+ALTER TABLE calculations
+    ADD COLUMN IF NOT EXISTS precision_scale INTEGER,
+    ADD COLUMN IF NOT EXISTS rounding_mode TEXT,
+    ADD COLUMN IF NOT EXISTS exact_result NUMERIC;
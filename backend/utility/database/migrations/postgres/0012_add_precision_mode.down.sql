@@ -0,0 +1,4 @@
+UPDATE calculations SET exact_result = NULL WHERE exact_result LIKE '%/%';
+ALTER TABLE calculations
+    DROP COLUMN IF EXISTS precision_mode,
+    ALTER COLUMN exact_result TYPE NUMERIC USING exact_result::NUMERIC;
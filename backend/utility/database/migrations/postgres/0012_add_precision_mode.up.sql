@@ -0,0 +1,3 @@
+ALTER TABLE calculations
+    ADD COLUMN IF NOT EXISTS precision_mode TEXT,
+    ALTER COLUMN exact_result TYPE TEXT;
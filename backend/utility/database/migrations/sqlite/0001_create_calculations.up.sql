@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS calculations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    userId INTEGER NOT NULL,
+    operation TEXT,
+    result DOUBLE PRECISION,
+    status TEXT,
+    created_time TIMESTAMP,
+    start_time TIMESTAMP,
+    end_time TIMESTAMP,
+    operation_server TEXT,
+    server_status TEXT,
+    add_duration INTEGER,
+    subtract_duration INTEGER,
+    multiply_duration INTEGER,
+    divide_duration INTEGER,
+    inactive_server_time INTEGER
+);
@@ -0,0 +1 @@
+ALTER TABLE calculations ADD COLUMN error_message TEXT;
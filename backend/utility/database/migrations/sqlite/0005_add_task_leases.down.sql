@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN lease_owner;
+ALTER TABLE tasks DROP COLUMN lease_expires_at;
@@ -0,0 +1,2 @@
+ALTER TABLE calculations DROP COLUMN attempts;
+ALTER TABLE calculations DROP COLUMN retry_after;
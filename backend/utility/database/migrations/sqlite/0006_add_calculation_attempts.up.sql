@@ -0,0 +1,2 @@
+ALTER TABLE calculations ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE calculations ADD COLUMN retry_after TIMESTAMP;
@@ -0,0 +1,3 @@
+ALTER TABLE calculations DROP COLUMN power_duration;
+ALTER TABLE calculations DROP COLUMN modulo_duration;
+ALTER TABLE calculations DROP COLUMN int_divide_duration;
@@ -0,0 +1,3 @@
+ALTER TABLE calculations ADD COLUMN power_duration INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE calculations ADD COLUMN modulo_duration INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE calculations ADD COLUMN int_divide_duration INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE calculations ADD COLUMN function_durations TEXT;
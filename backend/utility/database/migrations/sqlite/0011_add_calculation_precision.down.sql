@@ -0,0 +1,3 @@
+ALTER TABLE calculations DROP COLUMN precision_scale;
+ALTER TABLE calculations DROP COLUMN rounding_mode;
+ALTER TABLE calculations DROP COLUMN exact_result;
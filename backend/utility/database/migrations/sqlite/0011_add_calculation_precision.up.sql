@@ -0,0 +1,3 @@
+ALTER TABLE calculations ADD COLUMN precision_scale INTEGER;
+ALTER TABLE calculations ADD COLUMN rounding_mode TEXT;
+ALTER TABLE calculations ADD COLUMN exact_result TEXT;
@@ -0,0 +1 @@
+ALTER TABLE calculations DROP COLUMN precision_mode;
@@ -0,0 +1 @@
+ALTER TABLE calculations ADD COLUMN precision_mode TEXT;
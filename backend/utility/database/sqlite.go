@@ -0,0 +1,34 @@
+package database
+
+import (
+	"database/sql" // Для работы с SQL базами данных
+	"fmt"          // Форматированный вывод
+
+	_ "modernc.org/sqlite" // Встроенный драйвер SQLite без cgo
+)
+
+// OpenSQLiteStore открывает (или создает) файл базы SQLite по пути path и применяет к нему миграции.
+// Путь ":memory:" создает базу в памяти процесса. Внешний сервер базы данных не нужен.
+func OpenSQLiteStore(path string) (*SQLStore, error) {
+	// Внешние ключи включают каскадное удаление подзадач; время записывается в сортируемом формате
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening SQLite database %s: %w", path, err)
+	}
+
+	// Одно соединение: транзакции выполняются по очереди, а база ":memory:" не теряется при переподключении
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to SQLite database %s: %w", path, err)
+	}
+
+	if err := migrateUp(db, sqliteDialect); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	fmt.Printf("SQLite database %s is ready\n", path)
+	return &SQLStore{db: db, dialect: sqliteDialect}, nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"database/sql" // Для работы с SQL базами данных
+	"time"         // Работа со временем
+
+	"calculatorapi/utility/calculation" // Граф задач выражения
+	"calculatorapi/utility/models"      // Структуры данных для калькулятора
+)
+
+// Store - хранилище вычислений, их подзадач и пользователей, с которым работает оркестратор.
+// Реализации: PostgreSQL и встроенный SQLite (SQLStore) и хранилище в памяти (MemoryStore).
+type Store interface {
+	// InsertCalculation сохраняет новое вычисление в статусе 'created' и возвращает его идентификатор.
+	InsertCalculation(userId int, operation string, addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration, inactiveServerTime int, functionDurations map[string]int, variables map[string]float64, precision *calculation.Precision) (int, error)
+	// UpdateCalculation сохраняет результат и статус вычисления; exact - точный результат в точном режиме или "".
+	// Как и два следующих метода, изменяет только вычисления в статусах 'created' и 'work'.
+	UpdateCalculation(id int, result float64, exact string, status string) error
+	// UpdateCalculationError переводит вычисление в статус 'error' с сообщением об ошибке.
+	UpdateCalculationError(id int, message string) error
+	// UpdateCalculationStatusToWork переводит вычисление в статус 'work'.
+	UpdateCalculationStatusToWork(id int) error
+	// GetCalculationResultByID возвращает вычисление или sql.ErrNoRows, если его нет.
+	GetCalculationResultByID(id int) (*models.CalculationResponse, error)
+	// FetchCalculationsByUser возвращает все вычисления пользователя.
+	FetchCalculationsByUser(userId int) ([]models.OperationResponse, error)
+	// ClearCalculationsByUser удаляет все вычисления пользователя вместе с их подзадачами.
+	ClearCalculationsByUser(userId int) error
+
+	// CreateCalculationTasks сохраняет граф задач вычисления.
+	CreateCalculationTasks(calculationID int, plan *calculation.Plan) error
+	// FetchUnplannedCalculations возвращает вычисления в статусе 'created' без подзадач.
+	FetchUnplannedCalculations() ([]models.CalculationRequest, error)
+	// ClaimTasks арендует не более limit готовых подзадач для оркестратора owner.
+	ClaimTasks(owner string, limit int, lease time.Duration) ([]models.CalculationRequest, error)
+	// ClaimTask арендует одну подзадачу и сразу переводит ее в статус 'work', запоминая забравший ее калькулятор agent;
+	// nil, если готовых подзадач нет.
+	ClaimTask(owner, agent string, lease time.Duration) (*models.CalculationRequest, error)
+	// UpdateTaskStatusToWork переводит арендованную подзадачу в статус 'work', запоминая принявший ее калькулятор agent,
+	// или возвращает ErrTaskNotReady.
+	UpdateTaskStatusToWork(taskID int, owner, agent string) error
+	// ReleaseTask снимает аренду подзадачи и возвращает ее в очередь.
+	ReleaseTask(taskID int, owner string) error
+	// RetryTask возвращает зависшую подзадачу в очередь и засчитывает вычислению неудачную попытку;
+	// true, если попытки исчерпаны и вычисление перешло в статус 'failed'.
+	RetryTask(taskID int, message string, policy RetryPolicy) (bool, error)
+	// CompleteTask сохраняет результат подзадачи (точный результат exact в точном режиме) или возвращает ErrTaskNotActive.
+	CompleteTask(taskID int, result float64, exact string) error
+	// FailTask переводит подзадачу и ее вычисление в статус 'error' или возвращает ErrTaskNotActive.
+	FailTask(taskID int, message string) error
+	// FetchTasksByCalculation возвращает подзадачи вычисления, упорядоченные по идентификатору.
+	FetchTasksByCalculation(calculationID int) ([]models.Task, error)
+	// GetTaskByID возвращает подзадачу или sql.ErrNoRows, если ее нет.
+	GetTaskByID(taskID int) (*models.Task, error)
+	// CancelCalculation отменяет вычисление и его невыполненные подзадачи;
+	// sql.ErrNoRows, если вычисления нет, ErrCalculationFinished, если оно уже закончилось.
+	CancelCalculation(id int) error
+	// FetchWorkingTasks возвращает подзадачи в статусе 'work' выполняющихся вычислений.
+	FetchWorkingTasks() ([]models.WorkingTask, error)
+	// FetchFailedCalculations возвращает вычисления всех пользователей в статусе 'failed'.
+	FetchFailedCalculations() ([]models.FailedCalculation, error)
+	// RequeueCalculation возвращает вычисление из статуса 'failed' в очередь;
+	// sql.ErrNoRows, если вычисления нет, ErrCalculationNotFailed, если оно не в статусе 'failed'.
+	RequeueCalculation(id int) error
+
+	// RegisterUser добавляет пользователя с хешированным паролем.
+	RegisterUser(login, password string) error
+	// GetUserByLogin возвращает пользователя или ErrUserNotFound.
+	GetUserByLogin(login string) (*models.User, error)
+
+	// Close освобождает ресурсы хранилища.
+	Close() error
+}
+
+// dialect описывает различия SQL между PostgreSQL и SQLite, которые учитывают общие функции пакета.
+type dialect struct {
+	migrations string // Каталог встроенных миграций
+	lockSchema string // Блокировка миграций до конца транзакции, пустая строка, если она не нужна
+	claimLock  string // Окончание SELECT, блокирующее выбранные подзадачи и пропускающее уже заблокированные
+	rowLock    string // Окончание SELECT, блокирующее строку до конца транзакции
+}
+
+// SQLite блокирует всю базу на время пишущей транзакции, поэтому блокировки строк ему не нужны.
+var (
+	postgresDialect = dialect{
+		migrations: "migrations/postgres",
+		lockSchema: `SELECT pg_advisory_xact_lock($1)`,
+		claimLock:  "FOR UPDATE OF t SKIP LOCKED",
+		rowLock:    "FOR UPDATE",
+	}
+	sqliteDialect = dialect{
+		migrations: "migrations/sqlite",
+	}
+)
+
+// SQLStore - хранилище поверх database/sql. PostgreSQL и SQLite используют одни и те же функции пакета,
+// отличаясь только диалектом.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// NewPostgresStore создает хранилище поверх соединения с PostgreSQL, схема которого уже мигрирована.
+func NewPostgresStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, dialect: postgresDialect}
+}
+
+func (s *SQLStore) InsertCalculation(userId int, operation string, addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration, inactiveServerTime int, functionDurations map[string]int, variables map[string]float64, precision *calculation.Precision) (int, error) {
+	return InsertCalculation(s.db, userId, operation, addDuration, subtractDuration, multiplyDuration, divideDuration, powerDuration, moduloDuration, intDivideDuration, inactiveServerTime, functionDurations, variables, precision)
+}
+
+func (s *SQLStore) UpdateCalculation(id int, result float64, exact string, status string) error {
+	return UpdateCalculation(s.db, id, result, exact, status)
+}
+
+func (s *SQLStore) UpdateCalculationError(id int, message string) error {
+	return UpdateCalculationError(s.db, id, message)
+}
+
+func (s *SQLStore) UpdateCalculationStatusToWork(id int) error {
+	return UpdateCalculationStatusToWork(s.db, id)
+}
+
+func (s *SQLStore) GetCalculationResultByID(id int) (*models.CalculationResponse, error) {
+	return GetCalculationResultByID(s.db, id)
+}
+
+func (s *SQLStore) FetchCalculationsByUser(userId int) ([]models.OperationResponse, error) {
+	return FetchCalculationsByUser(s.db, userId)
+}
+
+func (s *SQLStore) ClearCalculationsByUser(userId int) error {
+	return ClearCalculationsByUser(s.db, userId)
+}
+
+func (s *SQLStore) CreateCalculationTasks(calculationID int, plan *calculation.Plan) error {
+	return CreateCalculationTasks(s.db, calculationID, plan)
+}
+
+func (s *SQLStore) FetchUnplannedCalculations() ([]models.CalculationRequest, error) {
+	return FetchUnplannedCalculations(s.db)
+}
+
+func (s *SQLStore) ClaimTasks(owner string, limit int, lease time.Duration) ([]models.CalculationRequest, error) {
+	return claimTasks(s.db, s.dialect, owner, limit, lease)
+}
+
+func (s *SQLStore) ClaimTask(owner, agent string, lease time.Duration) (*models.CalculationRequest, error) {
+	return claimTask(s.db, s.dialect, owner, agent, lease)
+}
+
+func (s *SQLStore) UpdateTaskStatusToWork(taskID int, owner, agent string) error {
+	return UpdateTaskStatusToWork(s.db, taskID, owner, agent)
+}
+
+func (s *SQLStore) ReleaseTask(taskID int, owner string) error {
+	return ReleaseTask(s.db, taskID, owner)
+}
+
+func (s *SQLStore) RetryTask(taskID int, message string, policy RetryPolicy) (bool, error) {
+	return RetryTask(s.db, taskID, message, policy)
+}
+
+func (s *SQLStore) CompleteTask(taskID int, result float64, exact string) error {
+	return completeTask(s.db, s.dialect, taskID, result, exact)
+}
+
+func (s *SQLStore) FailTask(taskID int, message string) error {
+	return FailTask(s.db, taskID, message)
+}
+
+func (s *SQLStore) CancelCalculation(id int) error {
+	return cancelCalculation(s.db, s.dialect, id)
+}
+
+func (s *SQLStore) FetchTasksByCalculation(calculationID int) ([]models.Task, error) {
+	return FetchTasksByCalculation(s.db, calculationID)
+}
+
+func (s *SQLStore) GetTaskByID(taskID int) (*models.Task, error) {
+	return GetTaskByID(s.db, taskID)
+}
+
+func (s *SQLStore) FetchWorkingTasks() ([]models.WorkingTask, error) {
+	return FetchWorkingTasks(s.db)
+}
+
+func (s *SQLStore) FetchFailedCalculations() ([]models.FailedCalculation, error) {
+	return FetchFailedCalculations(s.db)
+}
+
+func (s *SQLStore) RequeueCalculation(id int) error {
+	return requeueCalculation(s.db, s.dialect, id)
+}
+
+func (s *SQLStore) RegisterUser(login, password string) error {
+	return RegisterUser(s.db, login, password)
+}
+
+func (s *SQLStore) GetUserByLogin(login string) (*models.User, error) {
+	return GetUserByLogin(s.db, login)
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
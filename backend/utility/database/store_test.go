@@ -0,0 +1,452 @@
+package database
+
+import (
+    "database/sql"
+    "errors"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+
+    "calculatorapi/utility/calculation"
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Оба встроенных хранилища должны вести себя одинаково, поэтому проверяются одним сценарием.
+func TestMemoryStore(t *testing.T) {
+    testStore(t, NewMemoryStore())
+}
+
+func TestSQLiteStore(t *testing.T) {
+    store, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "calculator.db"))
+    if err != nil {
+        t.Fatalf("OpenSQLiteStore returned error: %v", err)
+    }
+    defer store.Close()
+    testStore(t, store)
+}
+
+func testStore(t *testing.T, store Store) {
+    t.Run("Calculation lifecycle", func(t *testing.T) { testCalculationLifecycle(t, store) })
+    t.Run("Failed task", func(t *testing.T) { testFailedTask(t, store) })
+    t.Run("Expired lease", func(t *testing.T) { testExpiredLease(t, store) })
+    t.Run("Cancelled calculation", func(t *testing.T) { testCancelledCalculation(t, store) })
+    t.Run("Finished calculation", func(t *testing.T) { testFinishedCalculation(t, store) })
+    t.Run("Retries", func(t *testing.T) { testRetries(t, store) })
+    t.Run("Exact result", func(t *testing.T) { testExactResult(t, store) })
+    t.Run("Rational result", func(t *testing.T) { testRationalResult(t, store) })
+    t.Run("Users", func(t *testing.T) { testUsers(t, store) })
+}
+
+// planCalculation сохраняет вычисление и его граф подзадач.
+func planCalculation(t *testing.T, store Store, userId int, expression string) int {
+    id, err := store.InsertCalculation(userId, expression, 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    if err != nil {
+        t.Fatalf("InsertCalculation returned error: %v", err)
+    }
+    plan, err := calculation.Decompose(expression)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := store.CreateCalculationTasks(id, plan); err != nil {
+        t.Fatalf("CreateCalculationTasks returned error: %v", err)
+    }
+    return id
+}
+
+func testCalculationLifecycle(t *testing.T, store Store) {
+    id, err := store.InsertCalculation(1, "2*3 + 4*5", 1, 2, 3, 4, 5, 6, 7, 60, map[string]int{"sqrt": 8}, map[string]float64{"rate": 0.07}, nil)
+    if err != nil {
+        t.Fatalf("InsertCalculation returned error: %v", err)
+    }
+    unplanned, err := store.FetchUnplannedCalculations()
+    if err != nil || len(unplanned) != 1 || unplanned[0].ID != id {
+        t.Fatalf("Expected calculation %d to be unplanned, got %v, %v", id, unplanned, err)
+    }
+    if unplanned[0].Variables["rate"] != 0.07 {
+        t.Errorf("Expected variables of the unplanned calculation, got %+v", unplanned[0])
+    }
+
+    plan, _ := calculation.Decompose("2*3 + 4*5")
+    if err := store.CreateCalculationTasks(id, plan); err != nil {
+        t.Fatalf("CreateCalculationTasks returned error: %v", err)
+    }
+    if unplanned, _ := store.FetchUnplannedCalculations(); len(unplanned) != 0 {
+        t.Errorf("Expected no unplanned calculations, got %v", unplanned)
+    }
+
+    // Готовы только два умножения; пока аренда действует, другой экземпляр их не получит
+    claimed, err := store.ClaimTasks("orchestrator-a", 10, time.Minute)
+    if err != nil || len(claimed) != 2 {
+        t.Fatalf("Expected two ready tasks, got %v, %v", claimed, err)
+    }
+    if claimed[0].MultiplyDuration != 3 || claimed[0].PowerDuration != 5 || claimed[0].IntDivideDuration != 7 || claimed[0].FunctionDurations["sqrt"] != 8 || claimed[0].Variables["rate"] != 0.07 || claimed[0].UserId != 1 || claimed[0].ID != id {
+        t.Errorf("Unexpected claimed task: %+v", claimed[0])
+    }
+    if again, _ := store.ClaimTasks("orchestrator-b", 10, time.Minute); len(again) != 0 {
+        t.Errorf("Expected leased tasks to be skipped, got %v", again)
+    }
+
+    first, second := claimed[0].TaskID, claimed[1].TaskID
+    if err := store.UpdateTaskStatusToWork(first, "orchestrator-b", "calculator1"); err != ErrTaskNotReady {
+        t.Errorf("Expected ErrTaskNotReady for a foreign lease, got %v", err)
+    }
+    if err := store.UpdateTaskStatusToWork(first, "orchestrator-a", "calculator1"); err != nil {
+        t.Fatalf("UpdateTaskStatusToWork returned error: %v", err)
+    }
+    if err := store.ReleaseTask(second, "orchestrator-a"); err != nil {
+        t.Fatalf("ReleaseTask returned error: %v", err)
+    }
+
+    working, err := store.FetchWorkingTasks()
+    if err != nil || len(working) != 1 || working[0].ID != first || working[0].StartTime.IsZero() {
+        t.Fatalf("Expected task %d to be working, got %v, %v", first, working, err)
+    }
+    if working[0].Agent != "calculator1" || working[0].InactiveServerTime != 60 || working[0].ModuloDuration != 6 || working[0].FunctionDurations["sqrt"] != 8 {
+        t.Errorf("Expected agent, durations and timeout of the working task, got %+v", working[0])
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "work" || result.Variables["rate"] != 0.07 {
+        t.Errorf("Expected calculation status work with its variables, got %+v", result)
+    }
+
+    if err := store.CompleteTask(first, 6, ""); err != nil {
+        t.Fatalf("CompleteTask returned error: %v", err)
+    }
+    if err := store.CompleteTask(first, 6, ""); !errors.Is(err, ErrTaskNotActive) {
+        t.Errorf("Expected ErrTaskNotActive for a completed task, got %v", err)
+    }
+
+    task, err := store.ClaimTask("orchestrator-a", "calculator1", time.Minute)
+    if err != nil || task == nil || task.TaskID != second {
+        t.Fatalf("Expected released task %d to be claimed, got %v, %v", second, task, err)
+    }
+    if err := store.CompleteTask(second, 20, ""); err != nil {
+        t.Fatalf("CompleteTask returned error: %v", err)
+    }
+
+    // Оба операнда сложения известны, поэтому корневая подзадача готова
+    task, err = store.ClaimTask("orchestrator-a", "calculator1", time.Minute)
+    if err != nil || task == nil {
+        t.Fatalf("Expected the root task to be ready, got %v", err)
+    }
+    if _, value, err := calculation.Evaluate(task.Operation, calculation.OperationTimes{}); err != nil || value != 26 {
+        t.Errorf("Expected root operation to evaluate to 26, got %q = %v, %v", task.Operation, value, err)
+    }
+    if err := store.CompleteTask(task.TaskID, 26, ""); err != nil {
+        t.Fatalf("CompleteTask returned error: %v", err)
+    }
+
+    result, err := store.GetCalculationResultByID(id)
+    if err != nil || result.Status != "completed" || result.Result != 26 || result.UserId != 1 {
+        t.Errorf("Expected completed calculation with result 26, got %+v, %v", result, err)
+    }
+    tasks, err := store.FetchTasksByCalculation(id)
+    if err != nil || len(tasks) != 3 || tasks[2].Status != "completed" || tasks[0].ParentID != tasks[2].ID {
+        t.Fatalf("Unexpected tasks: %+v, %v", tasks, err)
+    }
+    if task, err := store.GetTaskByID(tasks[2].ID); err != nil || task.Status != "completed" || task.Result != 26 || task.CalculationID != id {
+        t.Errorf("Unexpected root task: %+v, %v", task, err)
+    }
+    if _, err := store.GetTaskByID(tasks[2].ID + 1000); err != sql.ErrNoRows {
+        t.Errorf("Expected sql.ErrNoRows for a missing task, got %v", err)
+    }
+
+    if _, err := store.GetCalculationResultByID(id + 1000); err != sql.ErrNoRows {
+        t.Errorf("Expected sql.ErrNoRows for a missing calculation, got %v", err)
+    }
+
+    if err := store.ClearCalculationsByUser(1); err != nil {
+        t.Fatalf("ClearCalculationsByUser returned error: %v", err)
+    }
+    if calculations, _ := store.FetchCalculationsByUser(1); len(calculations) != 0 {
+        t.Errorf("Expected no calculations after clearing, got %v", calculations)
+    }
+    if tasks, _ := store.FetchTasksByCalculation(id); len(tasks) != 0 {
+        t.Errorf("Expected tasks to be removed with the calculation, got %v", tasks)
+    }
+}
+
+func testFailedTask(t *testing.T, store Store) {
+    id := planCalculation(t, store, 2, "(1+2)*3")
+
+    claimed, err := store.ClaimTasks("orchestrator-a", 10, time.Minute)
+    if err != nil || len(claimed) != 1 {
+        t.Fatalf("Expected one ready task, got %v, %v", claimed, err)
+    }
+    if err := store.FailTask(claimed[0].TaskID, "agent failed"); err != nil {
+        t.Fatalf("FailTask returned error: %v", err)
+    }
+
+    result, err := store.GetCalculationResultByID(id)
+    if err != nil || result.Status != "error" || result.Error != "agent failed" {
+        t.Errorf("Expected failed calculation, got %+v, %v", result, err)
+    }
+    tasks, _ := store.FetchTasksByCalculation(id)
+    if len(tasks) != 2 || tasks[0].Status != "error" || tasks[1].Status != "cancelled" {
+        t.Errorf("Expected failed and cancelled tasks, got %+v", tasks)
+    }
+    store.ClearCalculationsByUser(2)
+}
+
+func testExactResult(t *testing.T, store Store) {
+    precision := calculation.Decimal(2, calculation.RoundHalfUp)
+    id, err := store.InsertCalculation(6, "0.1 + 0.2 * 3", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, &precision)
+    if err != nil {
+        t.Fatalf("InsertCalculation returned error: %v", err)
+    }
+    if unplanned, _ := store.FetchUnplannedCalculations(); len(unplanned) != 1 || !reflect.DeepEqual(unplanned[0].Precision, &precision) {
+        t.Fatalf("Expected the precision of the unplanned calculation, got %+v", unplanned)
+    }
+    plan, _ := calculation.Decompose("0.1 + 0.2 * 3")
+    if err := store.CreateCalculationTasks(id, plan); err != nil {
+        t.Fatalf("CreateCalculationTasks returned error: %v", err)
+    }
+
+    // Точный результат умножения передается сложению без преобразования в float64
+    claimed, err := store.ClaimTasks("orchestrator-a", 10, time.Minute)
+    if err != nil || len(claimed) != 1 || claimed[0].Precision == nil || *claimed[0].Precision.Scale != 2 {
+        t.Fatalf("Expected one task with precision, got %+v, %v", claimed, err)
+    }
+    if err := store.CompleteTask(claimed[0].TaskID, 0.6, "0.6"); err != nil {
+        t.Fatalf("CompleteTask returned error: %v", err)
+    }
+    claimed, err = store.ClaimTasks("orchestrator-a", 10, time.Minute)
+    if err != nil || len(claimed) != 1 || claimed[0].Operation != "0.1 + 0.6" {
+        t.Fatalf("Expected the root task with the exact operand, got %+v, %v", claimed, err)
+    }
+    if err := store.CompleteTask(claimed[0].TaskID, 0.7, "0.7"); err != nil {
+        t.Fatalf("CompleteTask returned error: %v", err)
+    }
+
+    result, err := store.GetCalculationResultByID(id)
+    if err != nil || result.Status != "completed" || result.ExactResult != "0.7" || !reflect.DeepEqual(result.Precision, &precision) {
+        t.Errorf("Expected the exact result with its precision, got %+v, %v", result, err)
+    }
+    if calculations, _ := store.FetchCalculationsByUser(6); len(calculations) != 1 || calculations[0].ExactResult != "0.7" {
+        t.Errorf("Expected the exact result in the user's calculations, got %+v", calculations)
+    }
+    store.ClearCalculationsByUser(6)
+}
+
+func testRationalResult(t *testing.T, store Store) {
+    precision := &calculation.Precision{Mode: calculation.ModeRational}
+    id, err := store.InsertCalculation(7, "(10 / 3) ^ 2", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, precision)
+    if err != nil {
+        t.Fatalf("InsertCalculation returned error: %v", err)
+    }
+    plan, _ := calculation.Decompose("(10 / 3) ^ 2")
+    if err := store.CreateCalculationTasks(id, plan); err != nil {
+        t.Fatalf("CreateCalculationTasks returned error: %v", err)
+    }
+
+    claimed, err := store.ClaimTasks("orchestrator-a", 10, time.Minute)
+    if err != nil || len(claimed) != 1 || claimed[0].Precision == nil || *claimed[0].Precision != *precision {
+        t.Fatalf("Expected one task in the rational mode, got %+v, %v", claimed, err)
+    }
+    if err := store.CompleteTask(claimed[0].TaskID, 10.0/3, "10/3"); err != nil {
+        t.Fatalf("CompleteTask returned error: %v", err)
+    }
+    // Дробь передается степени литералом [10/3], иначе 10/3 ^ 2 означало бы 10/(3^2)
+    claimed, err = store.ClaimTasks("orchestrator-a", 10, time.Minute)
+    if err != nil || len(claimed) != 1 || claimed[0].Operation != "[10/3] ^ 2" {
+        t.Fatalf("Expected the root task with the fraction operand, got %+v, %v", claimed, err)
+    }
+    if err := store.CompleteTask(claimed[0].TaskID, 100.0/9, "100/9"); err != nil {
+        t.Fatalf("CompleteTask returned error: %v", err)
+    }
+
+    result, err := store.GetCalculationResultByID(id)
+    if err != nil || result.ExactResult != "100/9" || result.Precision == nil || *result.Precision != *precision {
+        t.Errorf("Expected the fraction result in the rational mode, got %+v, %v", result, err)
+    }
+    store.ClearCalculationsByUser(7)
+}
+
+func testExpiredLease(t *testing.T, store Store) {
+    planCalculation(t, store, 3, "1+2")
+
+    // Аренда уже истекла, поэтому подзадачу может забрать другой экземпляр
+    if claimed, err := store.ClaimTasks("orchestrator-a", 10, -time.Second); err != nil || len(claimed) != 1 {
+        t.Fatalf("Expected one ready task, got %v, %v", claimed, err)
+    }
+    claimed, err := store.ClaimTasks("orchestrator-b", 10, time.Minute)
+    if err != nil || len(claimed) != 1 {
+        t.Fatalf("Expected the expired lease to be claimed, got %v, %v", claimed, err)
+    }
+    if err := store.UpdateTaskStatusToWork(claimed[0].TaskID, "orchestrator-a", ""); err != ErrTaskNotReady {
+        t.Errorf("Expected ErrTaskNotReady for the previous owner, got %v", err)
+    }
+    store.ClearCalculationsByUser(3)
+}
+
+func testFinishedCalculation(t *testing.T, store Store) {
+    id, err := store.InsertCalculation(8, "2+2", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    if err != nil {
+        t.Fatalf("InsertCalculation returned error: %v", err)
+    }
+    if err := store.UpdateCalculation(id, 4, "", "completed"); err != nil {
+        t.Fatalf("UpdateCalculation returned error: %v", err)
+    }
+
+    // Повторные отчеты о завершенном вычислении не меняют его результат и статус
+    store.UpdateCalculation(id, 5, "", "completed")
+    store.UpdateCalculationError(id, "late error")
+    store.UpdateCalculationStatusToWork(id)
+    result, err := store.GetCalculationResultByID(id)
+    if err != nil || result.Status != "completed" || result.Result != 4 || result.Error != "" {
+        t.Errorf("Expected the completed calculation to stay unchanged, got %+v, %v", result, err)
+    }
+
+    id, _ = store.InsertCalculation(8, "2+2", 1, 1, 1, 1, 1, 1, 1, 0, nil, nil, nil)
+    if err := store.CancelCalculation(id); err != nil {
+        t.Fatalf("CancelCalculation returned error: %v", err)
+    }
+    store.UpdateCalculation(id, 4, "", "completed")
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "cancelled" {
+        t.Errorf("Expected the cancelled calculation to stay cancelled, got %+v", result)
+    }
+    store.ClearCalculationsByUser(8)
+}
+
+func testCancelledCalculation(t *testing.T, store Store) {
+    id := planCalculation(t, store, 4, "1+2*3")
+
+    claimed, err := store.ClaimTask("orchestrator-a", "calculator1", time.Minute)
+    if err != nil || claimed == nil {
+        t.Fatalf("Expected a ready task, got %v", err)
+    }
+    if err := store.CancelCalculation(id); err != nil {
+        t.Fatalf("CancelCalculation returned error: %v", err)
+    }
+    if err := store.CancelCalculation(id); !errors.Is(err, ErrCalculationFinished) {
+        t.Errorf("Expected ErrCalculationFinished for a cancelled calculation, got %v", err)
+    }
+    if err := store.CancelCalculation(id + 1000); err != sql.ErrNoRows {
+        t.Errorf("Expected sql.ErrNoRows for a missing calculation, got %v", err)
+    }
+
+    // Результаты, пришедшие после отмены, отклоняются и не меняют статус вычисления
+    if err := store.CompleteTask(claimed.TaskID, 6, ""); !errors.Is(err, ErrTaskNotActive) {
+        t.Errorf("Expected ErrTaskNotActive for a late result, got %v", err)
+    }
+    if err := store.FailTask(claimed.TaskID, "late error"); !errors.Is(err, ErrTaskNotActive) {
+        t.Errorf("Expected ErrTaskNotActive for a late error, got %v", err)
+    }
+    result, err := store.GetCalculationResultByID(id)
+    if err != nil || result.Status != "cancelled" {
+        t.Errorf("Expected cancelled calculation, got %+v, %v", result, err)
+    }
+    tasks, _ := store.FetchTasksByCalculation(id)
+    for _, task := range tasks {
+        if task.Status != "cancelled" {
+            t.Errorf("Expected task %d to be cancelled, got %q", task.ID, task.Status)
+        }
+    }
+    if claimed, _ := store.ClaimTasks("orchestrator-a", 10, time.Minute); len(claimed) != 0 {
+        t.Errorf("Expected no tasks of a cancelled calculation to be claimed, got %v", claimed)
+    }
+    store.ClearCalculationsByUser(4)
+}
+
+func testRetries(t *testing.T, store Store) {
+    policy := RetryPolicy{MaxAttempts: 2, Backoff: 20 * time.Millisecond}
+    id := planCalculation(t, store, 5, "2*3")
+
+    claimed, err := store.ClaimTask("orchestrator-a", "calculator1", time.Minute)
+    if err != nil || claimed == nil {
+        t.Fatalf("Expected a ready task, got %v", err)
+    }
+    failed, err := store.RetryTask(claimed.TaskID, "agent stopped responding", policy)
+    if err != nil || failed {
+        t.Fatalf("Expected the first attempt to be retried, got %v, %v", failed, err)
+    }
+    if _, err := store.RetryTask(claimed.TaskID, "agent stopped responding", policy); !errors.Is(err, ErrTaskNotActive) {
+        t.Errorf("Expected ErrTaskNotActive for a task that is not in work, got %v", err)
+    }
+
+    // До окончания паузы подзадача не отправляется повторно
+    if again, _ := store.ClaimTasks("orchestrator-a", 10, time.Minute); len(again) != 0 {
+        t.Errorf("Expected the retry to be delayed, got %v", again)
+    }
+    time.Sleep(50 * time.Millisecond)
+    claimed, err = store.ClaimTask("orchestrator-a", "calculator1", time.Minute)
+    if err != nil || claimed == nil {
+        t.Fatalf("Expected the task to be retried after the backoff, got %v", err)
+    }
+
+    // Последняя попытка переводит вычисление в статус 'failed' с последней ошибкой
+    failed, err = store.RetryTask(claimed.TaskID, "agent crashed", policy)
+    if err != nil || !failed {
+        t.Fatalf("Expected the calculation to fail after the last attempt, got %v, %v", failed, err)
+    }
+    result, err := store.GetCalculationResultByID(id)
+    if err != nil || result.Status != "failed" || result.Error != "agent crashed" || result.Attempts != 2 {
+        t.Errorf("Expected failed calculation after 2 attempts, got %+v, %v", result, err)
+    }
+
+    // Запоздавшая ошибка подзадачи не переводит исчерпавшее попытки вычисление в статус 'error'
+    if err := store.FailTask(claimed.TaskID, "late error"); !errors.Is(err, ErrTaskNotActive) {
+        t.Errorf("Expected ErrTaskNotActive for a task of a failed calculation, got %v", err)
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "failed" || result.Error != "agent crashed" {
+        t.Errorf("Expected the failed calculation to stay unchanged, got %+v", result)
+    }
+    if again, _ := store.ClaimTasks("orchestrator-a", 10, time.Minute); len(again) != 0 {
+        t.Errorf("Expected no tasks of a failed calculation to be claimed, got %v", again)
+    }
+    list, err := store.FetchFailedCalculations()
+    if err != nil || len(list) != 1 || list[0].ID != id || list[0].Attempts != 2 || list[0].UserId != 5 {
+        t.Errorf("Expected calculation %d among failed calculations, got %+v, %v", id, list, err)
+    }
+
+    // Возвращенное в очередь вычисление выполняется заново со сброшенным счетчиком
+    if err := store.RequeueCalculation(id); err != nil {
+        t.Fatalf("RequeueCalculation returned error: %v", err)
+    }
+    if err := store.RequeueCalculation(id); !errors.Is(err, ErrCalculationNotFailed) {
+        t.Errorf("Expected ErrCalculationNotFailed for a requeued calculation, got %v", err)
+    }
+    if err := store.RequeueCalculation(id + 1000); err != sql.ErrNoRows {
+        t.Errorf("Expected sql.ErrNoRows for a missing calculation, got %v", err)
+    }
+    claimed, err = store.ClaimTask("orchestrator-a", "calculator1", time.Minute)
+    if err != nil || claimed == nil {
+        t.Fatalf("Expected the requeued task to be claimed, got %v", err)
+    }
+    if err := store.CompleteTask(claimed.TaskID, 6, ""); err != nil {
+        t.Fatalf("CompleteTask returned error: %v", err)
+    }
+    if result, _ := store.GetCalculationResultByID(id); result.Status != "completed" || result.Result != 6 || result.Attempts != 0 {
+        t.Errorf("Expected completed calculation, got %+v", result)
+    }
+    store.ClearCalculationsByUser(5)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+    policy := RetryPolicy{MaxAttempts: 5, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+    for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+        if got := policy.Delay(attempt); got != want {
+            t.Errorf("Delay(%d) = %v, want %v", attempt, got, want)
+        }
+    }
+}
+
+func testUsers(t *testing.T, store Store) {
+    if err := store.RegisterUser("alice", "password"); err != nil {
+        t.Fatalf("RegisterUser returned error: %v", err)
+    }
+    if err := store.RegisterUser("alice", "other"); err == nil {
+        t.Error("Expected duplicate login to be rejected")
+    }
+
+    user, err := store.GetUserByLogin("alice")
+    if err != nil || user.ID == 0 {
+        t.Fatalf("GetUserByLogin returned %v, %v", user, err)
+    }
+    if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("password")); err != nil {
+        t.Errorf("Expected hashed password to match: %v", err)
+    }
+    if _, err := store.GetUserByLogin("bob"); err != ErrUserNotFound {
+        t.Errorf("Expected ErrUserNotFound, got %v", err)
+    }
+}
@@ -0,0 +1,825 @@
+package database
+
+import (
+	"database/sql"  // Для работы с SQL базами данных
+	"encoding/json" // Для хранения операндов подзадач в формате JSON
+	"errors"        // Для объявления и проверки ошибок
+	"fmt"           // Форматированный вывод
+	"strconv"       // Для записи результатов подзадач в операнды
+	"time"          // Работа со временем
+
+	"calculatorapi/utility/calculation" // Граф задач выражения
+	"calculatorapi/utility/models"      // Структуры данных для калькулятора
+)
+
+// ErrTaskNotReady возвращается, если задача уже не находится в очереди или аренда задачи
+// принадлежит другому оркестратору.
+var ErrTaskNotReady = errors.New("task is not ready")
+
+// ErrTaskNotActive возвращается при попытке сохранить результат задачи, которая уже завершена или отменена.
+var ErrTaskNotActive = errors.New("task is not active")
+
+// ErrCalculationFinished возвращается при попытке отменить уже завершенное, неудачное или отмененное вычисление.
+var ErrCalculationFinished = errors.New("calculation is already finished")
+
+// ErrCalculationNotFailed возвращается при попытке вернуть в очередь вычисление, которое не находится в статусе 'failed'.
+var ErrCalculationNotFailed = errors.New("calculation is not failed")
+
+// RetryPolicy ограничивает число попыток вычисления и задает паузу перед повторной отправкой его подзадач.
+type RetryPolicy struct {
+	MaxAttempts int           // Число попыток, после которого вычисление переходит в статус 'failed'
+	Backoff     time.Duration // Пауза после первой неудачной попытки, удваивается с каждой следующей
+	MaxBackoff  time.Duration // Наибольшая пауза; ноль снимает ограничение
+}
+
+// Delay возвращает паузу перед попыткой, следующей за attempt неудачными.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+// CreateCalculationTasks сохраняет граф задач вычисления в одной транзакции.
+// Задачи, все операнды которых уже известны, получают статус 'ready', остальные - 'waiting'.
+func CreateCalculationTasks(db *sql.DB, calculationID int, plan *calculation.Plan) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	createdTime := time.Now().UTC()
+	ids := make([]int, len(plan.Tasks)) // Идентификаторы строк по индексам задач плана
+
+	// Задачи в плане упорядочены так, что операнды идут раньше зависящих от них задач
+	for i, task := range plan.Tasks {
+		operands := make([]models.TaskOperand, len(task.Operands))
+		status := "ready"
+		for j, operand := range task.Operands {
+			if operand.Task >= 0 {
+				operands[j] = models.TaskOperand{TaskID: ids[operand.Task]}
+				status = "waiting"
+			} else {
+				operands[j] = models.TaskOperand{Value: operand.Value}
+			}
+		}
+
+		encoded, err := json.Marshal(operands)
+		if err != nil {
+			return fmt.Errorf("encoding task operands: %w", err)
+		}
+
+		query := `
+			INSERT INTO tasks (calculation_id, operator, operands, status, created_time)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`
+		if err := tx.QueryRow(query, calculationID, task.Op, string(encoded), status, createdTime).Scan(&ids[i]); err != nil {
+			return fmt.Errorf("inserting task: %w", err)
+		}
+	}
+
+	// Связывание задач с родителями, которые получат их результаты
+	for i, task := range plan.Tasks {
+		if task.Parent < 0 {
+			continue
+		}
+		query := `UPDATE tasks SET parent_id = $1, parent_position = $2 WHERE id = $3`
+		if _, err := tx.Exec(query, ids[task.Parent], task.Position, ids[i]); err != nil {
+			return fmt.Errorf("linking task %d to its parent: %w", ids[i], err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing tasks: %w", err)
+	}
+
+	fmt.Printf("Calculation ID %d split into %d tasks.\n", calculationID, len(plan.Tasks))
+	return nil
+}
+
+// FetchUnplannedCalculations извлекает вычисления со статусом "created", для которых еще не созданы задачи.
+func FetchUnplannedCalculations(db *sql.DB) ([]models.CalculationRequest, error) {
+	var calculations []models.CalculationRequest
+
+	query := `
+		SELECT c.id, c.userId, c.operation, c.variables, c.precision_scale, c.rounding_mode, c.precision_mode
+		FROM calculations c
+		WHERE c.status = 'created' AND NOT EXISTS (SELECT 1 FROM tasks t WHERE t.calculation_id = c.id)
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("querying unplanned calculations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			calc      models.CalculationRequest
+			variables sql.NullString
+			scale     sql.NullInt64
+			rounding  sql.NullString
+			mode      sql.NullString
+		)
+		if err := rows.Scan(&calc.ID, &calc.UserId, &calc.Operation, &variables, &scale, &rounding, &mode); err != nil {
+			return nil, fmt.Errorf("scanning unplanned calculation: %w", err)
+		}
+		calc.Precision = decodePrecision(scale, rounding, mode)
+		if calc.Variables, err = decodeMap[float64](variables); err != nil {
+			return nil, fmt.Errorf("calculation %d: %w", calc.ID, err)
+		}
+		calculations = append(calculations, calc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over unplanned calculations: %w", err)
+	}
+	return calculations, nil
+}
+
+// ClaimTasks атомарно забирает из очереди не более limit задач для оркестратора owner.
+// Задачи вычислений, ожидающих повторной попытки (retry_after), пропускаются до окончания паузы.
+// В одной транзакции выбираются готовые задачи и задачи 'dispatched' с истекшей арендой
+// (SELECT ... FOR UPDATE SKIP LOCKED не дает двум оркестраторам выбрать одну строку),
+// после чего они переводятся в статус 'dispatched' с владельцем аренды и временем ее окончания.
+// Поле Operation каждой записи содержит выражение одной операции с уже подставленными операндами.
+func ClaimTasks(db *sql.DB, owner string, limit int, lease time.Duration) ([]models.CalculationRequest, error) {
+	return claimTasks(db, postgresDialect, owner, limit, lease)
+}
+
+// claimTasks забирает задачи из очереди с блокировкой строк, принятой в диалекте d.
+func claimTasks(db *sql.DB, d dialect, owner string, limit int, lease time.Duration) ([]models.CalculationRequest, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := `
+		SELECT t.id, t.calculation_id, c.userId, t.operator, t.operands, c.add_duration, c.subtract_duration, c.multiply_duration, c.divide_duration, c.power_duration, c.modulo_duration, c.int_divide_duration, c.function_durations, c.variables, c.precision_scale, c.rounding_mode, c.precision_mode
+		FROM tasks t
+		JOIN calculations c ON c.id = t.calculation_id
+		WHERE (t.status = 'ready' OR (t.status = 'dispatched' AND t.lease_expires_at < $1))
+			AND c.status IN ('created', 'work') AND (c.retry_after IS NULL OR c.retry_after <= $1)
+		ORDER BY t.id
+		LIMIT $2
+	` + d.claimLock
+	rows, err := tx.Query(query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying ready tasks: %w", err)
+	}
+
+	var tasks []models.CalculationRequest
+	for rows.Next() {
+		var (
+			task      models.CalculationRequest
+			operator  string
+			operands  string
+			functions sql.NullString
+			variables sql.NullString
+			scale     sql.NullInt64
+			rounding  sql.NullString
+			mode      sql.NullString
+		)
+		if err := rows.Scan(&task.TaskID, &task.ID, &task.UserId, &operator, &operands, &task.AddDuration, &task.SubtractDuration, &task.MultiplyDuration, &task.DivideDuration, &task.PowerDuration, &task.ModuloDuration, &task.IntDivideDuration, &functions, &variables, &scale, &rounding, &mode); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning ready task: %w", err)
+		}
+		task.Precision = decodePrecision(scale, rounding, mode)
+		if task.FunctionDurations, err = decodeMap[int](functions); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("task %d: %w", task.TaskID, err)
+		}
+		if task.Variables, err = decodeMap[float64](variables); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("task %d: %w", task.TaskID, err)
+		}
+
+		values, err := operandValues(operands)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("task %d: %w", task.TaskID, err)
+		}
+		task.Operation = calculation.TaskExpression(operator, values)
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("iterating over ready tasks: %w", err)
+	}
+	rows.Close()
+
+	expiresAt := now.Add(lease)
+	for _, task := range tasks {
+		query := `UPDATE tasks SET status = 'dispatched', lease_owner = $1, lease_expires_at = $2 WHERE id = $3`
+		if _, err := tx.Exec(query, owner, expiresAt, task.TaskID); err != nil {
+			return nil, fmt.Errorf("leasing task %d: %w", task.TaskID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing claimed tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// ClaimTask забирает из очереди одну задачу для оркестратора owner и сразу переводит ее в статус 'work',
+// так как задача передается калькулятору agent в ответе на его запрос. Возвращает nil, если готовых задач нет.
+func ClaimTask(db *sql.DB, owner, agent string, lease time.Duration) (*models.CalculationRequest, error) {
+	return claimTask(db, postgresDialect, owner, agent, lease)
+}
+
+// claimTask забирает одну задачу с блокировкой строк, принятой в диалекте d.
+func claimTask(db *sql.DB, d dialect, owner, agent string, lease time.Duration) (*models.CalculationRequest, error) {
+	tasks, err := claimTasks(db, d, owner, 1, lease)
+	if err != nil || len(tasks) == 0 {
+		return nil, err
+	}
+
+	task := tasks[0]
+	if err := UpdateTaskStatusToWork(db, task.TaskID, owner, agent); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateTaskStatusToWork переводит задачу, арендованную оркестратором owner, в статус 'work',
+// а ее вычисление - в 'work', если оно еще не начато. agent - имя калькулятора из реестра, принявшего задачу,
+// или пустая строка, если он неизвестен.
+// Возвращает ErrTaskNotReady, если задача уже не в статусе 'dispatched' или аренда перешла к другому оркестратору.
+func UpdateTaskStatusToWork(db *sql.DB, taskID int, owner, agent string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var calculationID int
+	startTime := time.Now().UTC()
+	query := `
+		UPDATE tasks
+		SET status = 'work', start_time = $3, agent = $4
+		WHERE id = $1 AND status = 'dispatched' AND lease_owner = $2
+		RETURNING calculation_id
+	`
+	err = tx.QueryRow(query, taskID, owner, startTime, sql.NullString{String: agent, Valid: agent != ""}).Scan(&calculationID)
+	if err == sql.ErrNoRows {
+		return ErrTaskNotReady
+	}
+	if err != nil {
+		return fmt.Errorf("error updating task status to work: %w", err)
+	}
+
+	query = `
+		UPDATE calculations
+		SET status = 'work', start_time = $2
+		WHERE id = $1 AND status = 'created'
+	`
+	if _, err := tx.Exec(query, calculationID, startTime); err != nil {
+		return fmt.Errorf("error updating calculation status to work: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ReleaseTask возвращает арендованную оркестратором owner задачу в очередь, например если ее не принял ни один калькулятор.
+func ReleaseTask(db *sql.DB, taskID int, owner string) error {
+	query := `
+		UPDATE tasks
+		SET status = 'ready', lease_owner = NULL, lease_expires_at = NULL
+		WHERE id = $1 AND status = 'dispatched' AND lease_owner = $2
+	`
+	if _, err := db.Exec(query, taskID, owner); err != nil {
+		return fmt.Errorf("error releasing task %d: %w", taskID, err)
+	}
+	return nil
+}
+
+// RetryTask возвращает зависшую задачу из статуса 'work' в очередь и засчитывает вычислению неудачную попытку
+// с сообщением message. Следующая попытка откладывается на паузу из policy; после policy.MaxAttempts попыток
+// вычисление переходит в статус 'failed', а его выполняющиеся задачи возвращаются в очередь для RequeueCalculation.
+// Возвращает true, если вычисление перешло в 'failed', и ErrTaskNotActive, если задача уже не в статусе 'work'.
+func RetryTask(db *sql.DB, taskID int, message string, policy RetryPolicy) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var calculationID int
+	query := `
+		UPDATE tasks
+		SET status = 'ready', start_time = NULL, lease_owner = NULL, lease_expires_at = NULL
+		WHERE id = $1 AND status = 'work'
+		RETURNING calculation_id
+	`
+	err = tx.QueryRow(query, taskID).Scan(&calculationID)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+	if err != nil {
+		return false, fmt.Errorf("error resetting task %d to ready: %w", taskID, err)
+	}
+
+	var attempts int
+	query = `
+		UPDATE calculations
+		SET attempts = attempts + 1, error_message = $1
+		WHERE id = $2 AND status IN ('created', 'work')
+		RETURNING attempts
+	`
+	err = tx.QueryRow(query, message, calculationID).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		// Вычисление уже закончилось, попытка не засчитывается
+		return false, tx.Commit()
+	}
+	if err != nil {
+		return false, fmt.Errorf("counting attempt of calculation %d: %w", calculationID, err)
+	}
+
+	now := time.Now().UTC()
+	if attempts < policy.MaxAttempts {
+		query = `UPDATE calculations SET retry_after = $1 WHERE id = $2`
+		if _, err := tx.Exec(query, now.Add(policy.Delay(attempts)), calculationID); err != nil {
+			return false, fmt.Errorf("delaying calculation %d: %w", calculationID, err)
+		}
+		return false, tx.Commit()
+	}
+
+	query = `UPDATE calculations SET status = 'failed', retry_after = NULL, end_time = $1 WHERE id = $2`
+	if _, err := tx.Exec(query, now, calculationID); err != nil {
+		return false, fmt.Errorf("marking calculation %d as failed: %w", calculationID, err)
+	}
+	query = `
+		UPDATE tasks
+		SET status = 'ready', start_time = NULL, lease_owner = NULL, lease_expires_at = NULL
+		WHERE calculation_id = $1 AND status IN ('dispatched', 'work')
+	`
+	if _, err := tx.Exec(query, calculationID); err != nil {
+		return false, fmt.Errorf("returning tasks of calculation %d to the queue: %w", calculationID, err)
+	}
+
+	fmt.Printf("Calculation ID %d failed after %d attempts: %s\n", calculationID, attempts, message)
+	return true, tx.Commit()
+}
+
+// FetchFailedCalculations извлекает вычисления всех пользователей в статусе 'failed'.
+func FetchFailedCalculations(db *sql.DB) ([]models.FailedCalculation, error) {
+	calculations := []models.FailedCalculation{}
+
+	query := `
+		SELECT id, userId, operation, attempts, error_message, end_time
+		FROM calculations
+		WHERE status = 'failed'
+		ORDER BY id
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("querying failed calculations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			calc         models.FailedCalculation
+			errorMessage sql.NullString
+			endTime      sql.NullTime
+		)
+		if err := rows.Scan(&calc.ID, &calc.UserId, &calc.Operation, &calc.Attempts, &errorMessage, &endTime); err != nil {
+			return nil, fmt.Errorf("scanning failed calculation: %w", err)
+		}
+		calc.Error = errorMessage.String
+		calc.FailedAt = endTime.Time
+		calculations = append(calculations, calc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over failed calculations: %w", err)
+	}
+	return calculations, nil
+}
+
+// RequeueCalculation возвращает вычисление из статуса 'failed' в очередь со сброшенным счетчиком попыток.
+// Уже выполненные задачи не повторяются. Возвращает sql.ErrNoRows, если вычисления нет,
+// и ErrCalculationNotFailed, если оно не в статусе 'failed'.
+func RequeueCalculation(db *sql.DB, id int) error {
+	return requeueCalculation(db, postgresDialect, id)
+}
+
+// requeueCalculation возвращает вычисление в очередь, блокируя его строку так, как принято в диалекте d.
+func requeueCalculation(db *sql.DB, d dialect, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	if err := tx.QueryRow(`SELECT status FROM calculations WHERE id = $1 `+d.rowLock, id).Scan(&status); err != nil {
+		return err
+	}
+	if status != "failed" {
+		return fmt.Errorf("calculation %d is %s: %w", id, status, ErrCalculationNotFailed)
+	}
+
+	query := `
+		UPDATE calculations
+		SET status = 'created', attempts = 0, retry_after = NULL, error_message = NULL, end_time = NULL
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(query, id); err != nil {
+		return fmt.Errorf("requeueing calculation %d: %w", id, err)
+	}
+
+	fmt.Printf("Calculation ID %d requeued.\n", id)
+	return tx.Commit()
+}
+
+// CompleteTask сохраняет результат задачи и передает его родительской задаче.
+// Родитель становится 'ready', когда известны все его операнды; результат корневой задачи
+// становится результатом всего вычисления. exact - точная запись результата в точном режиме:
+// она передается родителю вместо result и сохраняется как точный результат вычисления.
+func CompleteTask(db *sql.DB, taskID int, result float64, exact string) error {
+	return completeTask(db, postgresDialect, taskID, result, exact)
+}
+
+// completeTask сохраняет результат задачи, блокируя родительскую задачу так, как принято в диалекте d.
+func completeTask(db *sql.DB, d dialect, taskID int, result float64, exact string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		calculationID int
+		parentID      sql.NullInt64
+		position      sql.NullInt64
+	)
+	endTime := time.Now().UTC()
+	query := `
+		UPDATE tasks
+		SET status = 'completed', result = $1, end_time = $2
+		WHERE id = $3 AND status IN ('ready', 'dispatched', 'work')
+		RETURNING calculation_id, parent_id, parent_position
+	`
+	err = tx.QueryRow(query, result, endTime, taskID).Scan(&calculationID, &parentID, &position)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+	if err != nil {
+		return fmt.Errorf("completing task %d: %w", taskID, err)
+	}
+
+	if !parentID.Valid {
+		// Корневая задача: результат относится ко всему вычислению
+		query = `
+			UPDATE calculations
+			SET result = $1, exact_result = $2, status = 'completed', end_time = $3
+			WHERE id = $4 AND status IN ('created', 'work')
+		`
+		if _, err := tx.Exec(query, result, exactResult(exact), endTime, calculationID); err != nil {
+			return fmt.Errorf("completing calculation %d: %w", calculationID, err)
+		}
+		fmt.Printf("Calculation ID %d completed. Result: %.6f\n", calculationID, result)
+		return tx.Commit()
+	}
+
+	// Подстановка результата в операнды родительской задачи
+	var operandsText string
+	if err := tx.QueryRow(`SELECT operands FROM tasks WHERE id = $1 `+d.rowLock, parentID.Int64).Scan(&operandsText); err != nil {
+		return fmt.Errorf("loading parent task %d: %w", parentID.Int64, err)
+	}
+
+	var operands []models.TaskOperand
+	if err := json.Unmarshal([]byte(operandsText), &operands); err != nil {
+		return fmt.Errorf("decoding operands of task %d: %w", parentID.Int64, err)
+	}
+	if int(position.Int64) >= len(operands) {
+		return fmt.Errorf("task %d refers to missing operand %d of task %d", taskID, position.Int64, parentID.Int64)
+	}
+	operands[position.Int64] = models.TaskOperand{Value: operandValue(result, exact)}
+
+	status := "ready"
+	for _, operand := range operands {
+		if operand.TaskID != 0 {
+			status = "waiting"
+		}
+	}
+
+	encoded, err := json.Marshal(operands)
+	if err != nil {
+		return fmt.Errorf("encoding operands of task %d: %w", parentID.Int64, err)
+	}
+	query = `UPDATE tasks SET operands = $1, status = $2 WHERE id = $3 AND status = 'waiting'`
+	if _, err := tx.Exec(query, string(encoded), status, parentID.Int64); err != nil {
+		return fmt.Errorf("updating parent task %d: %w", parentID.Int64, err)
+	}
+
+	fmt.Printf("Task ID %d of calculation %d completed. Result: %.6f\n", taskID, calculationID, result)
+	return tx.Commit()
+}
+
+// FailTask переводит задачу и ее вычисление в статус 'error' с сообщением об ошибке.
+// Оставшиеся невыполненные задачи вычисления отменяются. Возвращает ErrTaskNotActive,
+// если задача уже завершена или отменена либо само вычисление уже закончилось,
+// например было отменено или исчерпало попытки.
+func FailTask(db *sql.DB, taskID int, message string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var calculationID int
+	endTime := time.Now().UTC()
+	query := `
+		UPDATE tasks
+		SET status = 'error', error_message = $1, end_time = $2
+		WHERE id = $3 AND status IN ('ready', 'dispatched', 'work')
+		RETURNING calculation_id
+	`
+	err = tx.QueryRow(query, message, endTime, taskID).Scan(&calculationID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+	if err != nil {
+		return fmt.Errorf("error updating task %d status to error: %w", taskID, err)
+	}
+
+	query = `UPDATE tasks SET status = 'cancelled' WHERE calculation_id = $1 AND status IN ('waiting', 'ready', 'dispatched')`
+	if _, err := tx.Exec(query, calculationID); err != nil {
+		return fmt.Errorf("cancelling remaining tasks of calculation %d: %w", calculationID, err)
+	}
+
+	query = `
+		UPDATE calculations
+		SET result = NULL, status = 'error', error_message = $1, end_time = $2
+		WHERE id = $3 AND status IN ('created', 'work')
+	`
+	res, err := tx.Exec(query, message, endTime, calculationID)
+	if err != nil {
+		return fmt.Errorf("error updating calculation %d status to error: %w", calculationID, err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("error updating calculation %d status to error: %w", calculationID, err)
+	} else if n == 0 {
+		// Вычисление уже закончилось: транзакция откатывается, задачи остаются как есть
+		return fmt.Errorf("task %d: %w", taskID, ErrTaskNotActive)
+	}
+
+	fmt.Printf("Task ID %d of calculation %d failed: %s\n", taskID, calculationID, message)
+	return tx.Commit()
+}
+
+// CancelCalculation переводит вычисление, которое еще выполняется или ждет в очереди, в статус 'cancelled'
+// вместе со всеми его невыполненными задачами. Результаты, которые калькуляторы пришлют позже, будут отклонены.
+// Возвращает sql.ErrNoRows, если вычисления нет, и ErrCalculationFinished, если оно уже закончилось.
+func CancelCalculation(db *sql.DB, id int) error {
+	return cancelCalculation(db, postgresDialect, id)
+}
+
+// cancelCalculation отменяет вычисление, блокируя его строку так, как принято в диалекте d.
+func cancelCalculation(db *sql.DB, d dialect, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	if err := tx.QueryRow(`SELECT status FROM calculations WHERE id = $1 `+d.rowLock, id).Scan(&status); err != nil {
+		return err
+	}
+	if status != "created" && status != "work" {
+		return fmt.Errorf("calculation %d is %s: %w", id, status, ErrCalculationFinished)
+	}
+
+	query := `UPDATE calculations SET status = 'cancelled', end_time = $1 WHERE id = $2`
+	if _, err := tx.Exec(query, time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("cancelling calculation %d: %w", id, err)
+	}
+
+	query = `
+		UPDATE tasks
+		SET status = 'cancelled', lease_owner = NULL, lease_expires_at = NULL
+		WHERE calculation_id = $1 AND status IN ('waiting', 'ready', 'dispatched', 'work')
+	`
+	if _, err := tx.Exec(query, id); err != nil {
+		return fmt.Errorf("cancelling tasks of calculation %d: %w", id, err)
+	}
+
+	fmt.Printf("Calculation ID %d cancelled.\n", id)
+	return tx.Commit()
+}
+
+// FetchTasksByCalculation извлекает все задачи вычисления для отображения прогресса.
+func FetchTasksByCalculation(db *sql.DB, calculationID int) ([]models.Task, error) {
+	tasks := []models.Task{}
+
+	query := `
+		SELECT id, calculation_id, parent_id, parent_position, operator, operands, status, result, error_message
+		FROM tasks
+		WHERE calculation_id = $1
+		ORDER BY id
+	`
+	rows, err := db.Query(query, calculationID)
+	if err != nil {
+		return nil, fmt.Errorf("querying tasks of calculation %d: %w", calculationID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// GetTaskByID возвращает подзадачу по идентификатору или sql.ErrNoRows, если ее нет.
+func GetTaskByID(db *sql.DB, taskID int) (*models.Task, error) {
+	query := `
+		SELECT id, calculation_id, parent_id, parent_position, operator, operands, status, result, error_message
+		FROM tasks
+		WHERE id = $1
+	`
+	return scanTask(db.QueryRow(query, taskID))
+}
+
+// scanTask читает подзадачу из строки результата запроса.
+func scanTask(row interface{ Scan(dest ...interface{}) error }) (*models.Task, error) {
+	var (
+		task         models.Task
+		parentID     sql.NullInt64
+		position     sql.NullInt64
+		operands     string
+		result       sql.NullFloat64
+		errorMessage sql.NullString
+	)
+	err := row.Scan(&task.ID, &task.CalculationID, &parentID, &position, &task.Operator, &operands, &task.Status, &result, &errorMessage)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning task: %w", err)
+	}
+	if err := json.Unmarshal([]byte(operands), &task.Operands); err != nil {
+		return nil, fmt.Errorf("decoding operands of task %d: %w", task.ID, err)
+	}
+
+	task.ParentID = int(parentID.Int64)
+	task.Position = int(position.Int64)
+	if result.Valid {
+		task.Result = result.Float64
+	}
+	if errorMessage.Valid {
+		task.Error = errorMessage.String
+	}
+	return &task, nil
+}
+
+// FetchWorkingTasks извлекает подзадачи в статусе 'work' вместе с длительностями операций и временем ожидания
+// их вычислений и калькуляторами, которые их выполняют.
+func FetchWorkingTasks(db *sql.DB) ([]models.WorkingTask, error) {
+	var tasks []models.WorkingTask
+
+	query := `
+		SELECT t.id, t.calculation_id, c.userId, t.operator, t.start_time, t.agent, c.add_duration, c.subtract_duration, c.multiply_duration, c.divide_duration, c.power_duration, c.modulo_duration, c.int_divide_duration, c.function_durations, c.inactive_server_time
+		FROM tasks t
+		JOIN calculations c ON c.id = t.calculation_id
+		WHERE t.status = 'work' AND c.status IN ('created', 'work')
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("querying 'work' status tasks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			task               models.WorkingTask
+			agent              sql.NullString
+			functions          sql.NullString
+			inactiveServerTime sql.NullInt64
+		)
+		if err := rows.Scan(&task.ID, &task.CalculationID, &task.UserId, &task.Operator, &task.StartTime, &agent, &task.AddDuration, &task.SubtractDuration, &task.MultiplyDuration, &task.DivideDuration, &task.PowerDuration, &task.ModuloDuration, &task.IntDivideDuration, &functions, &inactiveServerTime); err != nil {
+			return nil, fmt.Errorf("scanning 'work' status task: %w", err)
+		}
+		if task.FunctionDurations, err = decodeMap[int](functions); err != nil {
+			return nil, fmt.Errorf("task %d: %w", task.ID, err)
+		}
+		task.Agent = agent.String
+		task.InactiveServerTime = int(inactiveServerTime.Int64)
+		tasks = append(tasks, task)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over 'work' status tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// operandValues декодирует операнды задачи и возвращает их значения.
+// Возвращает ошибку, если какой-либо операнд еще ожидает результат другой задачи.
+func operandValues(encoded string) ([]string, error) {
+	var operands []models.TaskOperand
+	if err := json.Unmarshal([]byte(encoded), &operands); err != nil {
+		return nil, fmt.Errorf("decoding operands: %w", err)
+	}
+	return knownValues(operands)
+}
+
+// encodeMap кодирует набор значений вычисления по имени (длительности функций, переменные) в JSON;
+// пустой набор хранится как NULL.
+func encodeMap[V any](values map[string]V) (sql.NullString, error) {
+	if len(values) == 0 {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("encoding %v: %w", values, err)
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+// decodeMap декодирует набор значений, сохраненный encodeMap.
+func decodeMap[V any](encoded sql.NullString) (map[string]V, error) {
+	if !encoded.Valid {
+		return nil, nil
+	}
+	var values map[string]V
+	if err := json.Unmarshal([]byte(encoded.String), &values); err != nil {
+		return nil, fmt.Errorf("decoding %q: %w", encoded.String, err)
+	}
+	return values, nil
+}
+
+// encodePrecision раскладывает точность вычисления на столбцы precision_scale, rounding_mode и precision_mode;
+// вычисление в режиме float64 (nil) хранится как NULL.
+func encodePrecision(precision *calculation.Precision) (sql.NullInt64, sql.NullString, sql.NullString) {
+	if precision == nil {
+		return sql.NullInt64{}, sql.NullString{}, sql.NullString{}
+	}
+	// Столбец precision_scale заполнен у любого точного вычисления; в рациональном режиме масштаб не задан и равен 0
+	var scale int
+	if precision.Scale != nil {
+		scale = *precision.Scale
+	}
+	return sql.NullInt64{Int64: int64(scale), Valid: true},
+		sql.NullString{String: precision.Rounding, Valid: precision.Rounding != ""},
+		sql.NullString{String: precision.Mode, Valid: precision.Mode != ""}
+}
+
+// decodePrecision собирает точность, сохраненную encodePrecision.
+func decodePrecision(scale sql.NullInt64, rounding, mode sql.NullString) *calculation.Precision {
+	if !scale.Valid {
+		return nil
+	}
+	if mode.String == calculation.ModeRational {
+		return &calculation.Precision{Mode: mode.String, Rounding: rounding.String}
+	}
+	precision := calculation.Decimal(int(scale.Int64), rounding.String)
+	precision.Mode = mode.String
+	return &precision
+}
+
+// exactResult возвращает значение столбца exact_result: NULL для результата в режиме float64.
+func exactResult(exact string) sql.NullString {
+	return sql.NullString{String: exact, Valid: exact != ""}
+}
+
+// operandValue возвращает запись результата задачи для операнда родителя: точную, если она есть.
+func operandValue(result float64, exact string) string {
+	if exact != "" {
+		return exact
+	}
+	return strconv.FormatFloat(result, 'g', -1, 64)
+}
+
+// knownValues возвращает значения операндов или ошибку, если какой-либо операнд еще не вычислен.
+func knownValues(operands []models.TaskOperand) ([]string, error) {
+	values := make([]string, len(operands))
+	for i, operand := range operands {
+		if operand.TaskID != 0 {
+			return nil, fmt.Errorf("operand %d is still waiting for task %d", i, operand.TaskID)
+		}
+		values[i] = operand.Value
+	}
+	return values, nil
+}
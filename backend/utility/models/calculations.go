@@ -1,15 +1,28 @@
 package models
 
+import (
+    "time"
+
+    "calculatorapi/utility/calculation" // Точный режим вычисления
+)
+
 // CalculationRequest определяет структуру запроса на вычисление.
 type CalculationRequest struct {
     ID                  int    `json:"id"` // Идентификатор запроса, должен соответствовать схеме базы данных
+    TaskID              int    `json:"taskId,omitempty"` // Идентификатор подзадачи, если запрос описывает одну операцию выражения
     UserId              int    `json:"userId"` // Идентификатор юзера
     Operation           string `json:"operation"` // Строка операции, например "2+2"
     AddDuration         int    `json:"add_duration"` // Продолжительность операции сложения в секундах
     SubtractDuration    int    `json:"subtract_duration"` // Продолжительность операции вычитания в секундах
     MultiplyDuration    int    `json:"multiply_duration"` // Продолжительность операции умножения в секундах
     DivideDuration      int    `json:"divide_duration"` // Продолжительность операции деления в секундах
+    PowerDuration       int    `json:"power_duration"` // Продолжительность операции возведения в степень в секундах
+    ModuloDuration      int    `json:"modulo_duration"` // Продолжительность операции остатка от деления в секундах
+    IntDivideDuration   int    `json:"int_divide_duration"` // Продолжительность операции целочисленного деления в секундах
     InactiveServerTime  int    `json:"inactive_server_time,omitempty"` // Время бездействия сервера, может быть опущено
+    FunctionDurations   map[string]int `json:"function_durations,omitempty"` // Продолжительность вызова встроенных функций в секундах по имени, например "sqrt"
+    Variables           map[string]float64 `json:"variables,omitempty"` // Значения переменных выражения по имени, например {"rate": 0.07}
+    Precision           *calculation.Precision `json:"precision,omitempty"` // Точный десятичный или рациональный режим; nil - вычисление в float64
 }
 
 // CalculationResponse определяет структуру для возвращения результатов вычислений.
@@ -19,6 +32,21 @@ type CalculationResponse struct {
     UserId      int        `json:"userId"` // Идентификатор юзера
     Result      float64    `json:"result,omitempty"` // Результат вычисления, может быть опущен, если вычисление не завершено
     Status      string     `json:"status"` // Статус запроса, например "completed" или "error"
+    Error       string     `json:"error,omitempty"` // Сообщение об ошибке, если статус "error" или "failed"
+    Attempts    int        `json:"attempts,omitempty"` // Число неудачных попыток, после которых подзадачи отправлялись повторно
+    Variables   map[string]float64 `json:"variables,omitempty"` // Значения переменных выражения
+    Precision   *calculation.Precision `json:"precision,omitempty"` // Точность, если вычисление выполняется в точном режиме
+    ExactResult string     `json:"exactResult,omitempty"` // Точный результат без округления до float64, если задана точность
+}
+
+// FailedCalculation описывает вычисление, исчерпавшее попытки выполнения.
+type FailedCalculation struct {
+    ID          int        `json:"id"` // Идентификатор вычисления
+    UserId      int        `json:"userId"` // Идентификатор юзера
+    Operation   string     `json:"operation"` // Выражение вычисления
+    Attempts    int        `json:"attempts"` // Число неудачных попыток
+    Error       string     `json:"error"` // Ошибка последней попытки
+    FailedAt    time.Time  `json:"failedAt"` // Время перехода в статус "failed"
 }
 
 // OperationResponse определяет структуру для возвращения информации об операции.
@@ -28,6 +56,8 @@ type OperationResponse struct {
     Operation   string  `json:"operation"` // Строка операции, выполненной калькулятором
     Result      float64 `json:"result,omitempty"` // Результат операции, может быть опущен, если операция не завершена
     Status      string  `json:"status"` // Статус операции, например "created", "work" или "completed"
+    Variables   map[string]float64 `json:"variables,omitempty"` // Значения переменных выражения
+    ExactResult string  `json:"exactResult,omitempty"` // Точный результат, если вычисление выполнялось в точном режиме
 }
 
 // User определяет структуру для юзера.
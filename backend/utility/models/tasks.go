@@ -0,0 +1,63 @@
+package models
+
+import (
+    "time"
+
+    "calculatorapi/utility/calculation" // Точный режим вычисления
+)
+
+// Task определяет структуру подзадачи вычисления - одной операции графа выражения.
+type Task struct {
+    ID              int           `json:"id"` // Идентификатор подзадачи
+    CalculationID   int           `json:"calculationId"` // Идентификатор вычисления, к которому относится подзадача
+    ParentID        int           `json:"parentId,omitempty"` // Идентификатор подзадачи, ожидающей этот результат, 0 для корня
+    Position        int           `json:"position"` // Номер операнда в родительской подзадаче
+    Operator        string        `json:"operator"` // Оператор, например "+" или "neg"
+    Operands        []TaskOperand `json:"operands"` // Операнды подзадачи
+    Status          string        `json:"status"` // Статус: "waiting", "ready", "work", "completed", "error" или "cancelled"
+    Result          float64       `json:"result,omitempty"` // Результат, может быть опущен, если подзадача не завершена
+    Error           string        `json:"error,omitempty"` // Сообщение об ошибке, если статус "error"
+}
+
+// TaskOperand определяет операнд подзадачи: известное значение или ссылку на другую подзадачу.
+type TaskOperand struct {
+    Value   string `json:"value,omitempty"` // Запись числа, если значение уже известно
+    TaskID  int    `json:"taskId,omitempty"` // Идентификатор подзадачи, результат которой ожидается
+}
+
+// TaskAssignment определяет структуру подзадачи, выдаваемой калькулятору по запросу GET /internal/task.
+type TaskAssignment struct {
+    ID          int             `json:"id"` // Идентификатор вычисления
+    TaskID      int             `json:"taskId"` // Идентификатор подзадачи
+    Operation   string          `json:"operation"` // Выражение одной операции с подставленными операндами
+    Times       map[string]int  `json:"times"` // Длительности операций в секундах, например "add_duration"
+    Variables   map[string]float64 `json:"variables,omitempty"` // Значения переменных выражения вычисления
+    Precision   *calculation.Precision `json:"precision,omitempty"` // Точный десятичный или рациональный режим вычисления
+}
+
+// TaskResult определяет структуру результата подзадачи, принимаемого по запросу POST /internal/task.
+type TaskResult struct {
+    TaskID  int     `json:"taskId"` // Идентификатор подзадачи
+    Result  float64 `json:"result"` // Результат операции
+    ExactResult string `json:"exactResult,omitempty"` // Точная запись результата, если подзадача выполнялась в точном режиме
+    Error   string  `json:"error,omitempty"` // Сообщение об ошибке, если операцию не удалось выполнить
+}
+
+// WorkingTask определяет подзадачу в статусе "work" с длительностями операций, по которым оркестратор находит зависшие подзадачи.
+type WorkingTask struct {
+    ID                  int       // Идентификатор подзадачи
+    CalculationID       int       // Идентификатор вычисления
+    UserId              int       // Идентификатор юзера
+    Operator            string    // Оператор подзадачи
+    StartTime           time.Time // Время начала выполнения
+    Agent               string    // Имя калькулятора из реестра, выполняющего подзадачу; пустое, если неизвестно
+    AddDuration         int       // Продолжительность операции сложения в секундах
+    SubtractDuration    int       // Продолжительность операции вычитания в секундах
+    MultiplyDuration    int       // Продолжительность операции умножения в секундах
+    DivideDuration      int       // Продолжительность операции деления в секундах
+    PowerDuration       int       // Продолжительность операции возведения в степень в секундах
+    ModuloDuration      int       // Продолжительность операции остатка от деления в секундах
+    IntDivideDuration   int       // Продолжительность операции целочисленного деления в секундах
+    InactiveServerTime  int       // Время ожидания калькулятора сверх длительности операции в секундах; 0 - по умолчанию
+    FunctionDurations   map[string]int // Продолжительность вызова встроенных функций в секундах по имени
+}
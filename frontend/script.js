@@ -76,6 +76,15 @@ function getUserIDFromJWT() {
     return payload.userID; // Make sure the key matches the payload's key
 }
 
+// Функция формирования заголовков запроса с JWT токеном для защищенных эндпоинтов
+function authHeaders(headers = {}) {
+    const token = localStorage.getItem('jwt');
+    if (token) {
+        headers['Authorization'] = `Bearer ${token}`;
+    }
+    return headers;
+}
+
 // Функция очистки формы регистрации
 function clearRegistrationForm() {
     document.getElementById('register-username').value = ''; // Clears the username input
@@ -125,33 +134,44 @@ function register() {
 // Отправить вычисление на сервер
 function submitCalculation() {
     const expression = document.getElementById('expression').value; // Получаем выражение от пользователя
+    const variablesText = document.getElementById('variables').value; // Значения переменных в формате JSON
     const calculationResultsSection = document.getElementById('calculation-results'); // Получаем секцию для вывода результатов
 
-    // Проверяем выражение на валидность и на деление на ноль
-    if (/\/0/.test(expression)) {
-        appendCalculationResult(calculationResultsSection, null, `[${expression}] - Division by zero is not allowed.`, 'error');
+    // Проверка синтаксиса выполняется сервером: ошибка с позицией возвращается в поле error ответа
+    if (!expression.trim()) {
+        appendCalculationResult(calculationResultsSection, null, `[${expression}] - Expression is empty.`, 'error');
         return;
     }
-    // Улучшенное регулярное выражение для проверки валидности арифметического выражения
-    if (!/^\d+([\+\-\*\/]\(?\-?\d+\)?)+$/.test(expression)) {
-        appendCalculationResult(calculationResultsSection, null, `[${expression}] - Invalid expression format.`, 'error');
-        return;
+
+    // Имена и значения переменных проверяет сервер, здесь только разбирается JSON
+    let variables;
+    if (variablesText.trim()) {
+        try {
+            variables = JSON.parse(variablesText);
+        } catch (error) {
+            appendCalculationResult(calculationResultsSection, null, `${expression} - Invalid variables JSON: ${error.message}`, 'error');
+            return;
+        }
     }
 
     // Отправляем запрос на сервер
     fetch('http://localhost:8080/submit-calculation', {
         method: 'POST',
-        headers: {
+        headers: authHeaders({
             'Content-Type': 'application/json'
-        },
+        }),
         body: JSON.stringify({
-            UserId: getUserIDFromJWT(),
             operation: expression,
             add_duration: parseInt(document.getElementById('plus-time').value),
             subtract_duration: parseInt(document.getElementById('minus-time').value),
             multiply_duration: parseInt(document.getElementById('multiply-time').value),
             divide_duration: parseInt(document.getElementById('divide-time').value),
+            power_duration: parseInt(document.getElementById('power-time').value),
+            modulo_duration: parseInt(document.getElementById('modulo-time').value),
+            int_divide_duration: parseInt(document.getElementById('int-divide-time').value),
             inactive_server_time: parseInt(document.getElementById('inactive-server-time').value),
+            variables: variables,
+            precision: readPrecision(),
         })
     })
     .then(response => response.json())
@@ -163,10 +183,10 @@ function submitCalculation() {
             localStorage.setItem('successfulIDs', JSON.stringify(successfulIDs));
 
             // Добавляем новый элемент для отображения операции и статуса
-            appendCalculationResult(calculationResultsSection, data.id, data.operation, 'pending');
+            appendCalculationResult(calculationResultsSection, data.id, `${data.operation}${formatVariables(data.variables)}`, 'pending');
         } else if (data.status === 'error') {
             // Добавляем сообщение об ошибке и операцию
-            appendCalculationResult(calculationResultsSection, `${expression} - ${data.error}`, 'error');
+            appendCalculationResult(calculationResultsSection, data.id, `${expression} - ${data.error}`, 'error');
         }
     })
     .catch((error) => {
@@ -204,18 +224,43 @@ function loadAllCalculations() {
         return; // Возвращаем ошибку или прекращаем выполнение, если ID пользователя не найден
     }
 
-    fetch(`http://localhost:8080/get-calculations-by-user?userId=${userId}`)
+    fetch('http://localhost:8080/get-calculations-by-user', { headers: authHeaders() })
         .then(response => response.json())
         .then(data => {
             data.forEach(calculation => {
                 const status = calculation.status === 'completed' ? 'success' : 'pending';
-                const resultText = calculation.status === 'completed' ? calculation.result : '?';
-                appendCalculationResult(calculationResultsSection, calculation.id, `${calculation.operation} Result = ${resultText}`, status);
+                const resultText = calculation.status === 'completed' ? formatResult(calculation) : '?';
+                appendCalculationResult(calculationResultsSection, calculation.id, `${calculation.operation}${formatVariables(calculation.variables)} Result = ${resultText}`, status);
             });
         })
         .catch(error => console.error('Error loading calculations:', error));
 }
 
+// Точный режим из настроек: рациональный, либо десятичный, если задан масштаб; иначе вычисление выполняется в числах с плавающей точкой
+function readPrecision() {
+    if (document.getElementById('arithmetic-mode').value === 'rational') {
+        return { mode: 'rational' };
+    }
+    const scale = document.getElementById('precision-scale').value;
+    if (scale === '') {
+        return undefined;
+    }
+    return { scale: parseInt(scale), rounding: document.getElementById('rounding-mode').value };
+}
+
+// Результат вычисления для вывода: точная запись, если вычисление выполнялось в точном режиме
+function formatResult(calculation) {
+    return calculation.exactResult !== undefined ? calculation.exactResult : calculation.result;
+}
+
+// Значения переменных вычисления для вывода рядом с выражением, например " where rate = 0.07, n = 12"
+function formatVariables(variables) {
+    if (!variables || Object.keys(variables).length === 0) {
+        return '';
+    }
+    return ' where ' + Object.keys(variables).sort().map(name => `${name} = ${variables[name]}`).join(', ');
+}
+
 // Функция appendCalculationResult для динамического контента в зависимости от статуса
 function appendCalculationResult(parentElement, id, message, status) {
     const resultElement = document.createElement('div');
@@ -231,6 +276,13 @@ function appendCalculationResult(parentElement, id, message, status) {
     resultElement.appendChild(operationLine);
 
     if (status === 'pending') {
+        // Кнопка отмены добавляется перед строкой статуса, чтобы updateResults находил ее как div:last-child
+        const cancelButton = document.createElement('button');
+        cancelButton.className = 'cancel-calculation';
+        cancelButton.textContent = 'Cancel';
+        cancelButton.addEventListener('click', () => cancelCalculation(id));
+        resultElement.appendChild(cancelButton);
+
         const pendingLine = document.createElement('div');
         pendingLine.textContent = 'Expression will be calculated soon.';
         resultElement.appendChild(pendingLine);
@@ -245,7 +297,13 @@ function saveSettings() {
     localStorage.setItem('minus-time', document.getElementById('minus-time').value);
     localStorage.setItem('multiply-time', document.getElementById('multiply-time').value);
     localStorage.setItem('divide-time', document.getElementById('divide-time').value);
+    localStorage.setItem('power-time', document.getElementById('power-time').value);
+    localStorage.setItem('modulo-time', document.getElementById('modulo-time').value);
+    localStorage.setItem('int-divide-time', document.getElementById('int-divide-time').value);
     localStorage.setItem('inactive-server-time', document.getElementById('inactive-server-time').value);
+    localStorage.setItem('arithmetic-mode', document.getElementById('arithmetic-mode').value);
+    localStorage.setItem('precision-scale', document.getElementById('precision-scale').value);
+    localStorage.setItem('rounding-mode', document.getElementById('rounding-mode').value);
 
     alert('Settings saved successfully.');
 }
@@ -294,11 +352,12 @@ function appendServerStatusDiv(parentElement, server) {
     const serverDiv = document.createElement('div');
     serverDiv.className = server.running ? 'server-status running' : 'server-status error';
     serverDiv.innerHTML = `
-        <p><strong>Type:</strong> Calculator</p>
-        <p><strong>URL:</strong> ${server.url}</p>
+        <p><strong>Type:</strong> Calculator${server.name ? ` (${server.name})` : ''}</p>
+        <p><strong>URL:</strong> ${server.url || `grpc://${server.grpcAddress}`}</p>
         <p><strong>Status:</strong> ${server.running ? 'Running' : 'Not Running'}${server.error ? ` (Error: ${server.error})` : ''}</p>
         ${server.running ? `<p><strong>Max Goroutines:</strong> ${server.maxGoroutines}</p>
         <p><strong>Current Goroutines:</strong> ${server.currentGoroutines}</p>` : ''}
+        ${server.lastSeen ? `<p><strong>Last Seen:</strong> ${new Date(server.lastSeen).toLocaleString()}</p>` : ''}
     `;
     parentElement.appendChild(serverDiv);
 }
@@ -324,32 +383,139 @@ function updateResults() {
         const id = resultElement.id.split('-')[1]; // Предполагается, что формат ID - "result-{id}"
 
         // Запрашиваем результат операции по ID
-        fetch(`http://localhost:8080/get-calculation-result?id=${id}`)
+        fetch(`http://localhost:8080/get-calculation-result?id=${id}`, { headers: authHeaders() })
             .then(response => response.json())
             .then(data => {
                 if (data.status === 'completed' && data.result !== undefined) {
                     // Обновляем текст результата и класс элемента
                     const operationLine = resultElement.querySelector('div:last-child');
-                    operationLine.textContent = `[${data.operation}] Result = ${data.result}`;
+                    operationLine.textContent = `[${data.operation}]${formatVariables(data.variables)} Result = ${formatResult(data)}`;
                     resultElement.classList.remove('pending');
                     resultElement.classList.add('success');
                     resultElement.style.backgroundColor = "#4CAF50"; // Зеленый фон для завершенных операций
+                } else if (data.status === 'error') {
+                    // Показываем сообщение об ошибке вычисления вместо результата
+                    const operationLine = resultElement.querySelector('div:last-child');
+                    operationLine.textContent = `[${data.operation}]${formatVariables(data.variables)} Error: ${data.error}`;
+                    resultElement.classList.remove('pending');
+                    resultElement.classList.add('error');
+                } else if (data.status === 'failed') {
+                    // Попытки выполнить вычисление исчерпаны, его может вернуть в очередь администратор
+                    const operationLine = resultElement.querySelector('div:last-child');
+                    operationLine.textContent = `[${data.operation}]${formatVariables(data.variables)} Failed after ${data.attempts} attempts: ${data.error}`;
+                    resultElement.classList.remove('pending');
+                    resultElement.classList.add('error');
+                } else if (data.status === 'cancelled') {
+                    const operationLine = resultElement.querySelector('div:last-child');
+                    operationLine.textContent = `[${data.operation}]${formatVariables(data.variables)} Cancelled`;
+                    resultElement.classList.remove('pending');
+                    resultElement.classList.add('cancelled');
                 } else {
                     // Если статус не завершен или результат отсутствует, оставляем как есть
                     console.log(`Calculation ID ${id} is still pending.`);
                 }
+
+                // Завершенное вычисление отменить уже нельзя
+                const cancelButton = resultElement.querySelector('.cancel-calculation');
+                if (cancelButton && !resultElement.classList.contains('pending')) {
+                    cancelButton.remove();
+                }
             })
             .catch(error => console.error('Error updating result:', error));
     });
 }
 
+// Подписка на поток изменений вычислений пользователя. EventSource не передает заголовок Authorization,
+// поэтому поток Server-Sent Events читается через fetch; при разрыве подписка повторяется.
+function subscribeCalculationEvents() {
+    if (!localStorage.getItem('jwt')) {
+        setTimeout(subscribeCalculationEvents, 5000);
+        return;
+    }
+
+    fetch('http://localhost:8080/api/v1/calculations/events', { headers: authHeaders() })
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`Event stream responded with status ${response.status}`);
+            }
+            const reader = response.body.getReader();
+            const decoder = new TextDecoder();
+            let buffer = '';
+
+            const read = () => reader.read().then(({ done, value }) => {
+                if (done) {
+                    throw new Error('Event stream closed');
+                }
+                buffer += decoder.decode(value, { stream: true });
+
+                // События разделяются пустой строкой
+                let separator;
+                while ((separator = buffer.indexOf('\n\n')) !== -1) {
+                    handleCalculationEvent(buffer.slice(0, separator));
+                    buffer = buffer.slice(separator + 2);
+                }
+                return read();
+            });
+            return read();
+        })
+        .catch(error => {
+            console.error('Calculation events:', error);
+            setTimeout(subscribeCalculationEvents, 5000);
+        });
+}
+
+// Обработка одного события потока: "status" - переход вычисления, "step" - изменение подзадачи
+function handleCalculationEvent(chunk) {
+    let name = '';
+    let data = '';
+    chunk.split('\n').forEach(line => {
+        if (line.startsWith('event: ')) {
+            name = line.slice(7);
+        } else if (line.startsWith('data: ')) {
+            data += line.slice(6);
+        }
+    });
+    if (!data) {
+        return; // Комментарий keep-alive
+    }
+    const payload = JSON.parse(data);
+
+    if (name === 'status' && ['completed', 'error', 'failed', 'cancelled'].includes(payload.status)) {
+        // Итоговое состояние отображается так же, как при опросе
+        updateResults();
+    } else if (name === 'step' && payload.status === 'completed') {
+        // Промежуточный результат показывается в строке статуса ожидающего вычисления
+        const resultElement = document.querySelector(`#result-${payload.calculationId}.pending`);
+        if (resultElement) {
+            resultElement.querySelector('div:last-child').textContent = `Step ${payload.operator} = ${payload.result}`;
+        }
+    }
+}
+
+// Отмена вычисления, которое еще ожидает в очереди или выполняется
+function cancelCalculation(id) {
+    fetch(`http://localhost:8080/api/v1/calculations/${id}/cancel`, {
+        method: 'POST',
+        headers: authHeaders()
+    })
+    .then(response => {
+        if (response.ok || response.status === 409) {
+            // Вычисление отменено или уже завершилось, обновляем его статус
+            updateResults();
+        } else {
+            console.error(`Failed to cancel calculation ID ${id}.`);
+        }
+    })
+    .catch(error => console.error('Error:', error));
+}
+
 // Функция для очистки и обновления результатов операций
 function clearAllCalculationsAndUpdate() {
     fetch('http://localhost:8080/clear-all-calculations', {
         method: 'POST',
-        headers: {
+        headers: authHeaders({
             'Content-Type': 'application/json'
-        }
+        })
     })
     .then(response => {
         if (response.ok) {
@@ -372,7 +538,8 @@ document.addEventListener('DOMContentLoaded', function() {
     document.getElementById('reload-operations-status').addEventListener('click', updateResults);
     loadAllCalculations(); // Загрузка всех вычислений при загрузке страницы
 
-    // Автоматическое обновление всех вычислений каждую минуту
+    // Изменения вычислений приходят из потока событий, ежеминутный опрос остается запасным вариантом
+    subscribeCalculationEvents();
     setInterval(updateResults, 60000);
 
     // Применение настроек изначально и каждый раз при их сохранении